@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -28,20 +29,21 @@ func echoServer() {
 		panic(err)
 	}
 
-	go func() {
-		for conn := range s.Accept() {
-			go func(c server.Connection) {
-				fmt.Printf("client connected: %v\n", c.RemoteAddress())
-				for str := range stream.String(c) {
-					fmt.Printf("[%s] received: %s\n", c.RemoteAddress(), str)
-					_, e := c.Write([]byte(str))
-					if e != nil {
-						panic(e)
-					}
-				}
-			}(conn)
+	echo := server.HandlerFunc(func(_ context.Context, c server.Connection) {
+		for str := range stream.String(c) {
+			fmt.Printf("[%s] received: %s\n", c.RemoteAddress(), str)
+			_, e := c.Write([]byte(str))
+			if e != nil {
+				panic(e)
+			}
 		}
-	}()
+	})
+
+	err = s.Start()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Stop()
 
 	go func() {
 		for e := range s.Errors() {
@@ -49,11 +51,12 @@ func echoServer() {
 		}
 	}()
 
-	err = s.Start()
-	if err != nil {
-		panic(err)
-	}
-	defer s.Stop()
+	go func() {
+		e := s.Serve(server.Chain(echo, server.Logging(nil)))
+		if e != nil {
+			panic(e)
+		}
+	}()
 
 	fmt.Println("server started...")
 
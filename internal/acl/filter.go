@@ -0,0 +1,152 @@
+package acl
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// Filter Decides whether a remote host may connect based on allow and deny
+// lists, whose entries are IP addresses, CIDRs (e.g., "10.0.0.0/8") or MAC
+// address prefixes of one to six octets (e.g., "AA:BB:CC" for a vendor).
+// Deny entries take precedence, and an empty allow list allows every host
+// that is not denied.
+// Thread-safe ✓ (immutable once created)
+type Filter struct {
+	allow []rule
+	deny  []rule
+}
+
+type rule struct {
+	prefix    netip.Prefix
+	macPrefix []byte
+}
+
+// New Returns nil (which allows every host) if both lists are empty
+func New(allow []string, deny []string) (*Filter, error) {
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil, nil
+	}
+
+	allowRules, err := parseRules(allow)
+	if err != nil {
+		return nil, err
+	}
+
+	denyRules, err := parseRules(deny)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Filter{allow: allowRules, deny: denyRules}, nil
+}
+
+// Allowed Returns true if the host (an IP or MAC address) may connect
+func (f *Filter) Allowed(host string) bool {
+	if f == nil {
+		return true
+	}
+
+	ip, mac := parseHost(host)
+
+	for _, r := range f.deny {
+		if r.match(ip, mac) {
+			return false
+		}
+	}
+
+	if len(f.allow) == 0 {
+		return true
+	}
+
+	for _, r := range f.allow {
+		if r.match(ip, mac) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Host Returns the host portion of a remote address, which is the address
+// itself if it has no port (as with RFCOMM MAC addresses and mem:// addresses).
+func Host(remoteAddress string) string {
+	if strings.Contains(remoteAddress, "://") {
+		return remoteAddress
+	}
+
+	host, _, err := net.SplitHostPort(remoteAddress)
+	if err != nil {
+		return remoteAddress
+	}
+	return host
+}
+
+func (r rule) match(ip netip.Addr, mac net.HardwareAddr) bool {
+	if r.macPrefix != nil {
+		return mac != nil && bytes.HasPrefix(mac, r.macPrefix)
+	}
+	return ip.IsValid() && r.prefix.Contains(ip)
+}
+
+func parseRules(entries []string) ([]rule, error) {
+	rules := make([]rule, 0, len(entries))
+
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			rules = append(rules, rule{prefix: prefix.Masked()})
+			continue
+		}
+
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			addr = addr.Unmap()
+			rules = append(rules, rule{prefix: netip.PrefixFrom(addr, addr.BitLen())})
+			continue
+		}
+
+		if macPrefix, err := parseMacPrefix(entry); err == nil {
+			rules = append(rules, rule{macPrefix: macPrefix})
+			continue
+		}
+
+		return nil, fmt.Errorf("access list entry '%s' is not an IP address, CIDR or MAC address prefix", entry)
+	}
+
+	return rules, nil
+}
+
+func parseMacPrefix(entry string) ([]byte, error) {
+	octets := strings.Split(entry, ":")
+	if len(octets) > 6 {
+		return nil, fmt.Errorf("too many octets")
+	}
+
+	prefix := make([]byte, len(octets))
+	for i, octet := range octets {
+		if len(octet) != 2 {
+			return nil, fmt.Errorf("invalid octet '%s'", octet)
+		}
+		b, err := strconv.ParseUint(octet, 16, 8)
+		if err != nil {
+			return nil, err
+		}
+		prefix[i] = byte(b)
+	}
+
+	return prefix, nil
+}
+
+func parseHost(host string) (netip.Addr, net.HardwareAddr) {
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return ip.Unmap().WithZone(""), nil
+	}
+	if mac, err := net.ParseMAC(host); err == nil {
+		return netip.Addr{}, mac
+	}
+	return netip.Addr{}, nil
+}
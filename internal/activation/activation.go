@@ -0,0 +1,92 @@
+package activation
+
+import (
+	"fmt"
+	"golang.org/x/sys/unix"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"tonysoft.com/comm/pkg/comerr"
+)
+
+// listenFdsStart The first file descriptor passed by systemd (SD_LISTEN_FDS_START)
+const listenFdsStart = 3
+
+var (
+	filesOnce  sync.Once
+	filesMutex sync.Mutex
+	files      []*os.File // nil once taken
+	filesErr   error
+)
+
+// Take Returns a socket passed to the process via systemd socket activation
+// (LISTEN_PID, LISTEN_FDS and LISTEN_FDNAMES) with the given name (""
+// matches any name) and type (unix.SOCK_STREAM or unix.SOCK_DGRAM).  Each
+// socket is only returned once, so that it is owned by a single server.
+func Take(name string, sockType int) (*os.File, error) {
+	filesOnce.Do(func() {
+		files, filesErr = listenFiles()
+	})
+	if filesErr != nil {
+		return nil, filesErr
+	}
+
+	filesMutex.Lock()
+	defer filesMutex.Unlock()
+
+	for i, f := range files {
+		if f == nil || (name != "" && f.Name() != name) {
+			continue
+		}
+
+		t, err := unix.GetsockoptInt(int(f.Fd()), unix.SOL_SOCKET, unix.SO_TYPE)
+		if err != nil || t != sockType {
+			continue
+		}
+
+		files[i] = nil
+		return f, nil
+	}
+
+	return nil, fmt.Errorf("%w : name '%s', type %d", comerr.ErrSocketNotPassed, name, sockType)
+}
+
+// listenFiles Parses the environment as per sd_listen_fds(3), then unsets it
+// so that it is not inherited by child processes
+func listenFiles() ([]*os.File, error) {
+	defer func() {
+		_ = os.Unsetenv("LISTEN_PID")
+		_ = os.Unsetenv("LISTEN_FDS")
+		_ = os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count < 1 {
+		return nil, nil
+	}
+
+	var names []string
+	if fdNames := os.Getenv("LISTEN_FDNAMES"); fdNames != "" {
+		names = strings.Split(fdNames, ":")
+	}
+
+	result := make([]*os.File, count)
+	for i := 0; i < count; i++ {
+		fd := listenFdsStart + i
+		unix.CloseOnExec(fd)
+
+		name := "unknown"
+		if i < len(names) {
+			name = names[i]
+		}
+		result[i] = os.NewFile(uintptr(fd), name)
+	}
+
+	return result, nil
+}
@@ -1,16 +1,130 @@
 package client
 
 import (
+	"context"
+	"net"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"time"
 	"tonysoft.com/comm/internal/comobj"
 	"tonysoft.com/comm/internal/config"
 	_config "tonysoft.com/comm/internal/config/client"
+	"tonysoft.com/comm/internal/dialproxy"
+	"tonysoft.com/comm/internal/netif"
+	"tonysoft.com/comm/internal/transport"
 )
 
 type BaseClient struct {
 	config.DefaultConfigurable[_config.Config]
 	comobj.DefaultConnectable
+	DefaultEventProducer
 }
 
 func (c *BaseClient) RemoteAddress() string {
 	return c.Config().RemoteAddress
 }
+
+func (c *BaseClient) setConnected() {
+	c.ConfigureEvents(c.Config().EventChanBufferSize)
+	c.SetConnectTime(time.Now().UTC())
+	c.SetIsConnected(true)
+	c.SendEvent(Event{Type: Connected})
+}
+
+func (c *BaseClient) setDisconnected() {
+	if c.IsConnected() {
+		c.SetDisconnectTime(time.Now().UTC())
+		c.SetIsConnected(false)
+		c.SendEvent(Event{Type: Disconnected})
+	}
+	c.CloseEvents()
+}
+
+// localAddr Returns the source address to dial from (see LocalAddress), nil
+// if the system should choose.  Interface names are resolved on each call,
+// so that reconnecting follows changes to the interface's address.
+func (c *BaseClient) localAddr(udp bool) (net.Addr, error) {
+	localAddress := c.Config().LocalAddress
+	if localAddress == "" {
+		return nil, nil
+	}
+
+	var addrPort netip.AddrPort
+	if addr, err := netip.ParseAddr(localAddress); err == nil {
+		addrPort = netip.AddrPortFrom(addr, 0)
+	} else if addrPort, err = netip.ParseAddrPort(localAddress); err != nil {
+		ip, ifErr := netif.Address(localAddress)
+		if ifErr != nil {
+			return nil, ifErr
+		}
+		addr, _ := netip.AddrFromSlice(ip)
+		addrPort = netip.AddrPortFrom(addr, 0)
+	}
+
+	if udp {
+		return net.UDPAddrFromAddrPort(addrPort), nil
+	}
+	return net.TCPAddrFromAddrPort(addrPort), nil
+}
+
+// resolvesHostname Returns true if host is a hostname to be looked up by
+// the Resolver rather than by the standard library
+func (c *BaseClient) resolvesHostname(host string) bool {
+	return c.Config().ResolveHostnames && transport.IsHostname(host)
+}
+
+// dialHostname Dials each address of the host hostname in turn
+// until one answers, giving each attempt an equal share of what is left of
+// the dialer's timeout.  If none answers the hostname is looked up again on
+// the next call, in case its addresses have changed.
+func (c *BaseClient) dialHostname(ctx context.Context, dialer net.Dialer, network string, host string, port uint16) (net.Conn, error) {
+	cfg := c.Config()
+
+	ips, err := transport.ResolveHost(ctx, cfg.Resolver, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var deadline time.Time
+	if dialer.Timeout > 0 {
+		deadline = time.Now().Add(dialer.Timeout)
+	}
+
+	for i, ip := range ips {
+		if !deadline.IsZero() {
+			dialer.Timeout = time.Until(deadline) / time.Duration(len(ips)-i)
+		}
+
+		family := "4"
+		if ip.To4() == nil {
+			family = "6"
+		}
+
+		var conn net.Conn
+		conn, err = dialer.DialContext(ctx, network+family, net.JoinHostPort(ip.String(), strconv.Itoa(int(port))))
+		if err == nil {
+			return conn, nil
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	transport.ForgetHost(cfg.Resolver, host)
+	return nil, err
+}
+
+// dialProxy Returns the proxy to connect to host through (see DialProxy),
+// nil if none
+func (c *BaseClient) dialProxy(host string) (*url.URL, error) {
+	cfg := c.Config()
+	if cfg.DialProxy != "" {
+		return dialproxy.Parse(cfg.DialProxy)
+	}
+	if cfg.DialProxyFromEnvironment {
+		return dialproxy.FromEnvironment(host)
+	}
+	return nil, nil
+}
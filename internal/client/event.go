@@ -0,0 +1,76 @@
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+type EventType int
+
+const (
+	Connected EventType = iota
+	Disconnected
+	Reconnecting
+	Reconnected
+	ReconnectFailed
+)
+
+// Event Produced by clients when their connection state changes.  Attempt
+// and Delay are only set for reconnection events, and Err holds the error
+// that caused the disconnect or the failed reconnection attempt, if any.
+type Event struct {
+	Type    EventType
+	Time    time.Time
+	Attempt int
+	Delay   time.Duration
+	Err     error
+}
+
+type EventProducer interface {
+	Events() <-chan Event
+}
+
+type DefaultEventProducer struct {
+	eventChan      chan Event
+	eventChanMutex sync.Mutex
+}
+
+func (p *DefaultEventProducer) Events() <-chan Event {
+	p.eventChanMutex.Lock()
+	defer p.eventChanMutex.Unlock()
+	return p.eventChan
+}
+
+func (p *DefaultEventProducer) ConfigureEvents(chanBufferSize int) {
+	p.CloseEvents()
+	p.eventChanMutex.Lock()
+	p.eventChan = make(chan Event, chanBufferSize)
+	p.eventChanMutex.Unlock()
+}
+
+func (p *DefaultEventProducer) SendEvent(event Event) {
+	p.eventChanMutex.Lock()
+	defer p.eventChanMutex.Unlock()
+
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+
+	if p.eventChan != nil {
+		select {
+		case p.eventChan <- event:
+			return
+		default:
+		}
+	}
+}
+
+func (p *DefaultEventProducer) CloseEvents() {
+	p.eventChanMutex.Lock()
+	defer p.eventChanMutex.Unlock()
+
+	if p.eventChan != nil {
+		close(p.eventChan)
+		p.eventChan = nil
+	}
+}
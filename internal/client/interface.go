@@ -0,0 +1,29 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net"
+	"tonysoft.com/comm/internal/comobj"
+	"tonysoft.com/comm/internal/config"
+	_config "tonysoft.com/comm/internal/config/client"
+)
+
+// Client Implemented by every concrete client and exported by the public
+// client package; declared here so that types in this package (like
+// ReconnectClient and PooledClient) can wrap any concrete client.
+type Client interface {
+	config.Configurable[_config.Config]
+	RemoteAddress() string
+	LocalAddr() net.Addr
+	RemoteAddr() net.Addr
+	Start() error
+	StartContext(context.Context) error
+	Stop() error
+	comobj.Connectable
+	io.Reader
+	io.Writer
+	ReadContext(context.Context, []byte) (int, error)
+	WriteContext(context.Context, []byte) (int, error)
+	EventProducer
+}
@@ -0,0 +1,148 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+	"tonysoft.com/comm/internal/memnet"
+	"tonysoft.com/comm/internal/socket"
+	"tonysoft.com/comm/pkg/comerr"
+)
+
+// MemoryClient Connects to a server (or node) listening on a mem:// address
+// within the same process, e.g., for testing without sockets.  The
+// connection's local address is LocalAddress if it is a mem:// address.
+type MemoryClient struct {
+	BaseClient
+	conn          *memnet.Conn
+	readTimeoutUs int
+	stopMutex     sync.Mutex
+}
+
+func (c *MemoryClient) Start() error {
+	return c.StartContext(context.Background())
+}
+
+func (c *MemoryClient) StartContext(ctx context.Context) error {
+	if c.IsConnected() {
+		return comerr.ErrClientAlreadyConnected
+	}
+
+	cfg := c.Config()
+
+	c.readTimeoutUs = cfg.ReadTimeoutUs
+
+	if cfg.ConnectTimeoutSec > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(cfg.ConnectTimeoutSec)*time.Second)
+		defer cancel()
+	}
+
+	latency := time.Duration(cfg.MemoryLatencyMs) * time.Millisecond
+	conn, err := memnet.Dial(ctx, cfg.RemoteAddress, cfg.LocalAddress, latency)
+	if err != nil {
+		return err
+	}
+
+	c.conn = conn
+	c.setConnected()
+
+	return nil
+}
+
+func (c *MemoryClient) Stop() error {
+	c.stopMutex.Lock()
+	defer c.stopMutex.Unlock()
+
+	defer c.setDisconnected()
+	if c.conn != nil {
+		err := c.conn.Close()
+		c.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (c *MemoryClient) LocalAddr() net.Addr {
+	conn := c.conn
+	if conn == nil {
+		return nil
+	}
+	return conn.LocalAddr()
+}
+
+func (c *MemoryClient) RemoteAddr() net.Addr {
+	conn := c.conn
+	if conn == nil {
+		return nil
+	}
+	return conn.RemoteAddr()
+}
+
+func (c *MemoryClient) Read(buffer []byte) (int, error) {
+	return c.ReadContext(context.Background(), buffer)
+}
+
+func (c *MemoryClient) ReadContext(ctx context.Context, buffer []byte) (int, error) {
+	conn := c.conn
+	if conn == nil {
+		return -1, net.ErrClosed
+	}
+
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+
+	err := conn.SetReadDeadline(time.Now().Add(time.Duration(c.readTimeoutUs) * time.Microsecond))
+	if err != nil {
+		return -1, fmt.Errorf("%w : %v", comerr.ErrSetReadTimeout, err)
+	}
+
+	aborted := socket.AbortOnDone(ctx, conn.SetReadDeadline)
+	count, err := conn.Read(buffer)
+	if aborted() {
+		return count, ctx.Err()
+	}
+
+	err = socket.SinkReadWriteError(err)
+	if err != nil {
+		_ = c.Stop()
+	}
+	return count, err
+}
+
+func (c *MemoryClient) Write(data []byte) (int, error) {
+	return c.WriteContext(context.Background(), data)
+}
+
+func (c *MemoryClient) WriteContext(ctx context.Context, data []byte) (int, error) {
+	conn := c.conn
+	if conn == nil {
+		return -1, net.ErrClosed
+	}
+
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+
+	aborted := socket.AbortOnDone(ctx, conn.SetWriteDeadline)
+	count, err := conn.Write(data)
+	if aborted() {
+		_ = conn.SetWriteDeadline(time.Time{})
+		return count, ctx.Err()
+	}
+
+	err = socket.SinkReadWriteError(err)
+	if err != nil {
+		_ = c.Stop()
+	}
+	return count, err
+}
+
+// IsAlive Used by Pool to verify an idle connection before reusing it
+func (c *MemoryClient) IsAlive() bool {
+	conn := c.conn
+	return conn != nil && conn.IsAlive()
+}
@@ -0,0 +1,336 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+	"tonysoft.com/comm/internal/comobj"
+	"tonysoft.com/comm/internal/config"
+	_config "tonysoft.com/comm/internal/config/client"
+	_pool "tonysoft.com/comm/internal/config/pool"
+	"tonysoft.com/comm/pkg/comerr"
+)
+
+// Pool Keeps warm client connections per remote address so that callers
+// talking to the same host repeatedly do not pay for a dial each time.
+// Configs that differ in how they connect (LocalAddress, proxy, TLSConfig or
+// SocketOptions) are pooled, and limited, separately.  Clients checked out
+// of the pool are returned to it by calling Stop().
+type Pool struct {
+	config.DefaultConfigurable[_pool.Config]
+
+	newClient func(_config.Config) (Client, error)
+
+	mutex     sync.Mutex
+	hosts     map[string]*poolHost
+	closed    bool
+	closeChan chan bool
+}
+
+type poolHost struct {
+	idle        []*PooledClient
+	count       int       // idle + checked out
+	releaseChan chan bool // closed (and replaced) whenever a slot frees up
+}
+
+func NewPool(cfg _pool.Config, newClient func(_config.Config) (Client, error)) *Pool {
+	p := &Pool{
+		newClient: newClient,
+		hosts:     make(map[string]*poolHost),
+		closeChan: make(chan bool),
+	}
+	p.SetConfig(cfg)
+
+	go p.pruneIdleConnections()
+
+	return p
+}
+
+// Get Check out a client for the remote address in cfg, returning
+// ErrConnectionLimitReached immediately if the per-host limit is reached.
+func (p *Pool) Get(cfg _config.Config) (Client, error) {
+	return p.get(context.Background(), cfg, false)
+}
+
+// GetContext Check out a client for the remote address in cfg, waiting
+// for one to be returned if the per-host limit is reached.
+func (p *Pool) GetContext(ctx context.Context, cfg _config.Config) (Client, error) {
+	return p.get(ctx, cfg, true)
+}
+
+func (p *Pool) IdleCount() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	count := 0
+	for _, host := range p.hosts {
+		count += len(host.idle)
+	}
+	return count
+}
+
+func (p *Pool) ConnectionCount() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	count := 0
+	for _, host := range p.hosts {
+		count += host.count
+	}
+	return count
+}
+
+// Close Stops all idle clients; clients that are checked out
+// will be stopped when they are returned to the pool.
+func (p *Pool) Close() error {
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.closeChan)
+
+	var idle []*PooledClient
+	for _, host := range p.hosts {
+		idle = append(idle, host.idle...)
+		host.count -= len(host.idle)
+		host.idle = nil
+		host.release()
+	}
+	p.mutex.Unlock()
+
+	var err error
+	for _, c := range idle {
+		if e := c.Client.Stop(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (p *Pool) get(ctx context.Context, cfg _config.Config, wait bool) (Client, error) {
+	key := poolKey(cfg)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		p.mutex.Lock()
+		if p.closed {
+			p.mutex.Unlock()
+			return nil, net.ErrClosed
+		}
+
+		host := p.host(key)
+
+		if idleCount := len(host.idle); idleCount > 0 {
+			c := host.idle[idleCount-1]
+			host.idle = host.idle[:idleCount-1]
+			p.mutex.Unlock()
+
+			if p.isHealthy(c) {
+				c.checkedOut.Store(true)
+				return c, nil
+			}
+
+			_ = c.Client.Stop()
+			p.release(key)
+			continue
+		}
+
+		maxCount := p.Config().MaxConnectionsPerHost
+		if maxCount < 1 || host.count < maxCount {
+			host.count++
+			p.mutex.Unlock()
+
+			c, err := p.dial(ctx, cfg, key)
+			if err != nil {
+				p.release(key)
+				return nil, err
+			}
+			return c, nil
+		}
+
+		releaseChan := host.releaseChan
+		p.mutex.Unlock()
+
+		if !wait {
+			return nil, fmt.Errorf("%w : %d", comerr.ErrConnectionLimitReached, maxCount)
+		}
+
+		select {
+		case <-releaseChan:
+			continue
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (p *Pool) dial(ctx context.Context, cfg _config.Config, key string) (*PooledClient, error) {
+	c, err := p.newClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.StartContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pc := &PooledClient{
+		Client: c,
+		pool:   p,
+		key:    key,
+	}
+	pc.checkedOut.Store(true)
+
+	return pc, nil
+}
+
+// put Return a checked out client to the pool, or stop it if the
+// pool is closed, the client is disconnected, or enough are idle.
+func (p *Pool) put(c *PooledClient) error {
+	cfg := p.Config()
+
+	p.mutex.Lock()
+	host := p.host(c.key)
+	if p.closed || !c.IsConnected() || len(host.idle) >= cfg.MaxIdleConnectionsPerHost {
+		host.count--
+		host.release()
+		p.mutex.Unlock()
+		return c.Client.Stop()
+	}
+
+	c.SetIdleTimeout(int64(cfg.IdleConnectionTimeoutMs))
+	host.idle = append(host.idle, c)
+	host.release()
+	p.mutex.Unlock()
+
+	return nil
+}
+
+func (p *Pool) release(key string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	host := p.host(key)
+	host.count--
+	host.release()
+}
+
+// host Must be called while holding the mutex
+func (p *Pool) host(key string) *poolHost {
+	host, ok := p.hosts[key]
+	if !ok {
+		host = &poolHost{releaseChan: make(chan bool)}
+		p.hosts[key] = host
+	}
+	return host
+}
+
+func (p *Pool) isHealthy(c *PooledClient) bool {
+	if !c.IsConnected() {
+		return false
+	}
+
+	if !p.Config().HealthCheckOnCheckout {
+		return true
+	}
+
+	if checker, ok := c.Client.(interface{ IsAlive() bool }); ok {
+		return checker.IsAlive()
+	}
+
+	return true
+}
+
+func (p *Pool) pruneIdleConnections() {
+	for {
+		select {
+		case <-p.closeChan:
+			return
+		case <-time.After(500 * time.Millisecond):
+		}
+
+		var expired []*PooledClient
+
+		p.mutex.Lock()
+		for _, host := range p.hosts {
+			idle := host.idle[:0]
+			for _, c := range host.idle {
+				if c.IsIdle() {
+					expired = append(expired, c)
+					host.count--
+				} else {
+					idle = append(idle, c)
+				}
+			}
+			if len(idle) != len(host.idle) {
+				host.idle = idle
+				host.release()
+			}
+		}
+		p.mutex.Unlock()
+
+		for _, c := range expired {
+			_ = c.Client.Stop()
+		}
+	}
+}
+
+// release Must be called while holding the pool's mutex
+func (h *poolHost) release() {
+	close(h.releaseChan)
+	h.releaseChan = make(chan bool)
+}
+
+// poolKey Identifies the clients that can stand in for one created from cfg:
+// those connected to the same remote address from the same local address,
+// through the same proxy and with the same TLS and socket settings
+func poolKey(cfg _config.Config) string {
+	network := "tcp"
+	if cfg.Connectionless {
+		network = "udp"
+	}
+	return fmt.Sprintf("%s/%s:%d from %s via %s/%t/%d/%s tls %p options %+v",
+		network, cfg.RemoteAddress, cfg.RemotePort, cfg.LocalAddress,
+		cfg.DialProxy, cfg.DialProxyFromEnvironment, cfg.ProxyProtocolVersion, cfg.ProxySourceAddress,
+		cfg.TLSConfig, cfg.SocketOptions)
+}
+
+/*******************************************************************************
+ POOLED CLIENT
+*******************************************************************************/
+
+// PooledClient A client checked out of a Pool.  Calling Stop() returns it
+// to the pool rather than closing the connection; use Discard() to close
+// the connection instead (after an unrecoverable protocol error, etc).
+type PooledClient struct {
+	Client
+	comobj.DefaultIdleable
+
+	pool       *Pool
+	key        string
+	checkedOut atomic.Bool
+}
+
+func (c *PooledClient) Stop() error {
+	if !c.checkedOut.Swap(false) {
+		return nil
+	}
+	return c.pool.put(c)
+}
+
+func (c *PooledClient) Discard() error {
+	if !c.checkedOut.Swap(false) {
+		return nil
+	}
+	c.pool.release(c.key)
+	return c.Client.Stop()
+}
@@ -0,0 +1,388 @@
+package client
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+	_config "tonysoft.com/comm/internal/config/client"
+	"tonysoft.com/comm/pkg/comerr"
+)
+
+type reconnectState int
+
+const (
+	reconnectStopped reconnectState = iota
+	reconnectConnected
+	reconnectReconnecting
+	reconnectFailed
+)
+
+// ReconnectClient Wraps a client so that read/write errors trigger a
+// reconnection (with exponential backoff and jitter) rather than leaving
+// the client stopped.  Reads block while reconnecting, up to the read
+// timeout, and writes are either rejected with ErrClientReconnecting or
+// buffered and flushed once the connection has been re-established.
+type ReconnectClient struct {
+	Client
+	DefaultEventProducer
+
+	mutex       sync.Mutex
+	state       reconnectState
+	readyChan   chan bool // closed once reconnected
+	stopChan    chan bool // closed when the client is explicitly stopped
+	loopWg      sync.WaitGroup
+	writeBuffer [][]byte
+	bufferSize  int
+	random      *rand.Rand
+}
+
+func NewReconnectClient(c Client) *ReconnectClient {
+	return &ReconnectClient{
+		Client: c,
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (c *ReconnectClient) Start() error {
+	return c.StartContext(context.Background())
+}
+
+func (c *ReconnectClient) StartContext(ctx context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.state == reconnectConnected || c.state == reconnectReconnecting {
+		return comerr.ErrClientAlreadyConnected
+	}
+
+	err := c.Client.StartContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.ConfigureEvents(c.Config().EventChanBufferSize)
+	c.state = reconnectConnected
+	c.stopChan = make(chan bool)
+	c.writeBuffer = nil
+	c.bufferSize = 0
+
+	c.SendEvent(Event{Type: Connected})
+
+	return nil
+}
+
+func (c *ReconnectClient) Stop() error {
+	c.mutex.Lock()
+	state := c.state
+	if state != reconnectStopped {
+		c.state = reconnectStopped
+		close(c.stopChan)
+	}
+	c.writeBuffer = nil
+	c.bufferSize = 0
+	c.mutex.Unlock()
+
+	// Wait for any pending reconnection attempt to be abandoned
+	c.loopWg.Wait()
+
+	err := c.Client.Stop()
+
+	if state == reconnectConnected {
+		c.SendEvent(Event{Type: Disconnected})
+	}
+	c.CloseEvents()
+
+	return err
+}
+
+func (c *ReconnectClient) Events() <-chan Event {
+	return c.DefaultEventProducer.Events()
+}
+
+func (c *ReconnectClient) Read(buffer []byte) (int, error) {
+	return c.ReadContext(context.Background(), buffer)
+}
+
+func (c *ReconnectClient) ReadContext(ctx context.Context, buffer []byte) (int, error) {
+	ready, err := c.awaitReady(ctx)
+	if err != nil {
+		return -1, err
+	}
+	if !ready {
+		return 0, nil
+	}
+
+	count, err := c.Client.ReadContext(ctx, buffer)
+	if err != nil && ctx.Err() == nil {
+		c.reconnect(err)
+		if count < 0 {
+			count = 0
+		}
+		return count, nil
+	}
+
+	return count, err
+}
+
+func (c *ReconnectClient) Write(data []byte) (int, error) {
+	return c.WriteContext(context.Background(), data)
+}
+
+func (c *ReconnectClient) WriteContext(ctx context.Context, data []byte) (int, error) {
+	c.mutex.Lock()
+	switch c.state {
+	case reconnectStopped:
+		c.mutex.Unlock()
+		return -1, net.ErrClosed
+	case reconnectFailed:
+		c.mutex.Unlock()
+		return -1, comerr.ErrReconnectFailed
+	case reconnectReconnecting:
+		defer c.mutex.Unlock()
+		return c.bufferWrite(data)
+	}
+	c.mutex.Unlock()
+
+	count, err := c.Client.WriteContext(ctx, data)
+	if err != nil && ctx.Err() == nil {
+		c.reconnect(err)
+
+		if count < 0 {
+			count = 0
+		}
+
+		n, e := c.WriteContext(ctx, data[count:])
+		if n > 0 {
+			count += n
+		}
+		return count, e
+	}
+
+	return count, err
+}
+
+// bufferWrite Must be called while holding the mutex
+func (c *ReconnectClient) bufferWrite(data []byte) (int, error) {
+	cfg := c.Config()
+
+	if !cfg.ReconnectBufferWrites {
+		return 0, comerr.ErrClientReconnecting
+	}
+
+	if c.bufferSize+len(data) > cfg.ReconnectBufferSize {
+		return 0, comerr.ErrReconnectBufferFull
+	}
+
+	dataCopy := make([]byte, len(data))
+	copy(dataCopy, data)
+	c.writeBuffer = append(c.writeBuffer, dataCopy)
+	c.bufferSize += len(dataCopy)
+
+	return len(data), nil
+}
+
+// awaitReady Returns true if the client is connected, waiting up to the
+// read timeout for a pending reconnection to complete.
+func (c *ReconnectClient) awaitReady(ctx context.Context) (bool, error) {
+	timeout := time.After(time.Duration(c.Config().ReadTimeoutUs) * time.Microsecond)
+
+	for {
+		c.mutex.Lock()
+		state, readyChan, stopChan := c.state, c.readyChan, c.stopChan
+		c.mutex.Unlock()
+
+		switch state {
+		case reconnectStopped:
+			return false, net.ErrClosed
+		case reconnectFailed:
+			return false, comerr.ErrReconnectFailed
+		case reconnectConnected:
+			return true, nil
+		}
+
+		select {
+		case <-readyChan:
+			continue
+		case <-stopChan:
+			return false, net.ErrClosed
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-timeout:
+			return false, nil
+		}
+	}
+}
+
+func (c *ReconnectClient) reconnect(cause error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.state != reconnectConnected {
+		return
+	}
+
+	c.state = reconnectReconnecting
+	c.readyChan = make(chan bool)
+
+	c.SendEvent(Event{Type: Disconnected, Err: cause})
+
+	c.loopWg.Add(1)
+	go c.reconnectLoop(c.stopChan, c.readyChan)
+}
+
+func (c *ReconnectClient) reconnectLoop(stopChan chan bool, readyChan chan bool) {
+	defer c.loopWg.Done()
+
+	cfg := c.Config()
+
+	_ = c.Client.Stop()
+
+	var err error
+	for attempt := 1; cfg.ReconnectMaxAttempts < 1 || attempt <= cfg.ReconnectMaxAttempts; attempt++ {
+		delay := c.backoff(cfg, attempt)
+		c.SendEvent(Event{Type: Reconnecting, Attempt: attempt, Delay: delay, Err: err})
+
+		select {
+		case <-stopChan:
+			return
+		case <-time.After(delay):
+		}
+
+		err = c.startOrAbort(stopChan)
+		if err != nil {
+			continue
+		}
+
+		// Writes buffered while flushing are flushed in turn
+		c.mutex.Lock()
+		for err == nil && c.state == reconnectReconnecting && len(c.writeBuffer) > 0 {
+			c.mutex.Unlock()
+			err = c.flushWrites(stopChan)
+			c.mutex.Lock()
+		}
+
+		if c.state != reconnectReconnecting {
+			c.mutex.Unlock()
+			return
+		}
+
+		if err != nil {
+			c.mutex.Unlock()
+			_ = c.Client.Stop()
+			continue
+		}
+
+		c.state = reconnectConnected
+		close(readyChan)
+		c.mutex.Unlock()
+
+		c.SendEvent(Event{Type: Reconnected, Attempt: attempt})
+		return
+	}
+
+	c.mutex.Lock()
+	if c.state == reconnectReconnecting {
+		c.state = reconnectFailed
+		c.writeBuffer = nil
+		c.bufferSize = 0
+		close(readyChan)
+	}
+	c.mutex.Unlock()
+
+	c.SendEvent(Event{Type: ReconnectFailed, Err: err})
+}
+
+// startOrAbort Restarts the wrapped client, aborting the attempt if the
+// client is stopped while the connection is being established.
+func (c *ReconnectClient) startOrAbort(stopChan chan bool) error {
+	ctx, cancel := stopContext(stopChan)
+	defer cancel()
+
+	return c.Client.StartContext(ctx)
+}
+
+// flushWrites Sends the writes buffered so far without holding the mutex,
+// so that a stalled peer does not block the other calls, aborting if the
+// client is stopped.  Writes left unsent are put back ahead of any buffered
+// in the meantime.
+func (c *ReconnectClient) flushWrites(stopChan chan bool) error {
+	ctx, cancel := stopContext(stopChan)
+	defer cancel()
+
+	c.mutex.Lock()
+	pending := c.writeBuffer
+	c.writeBuffer = nil
+	c.mutex.Unlock()
+
+	var err error
+	for len(pending) > 0 {
+		var count int
+		count, err = c.Client.WriteContext(ctx, pending[0])
+		if count > 0 {
+			pending[0] = pending[0][count:]
+			c.mutex.Lock()
+			if c.state == reconnectReconnecting {
+				c.bufferSize -= count
+			}
+			c.mutex.Unlock()
+		}
+		if err != nil {
+			break
+		}
+
+		if len(pending[0]) == 0 {
+			pending = pending[1:]
+		}
+	}
+
+	c.mutex.Lock()
+	if c.state == reconnectReconnecting && len(pending) > 0 {
+		c.writeBuffer = append(pending, c.writeBuffer...)
+	}
+	if len(c.writeBuffer) == 0 {
+		c.writeBuffer = nil
+		c.bufferSize = 0
+	}
+	c.mutex.Unlock()
+
+	return err
+}
+
+// stopContext Returns a context cancelled once stopChan is closed
+func stopContext(stopChan chan bool) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		select {
+		case <-stopChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, cancel
+}
+
+func (c *ReconnectClient) backoff(cfg _config.Config, attempt int) time.Duration {
+	delay := float64(cfg.ReconnectMinBackoffMs)
+	for i := 1; i < attempt && delay < float64(cfg.ReconnectMaxBackoffMs); i++ {
+		delay *= 2
+	}
+	if delay > float64(cfg.ReconnectMaxBackoffMs) {
+		delay = float64(cfg.ReconnectMaxBackoffMs)
+	}
+
+	jitter := cfg.ReconnectJitter
+	if jitter > 1 {
+		jitter = 1
+	} else if jitter < 0 {
+		jitter = 0
+	}
+
+	// Spread the delay evenly across [delay - jitter*delay, delay + jitter*delay]
+	delay += delay * jitter * (2*c.random.Float64() - 1)
+
+	return time.Duration(delay) * time.Millisecond
+}
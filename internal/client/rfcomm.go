@@ -3,6 +3,7 @@
 package client
 
 import (
+	"context"
 	"fmt"
 	"golang.org/x/sys/unix"
 	"net"
@@ -20,6 +21,10 @@ type RfcommClient struct {
 }
 
 func (c *RfcommClient) Start() error {
+	return c.StartContext(context.Background())
+}
+
+func (c *RfcommClient) StartContext(ctx context.Context) error {
 	c.conn = -1
 
 	cfg := c.Config()
@@ -44,75 +49,27 @@ func (c *RfcommClient) Start() error {
 
 	rfcomm.RestartBluetooth()
 
+	if err = ctx.Err(); err != nil {
+		_ = unix.Close(sock)
+		return err
+	}
+
+	// Connecting in non-blocking mode lets the attempt be aborted by the context
+	err = unix.SetNonblock(sock, true)
+	if err != nil {
+		_ = unix.Close(sock)
+		return fmt.Errorf("%w : %v", comerr.ErrSetNonBlockingMode, err)
+	}
+
 	err = unix.Connect(sock, sockInfo)
 
 	// See https://man7.org/linux/man-pages/man2/connect.2.html for the rationale behind this.
 	switch err {
 	case unix.EINPROGRESS, unix.EAGAIN, unix.EINTR:
-		abortChan := make(chan bool)
-		errChan := make(chan error)
-
-		go func() {
-			for {
-				select {
-				case <-abortChan:
-					return
-				case <-time.After(time.Second):
-					fdset := unix.FdSet{}
-					fdset.Set(sock)
-					tv := unix.Timeval{Sec: int64(cfg.ConnectTimeoutSec), Usec: 0}
-
-					n, selErr := unix.Select(sock+1, nil, &fdset, nil, &tv)
-
-					if selErr == nil && n > 0 {
-						optStr, getSockOptStrErr := unix.GetsockoptString(sock, unix.SOL_SOCKET, unix.SO_ERROR)
-
-						if getSockOptStrErr == unix.EINTR {
-							continue
-						}
-
-						optStrBytes := []byte(optStr)
-						connected := len(optStrBytes) > 0
-						for _, b := range optStrBytes {
-							if b != 0 {
-								connected = false
-							}
-						}
-						connected = connected && err == nil
-
-						if connected {
-							errChan <- nil
-							return
-						} else {
-							if err != nil {
-								errChan <- err
-							} else {
-								errChan <- comerr.ErrConnectAborted
-							}
-							return
-						}
-					} else if err == unix.EINTR {
-						continue
-					} else if err != nil {
-						errChan <- err
-						return
-					}
-				}
-			}
-		}()
-
-		select {
-		case e := <-errChan:
-			if e != nil {
-				_ = unix.Close(sock)
-				return e
-			} else {
-				break
-			}
-		case <-time.After(time.Duration(cfg.ConnectTimeoutSec) * time.Second):
-			abortChan <- true
+		err = c.awaitConnect(ctx, sock, time.Duration(cfg.ConnectTimeoutSec)*time.Second)
+		if err != nil {
 			_ = unix.Close(sock)
-			return comerr.ErrConnectTimeout
+			return err
 		}
 	case nil:
 		break
@@ -122,13 +79,76 @@ func (c *RfcommClient) Start() error {
 	}
 
 	c.conn = sock
-	c.SetIsConnected(true)
+	c.setConnected()
 
 	return c.setConnectionOptions()
 }
 
+// awaitConnect Polls the socket until the pending connection attempt completes,
+// the timeout elapses, or the context is done.  A pipe is included in the poll
+// set so that cancelling the context wakes the poll immediately.
+func (c *RfcommClient) awaitConnect(ctx context.Context, sock int, timeout time.Duration) error {
+	var wakeFds [2]int
+	err := unix.Pipe2(wakeFds[:], unix.O_NONBLOCK|unix.O_CLOEXEC)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = unix.Close(wakeFds[0])
+		_ = unix.Close(wakeFds[1])
+	}()
+
+	doneChan := make(chan bool)
+	defer close(doneChan)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_, _ = unix.Write(wakeFds[1], []byte{0})
+		case <-doneChan:
+		}
+	}()
+
+	deadline := time.Now().Add(timeout)
+	for {
+		remainingMs := int(time.Until(deadline).Milliseconds())
+		if remainingMs <= 0 {
+			return comerr.ErrConnectTimeout
+		}
+
+		fds := []unix.PollFd{
+			{Fd: int32(sock), Events: unix.POLLOUT},
+			{Fd: int32(wakeFds[0]), Events: unix.POLLIN},
+		}
+
+		_, err = unix.Poll(fds, remainingMs)
+		if err == unix.EINTR {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		if fds[0].Revents == 0 {
+			continue
+		}
+
+		soErr, err := unix.GetsockoptInt(sock, unix.SOL_SOCKET, unix.SO_ERROR)
+		if err != nil {
+			return err
+		}
+		if soErr != 0 {
+			return fmt.Errorf("%w : %v", comerr.ErrConnectAborted, unix.Errno(soErr))
+		}
+
+		return nil
+	}
+}
+
 func (c *RfcommClient) Stop() error {
-	defer c.SetIsConnected(false)
+	defer c.setDisconnected()
 
 	if c.conn == -1 {
 		return nil
@@ -149,11 +169,41 @@ func (c *RfcommClient) Stop() error {
 	}
 }
 
+func (c *RfcommClient) LocalAddr() net.Addr {
+	if c.conn < 0 {
+		return nil
+	}
+	if addr := rfcomm.LocalAddr(c.conn); addr != nil {
+		return addr
+	}
+	return nil
+}
+
+func (c *RfcommClient) RemoteAddr() net.Addr {
+	if c.conn < 0 {
+		return nil
+	}
+	if addr := rfcomm.RemoteAddr(c.conn); addr != nil {
+		return addr
+	}
+	return nil
+}
+
 func (c *RfcommClient) Read(buffer []byte) (int, error) {
+	return c.ReadContext(context.Background(), buffer)
+}
+
+// ReadContext The socket is in non-blocking mode, so the context
+// only needs to be checked before the read is attempted.
+func (c *RfcommClient) ReadContext(ctx context.Context, buffer []byte) (int, error) {
 	if c.conn < 0 {
 		return -1, net.ErrClosed
 	}
 
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+
 	count, err := unix.Read(c.conn, buffer)
 	err = socket.SinkReadWriteError(err)
 	if err != nil {
@@ -164,10 +214,20 @@ func (c *RfcommClient) Read(buffer []byte) (int, error) {
 }
 
 func (c *RfcommClient) Write(data []byte) (int, error) {
+	return c.WriteContext(context.Background(), data)
+}
+
+// WriteContext The socket is in non-blocking mode, so the context
+// only needs to be checked before the write is attempted.
+func (c *RfcommClient) WriteContext(ctx context.Context, data []byte) (int, error) {
 	if c.conn < 0 {
 		return -1, net.ErrClosed
 	}
 
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+
 	count, err := unix.Write(c.conn, data)
 	err = socket.SinkReadWriteError(err)
 	if err != nil {
@@ -177,6 +237,14 @@ func (c *RfcommClient) Write(data []byte) (int, error) {
 	return count, err
 }
 
+// IsAlive Used by Pool to verify an idle connection before reusing it
+func (c *RfcommClient) IsAlive() bool {
+	if c.conn < 0 {
+		return false
+	}
+	return socket.IsAlive(c.conn)
+}
+
 func (c *RfcommClient) setConnectionOptions() error {
 	if c.conn < 0 {
 		return net.ErrClosed
@@ -206,5 +274,11 @@ func (c *RfcommClient) setConnectionOptions() error {
 		return fmt.Errorf("%w : %v", comerr.ErrSetLingerTimeout, err)
 	}
 
+	err = c.Config().SocketOptions.Set(c.conn, "rfcomm")
+	if err != nil {
+		_ = c.Stop()
+		return err
+	}
+
 	return nil
 }
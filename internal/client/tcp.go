@@ -1,10 +1,15 @@
 package client
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"net"
 	"strconv"
+	"sync"
 	"time"
+	"tonysoft.com/comm/internal/dialproxy"
+	"tonysoft.com/comm/internal/proxyproto"
 	"tonysoft.com/comm/internal/socket"
 	"tonysoft.com/comm/pkg/comerr"
 )
@@ -12,10 +17,16 @@ import (
 type TcpClient struct {
 	BaseClient
 	conn          *net.TCPConn
+	stream        io.ReadWriter // conn, or the protocol layered over it (see WebSocketClient)
 	readTimeoutUs int
+	stopMutex     sync.Mutex // Stop may be called by a failed Read while the owner stops the client
 }
 
 func (c *TcpClient) Start() error {
+	return c.StartContext(context.Background())
+}
+
+func (c *TcpClient) StartContext(ctx context.Context) error {
 	if c.IsConnected() {
 		return comerr.ErrClientAlreadyConnected
 	}
@@ -24,45 +35,157 @@ func (c *TcpClient) Start() error {
 
 	c.readTimeoutUs = cfg.ReadTimeoutUs
 
-	remoteAddr := cfg.RemoteAddress + ":" + strconv.FormatUint(uint64(cfg.RemotePort), 10)
-	addr, err := net.ResolveTCPAddr("tcp", remoteAddr)
+	tcpConn, err := c.dial(ctx, cfg.RemoteAddress, cfg.RemotePort)
 	if err != nil {
 		return err
 	}
 
-	dialer := net.Dialer{Timeout: time.Duration(cfg.ConnectTimeoutSec) * time.Second}
-	tcpConn, err := dialer.Dial("tcp4", addr.String())
+	c.conn = tcpConn
+	c.stream = tcpConn
+	c.setConnected()
+
+	err = c.setConnectionOptions()
 	if err != nil {
 		return err
 	}
 
-	c.conn = tcpConn.(*net.TCPConn)
-	c.SetIsConnected(true)
+	if cfg.ProxyProtocolVersion > 0 {
+		err = c.writeProxyHeader(cfg.ProxyProtocolVersion, cfg.ProxySourceAddress)
+		if err != nil {
+			_ = c.Stop()
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dial Connects to host:port, through the proxy if any (see DialProxy)
+func (c *TcpClient) dial(ctx context.Context, host string, port uint16) (*net.TCPConn, error) {
+	cfg := c.Config()
+
+	localAddr, err := c.localAddr(false)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := net.Dialer{
+		Timeout:   time.Duration(cfg.ConnectTimeoutSec) * time.Second,
+		LocalAddr: localAddr,
+		Control:   cfg.SocketOptions.Control(nil),
+	}
+
+	proxyURL, err := c.dialProxy(host)
+	if err != nil {
+		return nil, err
+	}
+
+	var tcpConn net.Conn
+	if proxyURL != nil {
+		remoteAddr := net.JoinHostPort(host, strconv.FormatUint(uint64(port), 10))
+		tcpConn, err = dialproxy.Dial(ctx, proxyURL, dialer, remoteAddr)
+	} else if c.resolvesHostname(host) {
+		tcpConn, err = c.dialHostname(ctx, dialer, "tcp", host, port)
+	} else {
+		remoteAddr := host + ":" + strconv.FormatUint(uint64(port), 10)
+		addr, resolveErr := net.ResolveTCPAddr("tcp", remoteAddr)
+		if resolveErr != nil {
+			return nil, resolveErr
+		}
+		tcpConn, err = dialer.DialContext(ctx, "tcp4", addr.String())
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	return c.setConnectionOptions()
+	return tcpConn.(*net.TCPConn), nil
+}
+
+// writeProxyHeader Send a PROXY protocol header ahead of any data, for when
+// the client is proxying a connection to a server that expects one
+func (c *TcpClient) writeProxyHeader(version int, sourceAddress string) error {
+	header := &proxyproto.Header{
+		Version:     version,
+		Command:     proxyproto.Proxy,
+		Source:      c.conn.LocalAddr().(*net.TCPAddr),
+		Destination: c.conn.RemoteAddr().(*net.TCPAddr),
+	}
+
+	if sourceAddress != "" {
+		addr, err := net.ResolveTCPAddr("tcp", sourceAddress)
+		if err != nil {
+			return err
+		}
+		header.Source = addr
+	}
+
+	headerBytes, err := header.Bytes()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.conn.Write(headerBytes)
+	return err
 }
 
 func (c *TcpClient) Stop() error {
-	defer c.SetIsConnected(false)
+	c.stopMutex.Lock()
+	defer c.stopMutex.Unlock()
+	return c.stop()
+}
+
+func (c *TcpClient) stop() error {
+	defer c.setDisconnected()
 	if c.conn != nil {
 		err := c.conn.Close()
 		c.conn = nil
+		c.stream = nil
 		return err
 	}
 	return nil
 }
 
+func (c *TcpClient) LocalAddr() net.Addr {
+	conn := c.conn
+	if conn == nil {
+		return nil
+	}
+	return conn.LocalAddr()
+}
+
+func (c *TcpClient) RemoteAddr() net.Addr {
+	conn := c.conn
+	if conn == nil {
+		return nil
+	}
+	return conn.RemoteAddr()
+}
+
 func (c *TcpClient) Read(buffer []byte) (int, error) {
-	if c.conn == nil {
+	return c.ReadContext(context.Background(), buffer)
+}
+
+func (c *TcpClient) ReadContext(ctx context.Context, buffer []byte) (int, error) {
+	conn, stream := c.conn, c.stream
+	if conn == nil || stream == nil {
 		return -1, net.ErrClosed
 	}
 
-	err := c.conn.SetReadDeadline(time.Now().Add(time.Duration(c.readTimeoutUs) * time.Microsecond))
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+
+	err := conn.SetReadDeadline(time.Now().Add(time.Duration(c.readTimeoutUs) * time.Microsecond))
 	if err != nil {
 		return -1, fmt.Errorf("%w : %v", comerr.ErrSetReadTimeout, err)
 	}
 
-	count, err := c.conn.Read(buffer)
+	aborted := socket.AbortOnDone(ctx, conn.SetReadDeadline)
+	count, err := stream.Read(buffer)
+	if aborted() {
+		return count, ctx.Err()
+	}
+
 	err = socket.SinkReadWriteError(err)
 	if err != nil {
 		_ = c.Stop()
@@ -71,11 +194,26 @@ func (c *TcpClient) Read(buffer []byte) (int, error) {
 }
 
 func (c *TcpClient) Write(data []byte) (int, error) {
-	if c.conn == nil {
+	return c.WriteContext(context.Background(), data)
+}
+
+func (c *TcpClient) WriteContext(ctx context.Context, data []byte) (int, error) {
+	conn, stream := c.conn, c.stream
+	if conn == nil || stream == nil {
 		return -1, net.ErrClosed
 	}
 
-	count, err := c.conn.Write(data)
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+
+	aborted := socket.AbortOnDone(ctx, conn.SetWriteDeadline)
+	count, err := stream.Write(data)
+	if aborted() {
+		_ = conn.SetWriteDeadline(time.Time{})
+		return count, ctx.Err()
+	}
+
 	err = socket.SinkReadWriteError(err)
 	if err != nil {
 		_ = c.Stop()
@@ -83,6 +221,26 @@ func (c *TcpClient) Write(data []byte) (int, error) {
 	return count, err
 }
 
+// IsAlive Used by Pool to verify an idle connection before reusing it
+func (c *TcpClient) IsAlive() bool {
+	conn := c.conn
+	if conn == nil {
+		return false
+	}
+
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return false
+	}
+
+	alive := false
+	err = rawConn.Control(func(fd uintptr) {
+		alive = socket.IsAlive(int(fd))
+	})
+
+	return err == nil && alive
+}
+
 func (c *TcpClient) setConnectionOptions() error {
 	if c.conn == nil {
 		return net.ErrClosed
@@ -93,5 +251,14 @@ func (c *TcpClient) setConnectionOptions() error {
 		_ = c.Stop()
 		return fmt.Errorf("%w : %v", comerr.ErrSetLingerTimeout, err)
 	}
+
+	// Applied again as Go sets TCP_NODELAY and keep-alive once connected
+	rawConn, err := c.conn.SyscallConn()
+	if err == nil {
+		err = c.Config().SocketOptions.Apply(rawConn, "tcp")
+	}
+	if err != nil {
+		_ = c.Stop()
+	}
 	return err
 }
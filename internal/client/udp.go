@@ -1,6 +1,7 @@
 package client
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"strconv"
@@ -16,6 +17,10 @@ type UdpClient struct {
 }
 
 func (c *UdpClient) Start() error {
+	return c.StartContext(context.Background())
+}
+
+func (c *UdpClient) StartContext(ctx context.Context) error {
 	if c.IsConnected() {
 		return comerr.ErrClientAlreadyConnected
 	}
@@ -24,26 +29,47 @@ func (c *UdpClient) Start() error {
 
 	c.readTimeoutUs = cfg.ReadTimeoutUs
 
-	remoteAddr := cfg.RemoteAddress + ":" + strconv.FormatUint(uint64(cfg.RemotePort), 10)
-	addr, err := net.ResolveUDPAddr("udp", remoteAddr)
+	multicastOptions := socket.MulticastOptions{
+		Interface: cfg.MulticastInterface,
+		TTL:       cfg.MulticastTTL,
+		Loopback:  cfg.MulticastLoopback,
+		Broadcast: cfg.Broadcast || socket.IsBroadcast(cfg.RemoteAddress),
+	}
+
+	localAddr, err := c.localAddr(true)
 	if err != nil {
 		return err
 	}
 
-	dialer := net.Dialer{Timeout: time.Duration(cfg.ConnectTimeoutSec) * time.Second}
-	udpConn, err := dialer.Dial("udp4", addr.String())
+	dialer := net.Dialer{
+		Timeout:   time.Duration(cfg.ConnectTimeoutSec) * time.Second,
+		LocalAddr: localAddr,
+		Control:   multicastOptions.Control(cfg.SocketOptions.Control(nil)),
+	}
+
+	var udpConn net.Conn
+	if c.resolvesHostname(cfg.RemoteAddress) {
+		udpConn, err = c.dialHostname(ctx, dialer, "udp", cfg.RemoteAddress, cfg.RemotePort)
+	} else {
+		remoteAddr := cfg.RemoteAddress + ":" + strconv.FormatUint(uint64(cfg.RemotePort), 10)
+		addr, resolveErr := net.ResolveUDPAddr("udp", remoteAddr)
+		if resolveErr != nil {
+			return resolveErr
+		}
+		udpConn, err = dialer.DialContext(ctx, "udp4", addr.String())
+	}
 	if err != nil {
 		return err
 	}
 
 	c.conn = udpConn.(*net.UDPConn)
-	c.SetIsConnected(true)
+	c.setConnected()
 
 	return nil
 }
 
 func (c *UdpClient) Stop() error {
-	defer c.SetIsConnected(false)
+	defer c.setDisconnected()
 	if c.conn != nil {
 		err := c.conn.Close()
 		c.conn = nil
@@ -52,17 +78,47 @@ func (c *UdpClient) Stop() error {
 	return nil
 }
 
+func (c *UdpClient) LocalAddr() net.Addr {
+	conn := c.conn
+	if conn == nil {
+		return nil
+	}
+	return conn.LocalAddr()
+}
+
+func (c *UdpClient) RemoteAddr() net.Addr {
+	conn := c.conn
+	if conn == nil {
+		return nil
+	}
+	return conn.RemoteAddr()
+}
+
 func (c *UdpClient) Read(buffer []byte) (int, error) {
-	if c.conn == nil {
+	return c.ReadContext(context.Background(), buffer)
+}
+
+func (c *UdpClient) ReadContext(ctx context.Context, buffer []byte) (int, error) {
+	conn := c.conn
+	if conn == nil {
 		return -1, net.ErrClosed
 	}
 
-	err := c.conn.SetReadDeadline(time.Now().Add(time.Duration(c.readTimeoutUs) * time.Microsecond))
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+
+	err := conn.SetReadDeadline(time.Now().Add(time.Duration(c.readTimeoutUs) * time.Microsecond))
 	if err != nil {
 		return -1, fmt.Errorf("%w : %v", comerr.ErrSetReadTimeout, err)
 	}
 
-	count, err := c.conn.Read(buffer)
+	aborted := socket.AbortOnDone(ctx, conn.SetReadDeadline)
+	count, err := conn.Read(buffer)
+	if aborted() {
+		return count, ctx.Err()
+	}
+
 	err = socket.SinkReadWriteError(err)
 	if err != nil {
 		_ = c.Stop()
@@ -71,11 +127,56 @@ func (c *UdpClient) Read(buffer []byte) (int, error) {
 }
 
 func (c *UdpClient) Write(data []byte) (int, error) {
-	if c.conn == nil {
+	return c.WriteContext(context.Background(), data)
+}
+
+func (c *UdpClient) WriteContext(ctx context.Context, data []byte) (int, error) {
+	conn := c.conn
+	if conn == nil {
 		return -1, net.ErrClosed
 	}
 
-	count, err := c.conn.Write(data)
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+
+	aborted := socket.AbortOnDone(ctx, conn.SetWriteDeadline)
+	count, err := conn.Write(data)
+	if aborted() {
+		_ = conn.SetWriteDeadline(time.Time{})
+		return count, ctx.Err()
+	}
+
+	err = socket.SinkReadWriteError(err)
+	if err != nil {
+		_ = c.Stop()
+	}
+	return count, err
+}
+
+// WriteBatch Write each element of datagrams as its own datagram using as
+// few system calls as possible (sendmmsg), returning the number written.
+func (c *UdpClient) WriteBatch(datagrams [][]byte) (int, error) {
+	conn := c.conn
+	if conn == nil {
+		return 0, net.ErrClosed
+	}
+
+	if len(datagrams) == 0 {
+		return 0, nil
+	}
+
+	batchConn, err := socket.NewBatchWriter(conn, len(datagrams))
+	if err != nil {
+		return 0, err
+	}
+
+	batch := make([]socket.Datagram, len(datagrams))
+	for i, data := range datagrams {
+		batch[i].Data = data
+	}
+
+	count, err := batchConn.WriteBatch(batch)
 	err = socket.SinkReadWriteError(err)
 	if err != nil {
 		_ = c.Stop()
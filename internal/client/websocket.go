@@ -0,0 +1,125 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"time"
+	"tonysoft.com/comm/internal/socket"
+	"tonysoft.com/comm/internal/transport"
+	"tonysoft.com/comm/internal/websocket"
+	"tonysoft.com/comm/pkg/comerr"
+)
+
+// WebSocketClient A TcpClient that connects to a ws:// or wss:// address,
+// each Write being sent as one binary message (see websocket.Conn)
+type WebSocketClient struct {
+	TcpClient
+	ws *websocket.Conn
+}
+
+func (c *WebSocketClient) Start() error {
+	return c.StartContext(context.Background())
+}
+
+func (c *WebSocketClient) StartContext(ctx context.Context) error {
+	if c.IsConnected() {
+		return comerr.ErrClientAlreadyConnected
+	}
+
+	cfg := c.Config()
+
+	c.readTimeoutUs = cfg.ReadTimeoutUs
+
+	wsURL, err := transport.ParseWebSocketAddress(cfg.RemoteAddress, cfg.RemotePort)
+	if err != nil {
+		return err
+	}
+
+	tcpConn, err := c.dial(ctx, wsURL.Host, wsURL.Port)
+	if err != nil {
+		return err
+	}
+	c.conn = tcpConn
+
+	err = c.setConnectionOptions()
+	if err != nil {
+		return err
+	}
+
+	ws, err := c.handshake(ctx, tcpConn, wsURL)
+	if err != nil {
+		_ = tcpConn.Close()
+		c.conn = nil
+		return err
+	}
+
+	c.ws = ws
+	c.stream = ws
+	c.setConnected()
+
+	return nil
+}
+
+// handshake Performs the TLS (for wss://) and WebSocket handshakes within
+// ConnectTimeoutSec
+func (c *WebSocketClient) handshake(ctx context.Context, tcpConn *net.TCPConn, wsURL transport.WebSocketURL) (*websocket.Conn, error) {
+	cfg := c.Config()
+
+	if cfg.ConnectTimeoutSec > 0 {
+		err := tcpConn.SetDeadline(time.Now().Add(time.Duration(cfg.ConnectTimeoutSec) * time.Second))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	aborted := socket.AbortOnDone(ctx, tcpConn.SetDeadline)
+
+	var conn net.Conn = tcpConn
+	var err error
+	if wsURL.Secure {
+		tlsConfig := &tls.Config{}
+		if cfg.TLSConfig != nil {
+			tlsConfig = cfg.TLSConfig.Clone()
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = wsURL.Host
+		}
+
+		tlsConn := tls.Client(tcpConn, tlsConfig)
+		err = tlsConn.Handshake()
+		conn = tlsConn
+	}
+
+	var ws *websocket.Conn
+	if err == nil {
+		ws, err = websocket.Dial(conn, wsURL.HostPort(), wsURL.Path)
+	}
+
+	if aborted() {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return ws, tcpConn.SetDeadline(time.Time{})
+}
+
+// Stop Starts the WebSocket close handshake before closing the connection
+func (c *WebSocketClient) Stop() error {
+	c.stopMutex.Lock()
+	defer c.stopMutex.Unlock()
+
+	ws := c.ws
+	c.ws = nil
+	if ws == nil || c.conn == nil {
+		return c.stop()
+	}
+
+	defer c.setDisconnected()
+	err := ws.Close()
+	c.conn = nil
+	c.stream = nil
+	return err
+}
@@ -4,13 +4,17 @@ import (
 	"sync/atomic"
 )
 
-// Runnable Read-only interface for objects that maintain running state
+// Runnable Read-only interface for objects that maintain running state.
+// IsStopping returns true while the object is shutting down gracefully,
+// during which time IsRunning also returns true.
 type Runnable interface {
 	IsRunning() bool
+	IsStopping() bool
 }
 
 type DefaultRunnable struct {
-	isRunning atomic.Bool
+	isRunning  atomic.Bool
+	isStopping atomic.Bool
 }
 
 func (r *DefaultRunnable) IsRunning() bool {
@@ -20,3 +24,11 @@ func (r *DefaultRunnable) IsRunning() bool {
 func (r *DefaultRunnable) SetIsRunning(isRunning bool) {
 	r.isRunning.Store(isRunning)
 }
+
+func (r *DefaultRunnable) IsStopping() bool {
+	return r.isStopping.Load()
+}
+
+func (r *DefaultRunnable) SetIsStopping(isStopping bool) {
+	r.isStopping.Store(isStopping)
+}
@@ -1,26 +1,98 @@
 package client
 
+import (
+	"crypto/tls"
+	"tonysoft.com/comm/internal/socket"
+	"tonysoft.com/comm/internal/transport"
+)
+
 const (
-	defaultConnectTimeoutSec = 30      // how long to wait for the server to answer
-	defaultReadTimeoutUs     = 1000000 // <600 is essentially non-blocking
-	defaultConnectionless    = false   // if true uses UDP instead of TCP
+	defaultConnectTimeoutSec     = 30      // how long to wait for the server to answer
+	defaultReadTimeoutUs         = 1000000 // <600 is essentially non-blocking
+	defaultConnectionless        = false   // if true uses UDP instead of TCP
+	defaultEventChanBufferSize   = 100     // event count
+	defaultAutoReconnect         = false   // if true reconnects after a read/write error instead of stopping
+	defaultReconnectMaxAttempts  = 10      // <1 means unlimited
+	defaultReconnectMinBackoffMs = 100     // delay before the first reconnection attempt
+	defaultReconnectMaxBackoffMs = 30000   // the delay doubles with each attempt up to this limit
+	defaultReconnectJitter       = 0.2     // fraction of the delay that is randomized, 0 to 1
+	defaultReconnectBufferWrites = false   // if true writes are buffered while reconnecting
+	defaultReconnectBufferSize   = 1048576 // byte count, writes exceeding this are rejected
+
+	defaultMulticastInterface = ""    // interface name, "" means the system default
+	defaultMulticastTTL       = 1     // 1 keeps multicast datagrams on the local network
+	defaultMulticastLoopback  = true  // if true multicast datagrams are also delivered locally
+	defaultBroadcast          = false // if true UDP datagrams may be sent to broadcast addresses
+
+	defaultProxyProtocolVersion = 0  // 1 or 2 sends a PROXY protocol header once connected, 0 sends none
+	defaultProxySourceAddress   = "" // host:port sent as the source address, "" means the local address
+
+	defaultLocalAddress = "" // source IP, IP:port or interface name (e.g., eth0), or mem:// address, "" means chosen by the system
+
+	defaultResolveHostnames = false // if true RemoteAddress may be a hostname, each of its addresses being tried in turn
+
+	defaultDialProxy                = ""    // socks5:// or http:// URL of the proxy TCP connections are made through, "" means none
+	defaultDialProxyFromEnvironment = false // if true and DialProxy is "" uses ALL_PROXY or HTTPS_PROXY, unless excluded by NO_PROXY
+
+	defaultMemoryLatencyMs = 0 // delay before data sent either way over a mem:// connection can be read
 )
 
 type Config struct {
-	RemoteAddress     string
-	RemotePort        uint16
-	ConnectTimeoutSec int
-	ReadTimeoutUs     int
-	Connectionless    bool
+	RemoteAddress            string
+	RemotePort               uint16
+	ConnectTimeoutSec        int
+	ReadTimeoutUs            int
+	Connectionless           bool
+	EventChanBufferSize      int
+	AutoReconnect            bool
+	ReconnectMaxAttempts     int
+	ReconnectMinBackoffMs    int
+	ReconnectMaxBackoffMs    int
+	ReconnectJitter          float64
+	ReconnectBufferWrites    bool
+	ReconnectBufferSize      int
+	MulticastInterface       string
+	MulticastTTL             int
+	MulticastLoopback        bool
+	Broadcast                bool
+	ProxyProtocolVersion     int
+	ProxySourceAddress       string
+	SocketOptions            socket.Options // applied when dialing, and once connected
+	LocalAddress             string
+	ResolveHostnames         bool
+	Resolver                 transport.Resolver // looks up hostnames, nil means a caching system resolver
+	DialProxy                string
+	DialProxyFromEnvironment bool
+	TLSConfig                *tls.Config // used for wss:// addresses, nil means the system's root CAs are trusted
+	MemoryLatencyMs          int
 }
 
 func NewConfig(remoteAddress string, remotePort uint16) Config {
 	cfg := Config{
-		RemoteAddress:     remoteAddress,
-		RemotePort:        remotePort,
-		ConnectTimeoutSec: defaultConnectTimeoutSec,
-		ReadTimeoutUs:     defaultReadTimeoutUs,
-		Connectionless:    defaultConnectionless,
+		RemoteAddress:            remoteAddress,
+		RemotePort:               remotePort,
+		ConnectTimeoutSec:        defaultConnectTimeoutSec,
+		ReadTimeoutUs:            defaultReadTimeoutUs,
+		Connectionless:           defaultConnectionless,
+		EventChanBufferSize:      defaultEventChanBufferSize,
+		AutoReconnect:            defaultAutoReconnect,
+		ReconnectMaxAttempts:     defaultReconnectMaxAttempts,
+		ReconnectMinBackoffMs:    defaultReconnectMinBackoffMs,
+		ReconnectMaxBackoffMs:    defaultReconnectMaxBackoffMs,
+		ReconnectJitter:          defaultReconnectJitter,
+		ReconnectBufferWrites:    defaultReconnectBufferWrites,
+		ReconnectBufferSize:      defaultReconnectBufferSize,
+		MulticastInterface:       defaultMulticastInterface,
+		MulticastTTL:             defaultMulticastTTL,
+		MulticastLoopback:        defaultMulticastLoopback,
+		Broadcast:                defaultBroadcast,
+		ProxyProtocolVersion:     defaultProxyProtocolVersion,
+		ProxySourceAddress:       defaultProxySourceAddress,
+		LocalAddress:             defaultLocalAddress,
+		ResolveHostnames:         defaultResolveHostnames,
+		DialProxy:                defaultDialProxy,
+		DialProxyFromEnvironment: defaultDialProxyFromEnvironment,
+		MemoryLatencyMs:          defaultMemoryLatencyMs,
 	}
 	return cfg
 }
@@ -4,11 +4,12 @@ import (
 	"sync/atomic"
 	"tonysoft.com/comm/internal/config/client"
 	"tonysoft.com/comm/internal/config/node"
+	"tonysoft.com/comm/internal/config/pool"
 	"tonysoft.com/comm/internal/config/server"
 )
 
 type Config interface {
-	client.Config | server.Config | node.Config
+	client.Config | server.Config | node.Config | pool.Config
 }
 
 type Configurable[T Config] interface {
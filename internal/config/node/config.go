@@ -1,5 +1,14 @@
 package node
 
+import (
+	"crypto/tls"
+	"net"
+	"tonysoft.com/comm/internal/ratelimit"
+	"tonysoft.com/comm/internal/socket"
+	"tonysoft.com/comm/internal/stream"
+	"tonysoft.com/comm/internal/transport"
+)
+
 const (
 	defaultIncomingConnectionLimit = -1      // <0 means 4096, 0 means none
 	defaultOutgoingConnectionLimit = -1      // <0 means 4096, 0 means none
@@ -11,35 +20,75 @@ const (
 	defaultReadBufferSize          = 1500    // byte count, should match transport MTU
 	defaultReadTimeoutUs           = 1000000 // <600 is essentially non-blocking
 	defaultSendMessageReceipts     = true    // Automatically send a receipt upon receiving a message
+
+	defaultRecvOverflowPolicy   = stream.Block      // applies to the Recv() channel
+	defaultStatusOverflowPolicy = stream.DropNewest // applies to the Status() channel
+	defaultHostConnectionLimit  = 0                 // incoming connections per remote IP, <1 means no limit
+	defaultSocketActivation     = false             // if true uses a socket passed by systemd (LISTEN_FDS)
+	defaultSocketActivationName = ""                // name of the socket (LISTEN_FDNAMES), "" means any
+	defaultInterface            = ""                // binds to the address of this interface instead of Address, "" means none
+	defaultResolveHostnames     = false             // if true Address and the addresses of other nodes may be hostnames
+
+	defaultDialProxy                = ""    // socks5:// or http:// URL of the proxy connections to other nodes are made through, "" means none
+	defaultDialProxyFromEnvironment = false // if true and DialProxy is "" uses ALL_PROXY or HTTPS_PROXY, unless excluded by NO_PROXY
+
+	defaultMemoryLatencyMs = 0 // delay before data sent either way between mem:// nodes can be read
 )
 
 type Config struct {
-	Address                 string
-	IncomingConnectionLimit int
-	OutgoingConnectionLimit int
-	ConnectTimeoutSec       int
-	IdleConnectionTimeoutMs int
-	ErrorChanBufferSize     int
-	RecvChanBufferSize      int
-	StatusChanBufferSize    int
-	ReadBufferSize          int
-	ReadTimeoutUs           int
-	SendMessageReceipts     bool
+	Address                  string
+	IncomingConnectionLimit  int
+	OutgoingConnectionLimit  int
+	ConnectTimeoutSec        int
+	IdleConnectionTimeoutMs  int
+	ErrorChanBufferSize      int
+	RecvChanBufferSize       int
+	StatusChanBufferSize     int
+	ReadBufferSize           int
+	ReadTimeoutUs            int
+	SendMessageReceipts      bool
+	RecvOverflowPolicy       stream.OverflowPolicy
+	StatusOverflowPolicy     stream.OverflowPolicy
+	HostConnectionLimit      int
+	AllowList                []string        // IPs or CIDRs of callers, empty means allow all that are not denied
+	DenyList                 []string        // IPs or CIDRs of callers, takes precedence over AllowList
+	PeerSendLimit            ratelimit.Limit // applies to Send() for each peer, the zero value means no limit
+	Listener                 net.Listener    // pre-opened listener used instead of binding Address, closed on Stop
+	SocketActivation         bool
+	SocketActivationName     string
+	SocketOptions            socket.Options // applied to the node's server and to its connections to other nodes
+	Interface                string
+	ResolveHostnames         bool
+	Resolver                 transport.Resolver // looks up hostnames, nil means a caching system resolver
+	DialProxy                string
+	DialProxyFromEnvironment bool
+	TLSConfig                *tls.Config // certificates for a wss:// Address, and the root CAs trusted when calling wss:// nodes
+	MemoryLatencyMs          int
 }
 
 func NewConfig(address string) Config {
 	cfg := Config{
-		Address:                 address,
-		IncomingConnectionLimit: defaultIncomingConnectionLimit,
-		OutgoingConnectionLimit: defaultOutgoingConnectionLimit,
-		ConnectTimeoutSec:       defaultConnectTimeoutSec,
-		IdleConnectionTimeoutMs: defaultIdleConnectionTimeoutMs,
-		ErrorChanBufferSize:     defaultErrorChanBufferSize,
-		RecvChanBufferSize:      defaultRecvChanBufferSize,
-		StatusChanBufferSize:    defaultStatusChanBufferSize,
-		ReadBufferSize:          defaultReadBufferSize,
-		ReadTimeoutUs:           defaultReadTimeoutUs,
-		SendMessageReceipts:     defaultSendMessageReceipts,
+		Address:                  address,
+		IncomingConnectionLimit:  defaultIncomingConnectionLimit,
+		OutgoingConnectionLimit:  defaultOutgoingConnectionLimit,
+		ConnectTimeoutSec:        defaultConnectTimeoutSec,
+		IdleConnectionTimeoutMs:  defaultIdleConnectionTimeoutMs,
+		ErrorChanBufferSize:      defaultErrorChanBufferSize,
+		RecvChanBufferSize:       defaultRecvChanBufferSize,
+		StatusChanBufferSize:     defaultStatusChanBufferSize,
+		ReadBufferSize:           defaultReadBufferSize,
+		ReadTimeoutUs:            defaultReadTimeoutUs,
+		SendMessageReceipts:      defaultSendMessageReceipts,
+		RecvOverflowPolicy:       defaultRecvOverflowPolicy,
+		StatusOverflowPolicy:     defaultStatusOverflowPolicy,
+		HostConnectionLimit:      defaultHostConnectionLimit,
+		SocketActivation:         defaultSocketActivation,
+		SocketActivationName:     defaultSocketActivationName,
+		Interface:                defaultInterface,
+		ResolveHostnames:         defaultResolveHostnames,
+		DialProxy:                defaultDialProxy,
+		DialProxyFromEnvironment: defaultDialProxyFromEnvironment,
+		MemoryLatencyMs:          defaultMemoryLatencyMs,
 	}
 	return cfg
 }
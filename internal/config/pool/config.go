@@ -0,0 +1,25 @@
+package pool
+
+const (
+	defaultMaxConnectionsPerHost     = 8     // <1 means unlimited
+	defaultMaxIdleConnectionsPerHost = 2     // warm connections kept per host, 0 means none
+	defaultIdleConnectionTimeoutMs   = 60000 // <1 means no idle connection eviction
+	defaultHealthCheckOnCheckout     = true  // verify idle connections are still alive before reuse
+)
+
+type Config struct {
+	MaxConnectionsPerHost     int
+	MaxIdleConnectionsPerHost int
+	IdleConnectionTimeoutMs   int
+	HealthCheckOnCheckout     bool
+}
+
+func NewConfig() Config {
+	cfg := Config{
+		MaxConnectionsPerHost:     defaultMaxConnectionsPerHost,
+		MaxIdleConnectionsPerHost: defaultMaxIdleConnectionsPerHost,
+		IdleConnectionTimeoutMs:   defaultIdleConnectionTimeoutMs,
+		HealthCheckOnCheckout:     defaultHealthCheckOnCheckout,
+	}
+	return cfg
+}
@@ -1,5 +1,15 @@
 package server
 
+import (
+	"crypto/tls"
+	"net"
+	"os"
+	"tonysoft.com/comm/internal/ratelimit"
+	"tonysoft.com/comm/internal/socket"
+	"tonysoft.com/comm/internal/stream"
+	"tonysoft.com/comm/internal/transport"
+)
+
 const (
 	defaultClientConnectionLimit   = -1      // <0 means 4096, 0 means none
 	defaultIdleConnectionTimeoutMs = 60000   // <1 means no idle connection pruning
@@ -7,6 +17,26 @@ const (
 	defaultReadBufferSize          = 1500    // byte count, should match transport MTU
 	defaultReadTimeoutUs           = 1000000 // <600 is essentially non-blocking
 	defaultConnectionless          = false   // if true uses UDP instead of TCP
+
+	defaultAcceptOverflowPolicy = stream.DropNewest // dropped connections are closed
+	defaultUdpSessions          = false             // if true datagrams are grouped by remote address
+	defaultUdpSessionQueueSize  = 64                // datagram count, per session
+	defaultMulticastInterface   = ""                // interface name, "" means the system default
+	defaultMulticastTTL         = 1                 // 1 keeps multicast datagrams on the local network
+	defaultMulticastLoopback    = true              // if true multicast datagrams are also delivered locally
+	defaultUdpBatchSize         = 1                 // datagrams read per system call, >1 uses recvmmsg
+	defaultEventLoop            = false             // if true TCP and RFCOMM connections are watched with epoll
+	defaultEventLoopWorkers     = 0                 // Serve handler Go routine count, <1 means one per CPU
+	defaultHostConnectionLimit  = 0                 // per remote IP (or MAC for RFCOMM), <1 means no limit
+	defaultProxyProtocol        = false             // if true TCP connections must start with a PROXY protocol header
+	defaultProxyHeaderTimeoutMs = 5000              // how long to wait for the PROXY protocol header
+	defaultSocketActivation     = false             // if true uses a socket passed by systemd (LISTEN_FDS)
+	defaultSocketActivationName = ""                // name of the socket (LISTEN_FDNAMES), "" means any
+	defaultListenerShards       = 1                 // TCP/UDP listeners bound with SO_REUSEPORT, each with its own loop
+	defaultInterface            = ""                // binds to the address of this interface instead of Address, "" means none
+	defaultResolveHostnames     = false             // if true Address may be a hostname, bound to its first address
+	defaultHandshakeTimeoutMs   = 5000              // how long to wait for the WebSocket (and TLS) handshake of ws:// and wss:// addresses
+	defaultMemoryLatencyMs      = 0                 // delay before data sent either way over a mem:// connection can be read
 )
 
 type Config struct {
@@ -18,6 +48,39 @@ type Config struct {
 	ReadBufferSize          int
 	ReadTimeoutUs           int
 	Connectionless          bool
+	AcceptOverflowPolicy    stream.OverflowPolicy
+	UdpSessions             bool
+	UdpSessionQueueSize     int
+	MulticastGroups         []string // joined in addition to Address, if Address is a multicast address
+	MulticastInterface      string
+	MulticastTTL            int
+	MulticastLoopback       bool
+	UdpBatchSize            int
+	EventLoop               bool
+	EventLoopWorkers        int
+	HostConnectionLimit     int
+	AllowList               []string        // IPs, CIDRs or MAC prefixes, empty means allow all that are not denied
+	DenyList                []string        // IPs, CIDRs or MAC prefixes, takes precedence over AllowList
+	ConnectionReadLimit     ratelimit.Limit // applies to each connection, the zero value means no limit
+	ConnectionWriteLimit    ratelimit.Limit // applies to each connection, the zero value means no limit
+	ServerReadLimit         ratelimit.Limit // shared by all connections, the zero value means no limit
+	ServerWriteLimit        ratelimit.Limit // shared by all connections, the zero value means no limit
+	ProxyProtocol           bool
+	ProxyHeaderTimeoutMs    int
+	TrustedProxies          []string       // IPs or CIDRs allowed to send PROXY protocol headers, required for ProxyProtocol
+	Listener                net.Listener   // pre-opened TCP listener used instead of binding, closed on Stop
+	PacketConn              net.PacketConn // pre-opened UDP socket used instead of binding, closed on Stop
+	ListenerFile            *os.File       // pre-opened TCP or UDP socket used instead of binding, duplicated on Start
+	SocketActivation        bool
+	SocketActivationName    string
+	ListenerShards          int
+	SocketOptions           socket.Options // applied to the listener and to accepted TCP and RFCOMM connections
+	Interface               string
+	ResolveHostnames        bool
+	Resolver                transport.Resolver // looks up hostnames, nil means a caching system resolver
+	HandshakeTimeoutMs      int
+	TLSConfig               *tls.Config // certificates for wss:// addresses
+	MemoryLatencyMs         int
 }
 
 func NewConfig(address string, port uint16) Config {
@@ -30,6 +93,25 @@ func NewConfig(address string, port uint16) Config {
 		ReadBufferSize:          defaultReadBufferSize,
 		ReadTimeoutUs:           defaultReadTimeoutUs,
 		Connectionless:          defaultConnectionless,
+		AcceptOverflowPolicy:    defaultAcceptOverflowPolicy,
+		UdpSessions:             defaultUdpSessions,
+		UdpSessionQueueSize:     defaultUdpSessionQueueSize,
+		MulticastInterface:      defaultMulticastInterface,
+		MulticastTTL:            defaultMulticastTTL,
+		MulticastLoopback:       defaultMulticastLoopback,
+		UdpBatchSize:            defaultUdpBatchSize,
+		EventLoop:               defaultEventLoop,
+		EventLoopWorkers:        defaultEventLoopWorkers,
+		HostConnectionLimit:     defaultHostConnectionLimit,
+		ProxyProtocol:           defaultProxyProtocol,
+		ProxyHeaderTimeoutMs:    defaultProxyHeaderTimeoutMs,
+		SocketActivation:        defaultSocketActivation,
+		SocketActivationName:    defaultSocketActivationName,
+		ListenerShards:          defaultListenerShards,
+		Interface:               defaultInterface,
+		ResolveHostnames:        defaultResolveHostnames,
+		HandshakeTimeoutMs:      defaultHandshakeTimeoutMs,
+		MemoryLatencyMs:         defaultMemoryLatencyMs,
 	}
 	return cfg
 }
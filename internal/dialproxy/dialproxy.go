@@ -0,0 +1,323 @@
+package dialproxy
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"tonysoft.com/comm/internal/socket"
+	"tonysoft.com/comm/pkg/comerr"
+)
+
+const (
+	socksVersion     = 0x05
+	socksNoAuth      = 0x00
+	socksUserPass    = 0x02
+	socksCmdConnect  = 0x01
+	socksIPv4        = 0x01
+	socksDomain      = 0x03
+	socksIPv6        = 0x04
+	socksSucceeded   = 0x00
+	userPassVersion  = 0x01
+	maxResponseBytes = 8192 // limit on the HTTP CONNECT response header
+)
+
+// Parse Parses a proxy URL, socks5://[user:password@]host[:port] (or
+// socks5h://, port 1080 by default) or http://[user:password@]host[:port]
+// (port 80 by default)
+func Parse(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w : %v", comerr.ErrDialProxy, err)
+	}
+
+	switch u.Scheme {
+	case "socks5", "socks5h", "http":
+	default:
+		return nil, fmt.Errorf("%w : unsupported scheme %q", comerr.ErrDialProxy, u.Scheme)
+	}
+
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("%w : no host in %q", comerr.ErrDialProxy, rawURL)
+	}
+
+	return u, nil
+}
+
+// FromEnvironment Returns the proxy set by ALL_PROXY or, failing that,
+// HTTPS_PROXY (or their lowercase forms), nil if neither is set or if host
+// is excluded by NO_PROXY
+func FromEnvironment(host string) (*url.URL, error) {
+	rawURL := getenv("ALL_PROXY")
+	if rawURL == "" {
+		rawURL = getenv("HTTPS_PROXY")
+	}
+	if rawURL == "" || excluded(host, getenv("NO_PROXY")) {
+		return nil, nil
+	}
+
+	// As with curl, a proxy without a scheme is an HTTP proxy
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+
+	return Parse(rawURL)
+}
+
+func getenv(name string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return os.Getenv(strings.ToLower(name))
+}
+
+// excluded Returns true if host matches an entry of noProxy, a comma
+// separated list of "*", hostnames (which match their subdomains too), IPs
+// and CIDRs
+func excluded(host string, noProxy string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	ip := net.ParseIP(host)
+
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if h, _, err := net.SplitHostPort(entry); err == nil {
+			entry = h
+		}
+
+		switch {
+		case entry == "":
+			continue
+		case entry == "*":
+			return true
+		case ip != nil:
+			if _, cidr, err := net.ParseCIDR(entry); err == nil && cidr.Contains(ip) {
+				return true
+			}
+			if ip.Equal(net.ParseIP(entry)) {
+				return true
+			}
+		default:
+			entry = strings.TrimSuffix(strings.TrimPrefix(entry, "."), ".")
+			if host == entry || strings.HasSuffix(host, "."+entry) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Dial Connects to address (host:port) through the proxy, dialing the proxy
+// with dialer, whose Timeout also bounds the proxy's handshake.  Hostnames
+// are resolved by the proxy.
+func Dial(ctx context.Context, proxyURL *url.URL, dialer net.Dialer, address string) (net.Conn, error) {
+	if dialer.Timeout > 0 {
+		dialer.Deadline = time.Now().Add(dialer.Timeout)
+	}
+
+	port := proxyURL.Port()
+	if port == "" {
+		port = "1080"
+		if proxyURL.Scheme == "http" {
+			port = "80"
+		}
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(proxyURL.Hostname(), port))
+	if err != nil {
+		return nil, err
+	}
+
+	err = conn.SetDeadline(dialer.Deadline)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	aborted := socket.AbortOnDone(ctx, conn.SetDeadline)
+	if proxyURL.Scheme == "http" {
+		err = httpConnect(conn, proxyURL.User, address)
+	} else {
+		err = socksConnect(conn, proxyURL.User, address)
+	}
+	if aborted() {
+		err = ctx.Err()
+	}
+
+	if err == nil {
+		err = conn.SetDeadline(time.Time{})
+	}
+
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// socksConnect Performs a SOCKS5 (RFC 1928) CONNECT handshake, with
+// username/password authentication (RFC 1929) if user is set
+func socksConnect(conn net.Conn, user *url.Userinfo, address string) error {
+	host, portPart, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(portPart, 10, 16)
+	if err != nil {
+		return err
+	}
+
+	greeting := []byte{socksVersion, 1, socksNoAuth}
+	if user != nil {
+		greeting = []byte{socksVersion, 2, socksNoAuth, socksUserPass}
+	}
+	if _, err = conn.Write(greeting); err != nil {
+		return err
+	}
+
+	reply := make([]byte, 2)
+	if _, err = io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != socksVersion {
+		return fmt.Errorf("%w : not a SOCKS5 proxy", comerr.ErrDialProxy)
+	}
+
+	switch reply[1] {
+	case socksNoAuth:
+	case socksUserPass:
+		if user == nil {
+			return fmt.Errorf("%w : proxy requires authentication", comerr.ErrDialProxy)
+		}
+		if err = socksAuthenticate(conn, user); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w : no acceptable authentication method", comerr.ErrDialProxy)
+	}
+
+	request := []byte{socksVersion, socksCmdConnect, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return fmt.Errorf("%w : hostname too long", comerr.ErrDialProxy)
+		}
+		request = append(request, socksDomain, byte(len(host)))
+		request = append(request, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		request = append(request, socksIPv4)
+		request = append(request, ip4...)
+	} else {
+		request = append(request, socksIPv6)
+		request = append(request, ip.To16()...)
+	}
+	request = binary.BigEndian.AppendUint16(request, uint16(port))
+
+	if _, err = conn.Write(request); err != nil {
+		return err
+	}
+
+	// VER REP RSV ATYP, then the bound address and port, which are not used
+	response := make([]byte, 4)
+	if _, err = io.ReadFull(conn, response); err != nil {
+		return err
+	}
+	if response[1] != socksSucceeded {
+		return fmt.Errorf("%w : SOCKS5 reply %d", comerr.ErrDialProxy, response[1])
+	}
+
+	var boundLength int
+	switch response[3] {
+	case socksIPv4:
+		boundLength = net.IPv4len
+	case socksIPv6:
+		boundLength = net.IPv6len
+	case socksDomain:
+		length := make([]byte, 1)
+		if _, err = io.ReadFull(conn, length); err != nil {
+			return err
+		}
+		boundLength = int(length[0])
+	default:
+		return fmt.Errorf("%w : invalid SOCKS5 address type %d", comerr.ErrDialProxy, response[3])
+	}
+
+	_, err = io.ReadFull(conn, make([]byte, boundLength+2))
+	return err
+}
+
+func socksAuthenticate(conn net.Conn, user *url.Userinfo) error {
+	username := user.Username()
+	password, _ := user.Password()
+	if len(username) > 255 || len(password) > 255 {
+		return fmt.Errorf("%w : username or password too long", comerr.ErrDialProxy)
+	}
+
+	request := []byte{userPassVersion, byte(len(username))}
+	request = append(request, username...)
+	request = append(request, byte(len(password)))
+	request = append(request, password...)
+
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[1] != socksSucceeded {
+		return fmt.Errorf("%w : authentication failed", comerr.ErrDialProxy)
+	}
+
+	return nil
+}
+
+// httpConnect Asks an HTTP proxy to open a tunnel with the CONNECT method,
+// with basic authentication if user is set
+func httpConnect(conn net.Conn, user *url.Userinfo, address string) error {
+	var request strings.Builder
+	request.WriteString("CONNECT " + address + " HTTP/1.1\r\nHost: " + address + "\r\n")
+	if user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		request.WriteString("Proxy-Authorization: Basic " + credentials + "\r\n")
+	}
+	request.WriteString("\r\n")
+
+	if _, err := io.WriteString(conn, request.String()); err != nil {
+		return err
+	}
+
+	// Read a byte at a time so that nothing sent through the tunnel after
+	// the response is consumed
+	var response []byte
+	b := make([]byte, 1)
+	for !strings.HasSuffix(string(response), "\r\n\r\n") {
+		if len(response) >= maxResponseBytes {
+			return fmt.Errorf("%w : response header too long", comerr.ErrDialProxy)
+		}
+		if _, err := io.ReadFull(conn, b); err != nil {
+			return err
+		}
+		response = append(response, b[0])
+	}
+
+	statusLine, _, _ := strings.Cut(string(response), "\r\n")
+	fields := strings.Fields(statusLine)
+	if len(fields) < 2 || !strings.HasPrefix(fields[0], "HTTP/1.") {
+		return fmt.Errorf("%w : invalid response %q", comerr.ErrDialProxy, statusLine)
+	}
+	if fields[1] != "200" {
+		return fmt.Errorf("%w : %s", comerr.ErrDialProxy, strings.Join(fields[1:], " "))
+	}
+
+	return nil
+}
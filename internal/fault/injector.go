@@ -0,0 +1,235 @@
+package fault
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+	"tonysoft.com/comm/internal/ratelimit"
+	"tonysoft.com/comm/pkg/comerr"
+)
+
+// Config The faults injected into the reads and writes of a connection,
+// where the zero value injects none.  Probabilities are from 0 to 1 and
+// apply to each read or write independently.
+type Config struct {
+	Seed int64 // seeds the random choices, so that the same sequence of reads/writes sees the same faults
+
+	LatencyMs   int     // delay before each write is sent, and before the data of each read is returned
+	JitterMs    int     // random extra delay of up to this much
+	BytesPerSec float64 // bandwidth cap applied to each direction, 0 means none
+
+	CorruptProbability    float64 // of one byte of a read or write being altered
+	TruncateProbability   float64 // of a write sending only the start of the data, while reporting all of it as sent
+	DropProbability       float64 // of a write being discarded (e.g., a lost datagram), while reporting it as sent
+	ReorderProbability    float64 // of a write being held back and sent after the next one
+	DisconnectProbability float64 // of a read or write closing the connection and failing with ErrInjectedDisconnect
+	DisconnectAfterBytes  int     // closes the connection once this many bytes were read and written, 0 means never
+}
+
+// injector Decides which faults to inject, with a random source for each
+// direction so that concurrent reads and writes do not affect each other's
+// sequence of faults
+// Thread-safe ✓
+type injector struct {
+	cfg Config
+
+	readRandom  *source
+	writeRandom *source
+	readBucket  *ratelimit.Bucket // nil unless BytesPerSec is set
+	writeBucket *ratelimit.Bucket
+
+	mutex       sync.Mutex
+	held        []byte // write held back to be sent after the next one
+	transferred int    // bytes read and written since the connection was (re)started
+}
+
+type source struct {
+	mutex  sync.Mutex
+	random *rand.Rand
+}
+
+func newInjector(cfg Config) *injector {
+	i := &injector{
+		cfg:         cfg,
+		readRandom:  &source{random: rand.New(rand.NewSource(cfg.Seed))},
+		writeRandom: &source{random: rand.New(rand.NewSource(cfg.Seed + 1))},
+	}
+	if cfg.BytesPerSec > 0 {
+		i.readBucket = ratelimit.NewBucket(cfg.BytesPerSec, 0)
+		i.writeBucket = ratelimit.NewBucket(cfg.BytesPerSec, 0)
+	}
+	return i
+}
+
+// chance Returns true with the given probability
+func (s *source) chance(probability float64) bool {
+	if probability <= 0 {
+		return false
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.random.Float64() < probability
+}
+
+// intn Returns a number from 0 to n-1
+func (s *source) intn(n int) int {
+	if n < 2 {
+		return 0
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.random.Intn(n)
+}
+
+// reset Called when the connection is (re)started, the random sources
+// carrying on so that each connection sees different faults
+func (i *injector) reset() {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.held = nil
+	i.transferred = 0
+}
+
+// disconnects Returns true if the connection should be closed now
+func (i *injector) disconnects(random *source) bool {
+	if random.chance(i.cfg.DisconnectProbability) {
+		return true
+	}
+
+	if i.cfg.DisconnectAfterBytes < 1 {
+		return false
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	return i.transferred >= i.cfg.DisconnectAfterBytes
+}
+
+func (i *injector) count(n int) {
+	if n < 1 {
+		return
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.transferred += n
+}
+
+// delay Waits for LatencyMs plus up to JitterMs
+func (i *injector) delay(ctx context.Context, random *source) error {
+	d := time.Duration(i.cfg.LatencyMs) * time.Millisecond
+	if i.cfg.JitterMs > 0 {
+		d += time.Duration(random.intn(i.cfg.JitterMs+1)) * time.Millisecond
+	}
+
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// corrupt Alters one byte of data
+func (i *injector) corrupt(random *source, data []byte) {
+	if len(data) == 0 || !random.chance(i.cfg.CorruptProbability) {
+		return
+	}
+	data[random.intn(len(data))] ^= byte(1 + random.intn(255))
+}
+
+// read Reads via readFunc, injecting faults into what is read.  The
+// connection is closed via disconnect when a disconnect is injected.
+func (i *injector) read(ctx context.Context, buffer []byte, readFunc func(context.Context, []byte) (int, error),
+	disconnect func() error) (int, error) {
+	if i.disconnects(i.readRandom) {
+		_ = disconnect()
+		return -1, comerr.ErrInjectedDisconnect
+	}
+
+	count, err := readFunc(ctx, buffer)
+	if count < 1 {
+		return count, err
+	}
+
+	i.count(count)
+	i.corrupt(i.readRandom, buffer[:count])
+
+	if delayErr := i.delay(ctx, i.readRandom); delayErr != nil {
+		return count, delayErr
+	}
+
+	if i.readBucket != nil {
+		if waitErr := i.readBucket.Wait(ctx, count); waitErr != nil {
+			return count, waitErr
+		}
+	}
+
+	return count, err
+}
+
+// write Writes via writeFunc, injecting faults into what is written.  The
+// full length of data is reported as written unless writeFunc fails.
+func (i *injector) write(ctx context.Context, data []byte, writeFunc func(context.Context, []byte) (int, error),
+	disconnect func() error) (int, error) {
+	if i.disconnects(i.writeRandom) {
+		_ = disconnect()
+		return -1, comerr.ErrInjectedDisconnect
+	}
+
+	if err := i.delay(ctx, i.writeRandom); err != nil {
+		return -1, err
+	}
+
+	if i.writeBucket != nil {
+		if err := i.writeBucket.Wait(ctx, len(data)); err != nil {
+			return -1, err
+		}
+	}
+
+	if i.writeRandom.chance(i.cfg.DropProbability) {
+		return len(data), nil
+	}
+
+	payload := append([]byte(nil), data...)
+	i.corrupt(i.writeRandom, payload)
+
+	if len(payload) > 1 && i.writeRandom.chance(i.cfg.TruncateProbability) {
+		payload = payload[:1+i.writeRandom.intn(len(payload)-1)]
+	}
+
+	i.mutex.Lock()
+	held := i.held
+	i.held = nil
+	if held == nil && i.writeRandom.chance(i.cfg.ReorderProbability) {
+		i.held = payload
+		i.mutex.Unlock()
+		return len(data), nil
+	}
+	i.mutex.Unlock()
+
+	for _, chunk := range [][]byte{payload, held} {
+		if chunk == nil {
+			continue
+		}
+
+		count, err := writeFunc(ctx, chunk)
+		i.count(count)
+		if err != nil {
+			return count, err
+		}
+	}
+
+	return len(data), nil
+}
@@ -0,0 +1,109 @@
+package fault
+
+import (
+	"context"
+	"net"
+	"tonysoft.com/comm/internal/client"
+	"tonysoft.com/comm/internal/socket"
+)
+
+// Client Injects faults into the reads and writes of a client, injected
+// disconnects stopping it
+type Client struct {
+	client.Client
+	injector *injector
+}
+
+// NewClient Wraps c, unless it reconnects automatically (see AutoReconnect),
+// in which case the client it reconnects is wrapped instead so that injected
+// disconnects trigger reconnection; c must not be started yet.
+func NewClient(c client.Client, cfg Config) client.Client {
+	if rc, ok := c.(*client.ReconnectClient); ok {
+		rc.Client = NewClient(rc.Client, cfg)
+		return rc
+	}
+	return &Client{Client: c, injector: newInjector(cfg)}
+}
+
+func (c *Client) Start() error {
+	return c.StartContext(context.Background())
+}
+
+func (c *Client) StartContext(ctx context.Context) error {
+	c.injector.reset()
+	return c.Client.StartContext(ctx)
+}
+
+func (c *Client) Read(buffer []byte) (int, error) {
+	return c.ReadContext(context.Background(), buffer)
+}
+
+func (c *Client) ReadContext(ctx context.Context, buffer []byte) (int, error) {
+	return c.injector.read(ctx, buffer, c.Client.ReadContext, c.Client.Stop)
+}
+
+func (c *Client) Write(data []byte) (int, error) {
+	return c.WriteContext(context.Background(), data)
+}
+
+func (c *Client) WriteContext(ctx context.Context, data []byte) (int, error) {
+	return c.injector.write(ctx, data, c.Client.WriteContext, c.Client.Stop)
+}
+
+// Connection Injects faults into the reads and writes of a connection
+// accepted by a server, injected disconnects closing it
+type Connection struct {
+	socket.Connection
+	injector *injector
+}
+
+func NewConnection(conn socket.Connection, cfg Config) *Connection {
+	return &Connection{Connection: conn, injector: newInjector(cfg)}
+}
+
+func (c *Connection) Read(buffer []byte) (int, error) {
+	return c.ReadContext(context.Background(), buffer)
+}
+
+func (c *Connection) ReadContext(ctx context.Context, buffer []byte) (int, error) {
+	return c.injector.read(ctx, buffer, c.Connection.ReadContext, c.Connection.Close)
+}
+
+func (c *Connection) Write(data []byte) (int, error) {
+	return c.WriteContext(context.Background(), data)
+}
+
+func (c *Connection) WriteContext(ctx context.Context, data []byte) (int, error) {
+	return c.injector.write(ctx, data, c.Connection.WriteContext, c.Connection.Close)
+}
+
+// Conn Injects faults into the reads and writes of any net.Conn, injected
+// disconnects closing it
+type Conn struct {
+	net.Conn
+	injector *injector
+}
+
+func NewConn(conn net.Conn, cfg Config) *Conn {
+	return &Conn{Conn: conn, injector: newInjector(cfg)}
+}
+
+func (c *Conn) Read(buffer []byte) (int, error) {
+	count, err := c.injector.read(context.Background(), buffer, func(_ context.Context, b []byte) (int, error) {
+		return c.Conn.Read(b)
+	}, c.Conn.Close)
+	if count < 0 {
+		count = 0
+	}
+	return count, err
+}
+
+func (c *Conn) Write(data []byte) (int, error) {
+	count, err := c.injector.write(context.Background(), data, func(_ context.Context, b []byte) (int, error) {
+		return c.Conn.Write(b)
+	}, c.Conn.Close)
+	if count < 0 {
+		count = 0
+	}
+	return count, err
+}
@@ -0,0 +1,340 @@
+package handoff
+
+import (
+	"context"
+	"fmt"
+	"golang.org/x/sys/unix"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"tonysoft.com/comm/pkg/comerr"
+)
+
+// EnvPath The environment variable through which Restart passes the path of
+// the handoff socket to the new process (see Inherited)
+const EnvPath = "COMM_HANDOFF_PATH"
+
+const (
+	maxFiles     = 64
+	readyMessage = "ready"
+)
+
+// Source Implemented by servers whose listening socket can be handed off
+type Source interface {
+	ListenerFile() (*os.File, error)
+	Shutdown(context.Context) error
+}
+
+// Handoff The listening sockets received from the previous process, which
+// is waiting for Ready before shutting down
+type Handoff struct {
+	conn  *net.UnixConn
+	files map[string]*os.File
+}
+
+// Inherited Receives the listening sockets handed off by Restart in the
+// previous process, nil if this process was not started by Restart
+func Inherited() (*Handoff, error) {
+	path := os.Getenv(EnvPath)
+	if path == "" {
+		return nil, nil
+	}
+	_ = os.Unsetenv(EnvPath)
+
+	return Receive(path)
+}
+
+// Receive Connects to the handoff socket at path and receives the listening
+// sockets offered by the running process (see Offer)
+func Receive(path string) (*Handoff, error) {
+	conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return nil, failed(err)
+	}
+
+	buffer := make([]byte, 4096)
+	oob := make([]byte, unix.CmsgSpace(maxFiles*4))
+	count, oobCount, _, _, err := conn.ReadMsgUnix(buffer, oob)
+	if err != nil {
+		_ = conn.Close()
+		return nil, failed(err)
+	}
+
+	var fds []int
+	messages, err := unix.ParseSocketControlMessage(oob[:oobCount])
+	for i := 0; err == nil && i < len(messages); i++ {
+		var rights []int
+		rights, err = unix.ParseUnixRights(&messages[i])
+		fds = append(fds, rights...)
+	}
+
+	names := strings.Split(string(buffer[:count]), "\n")
+	if err == nil && len(names) != len(fds) {
+		err = fmt.Errorf("received %d sockets for %d names", len(fds), len(names))
+	}
+	if err != nil {
+		for _, fd := range fds {
+			_ = unix.Close(fd)
+		}
+		_ = conn.Close()
+		return nil, failed(err)
+	}
+
+	h := &Handoff{conn: conn, files: make(map[string]*os.File, len(fds))}
+	for i, fd := range fds {
+		unix.CloseOnExec(fd)
+		h.files[names[i]] = os.NewFile(uintptr(fd), names[i])
+	}
+
+	return h, nil
+}
+
+// File Returns the socket with the given name, nil if there is none (or h
+// is nil) so that the result can be used as a server's ListenerFile
+// whether a handoff took place or not
+func (h *Handoff) File(name string) *os.File {
+	if h == nil {
+		return nil
+	}
+	return h.files[name]
+}
+
+// Ready Tells the previous process that its sockets are in use, so that it
+// can shut down.  The sockets remain open until Close, so that servers
+// using them can be restarted.
+func (h *Handoff) Ready() error {
+	if h == nil {
+		return nil
+	}
+
+	_, err := h.conn.Write([]byte(readyMessage))
+	_ = h.conn.Close()
+	if err != nil {
+		return failed(err)
+	}
+
+	return nil
+}
+
+// Close Closes the received sockets, which remain open in the servers using
+// them until they are stopped
+func (h *Handoff) Close() {
+	if h == nil {
+		return
+	}
+	closeFiles(h.files)
+}
+
+// Offer Hands the listening sockets of sources (keyed by name, see
+// Handoff.File) to the first process to connect to a Unix socket at path
+// (see Receive), then once it is Ready shuts the sources down gracefully.
+// The sources keep running if the handoff fails or ctx is done first.
+func Offer(ctx context.Context, path string, sources map[string]Source) error {
+	files, err := listenerFiles(sources)
+	if err != nil {
+		return err
+	}
+	defer closeFiles(files)
+
+	listener, err := listen(path)
+	if err != nil {
+		return err
+	}
+
+	err = offer(ctx, listener, files)
+	if err != nil {
+		return err
+	}
+
+	return shutdown(ctx, sources)
+}
+
+// Restart Starts a new instance of the executable, with the same arguments
+// and EnvPath set to path, then Offers it the listening sockets of sources.
+// The new process is killed if the handoff fails.
+func Restart(ctx context.Context, path string, sources map[string]Source) (*os.Process, error) {
+	files, err := listenerFiles(sources)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFiles(files)
+
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, failed(err)
+	}
+
+	listener, err := listen(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Env = append(os.Environ(), EnvPath+"="+path)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err = cmd.Start()
+	if err != nil {
+		_ = listener.Close()
+		return nil, failed(err)
+	}
+
+	err = offer(ctx, listener, files)
+	if err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return nil, err
+	}
+
+	return cmd.Process, shutdown(ctx, sources)
+}
+
+func listenerFiles(sources map[string]Source) (map[string]*os.File, error) {
+	if len(sources) == 0 || len(sources) > maxFiles {
+		return nil, failed(fmt.Errorf("between 1 and %d sockets can be handed off", maxFiles))
+	}
+
+	files := make(map[string]*os.File, len(sources))
+	for name, source := range sources {
+		if name == "" || strings.Contains(name, "\n") {
+			closeFiles(files)
+			return nil, failed(fmt.Errorf("invalid socket name '%s'", name))
+		}
+
+		f, err := source.ListenerFile()
+		if err != nil {
+			closeFiles(files)
+			return nil, failed(fmt.Errorf("%s: %v", name, err))
+		}
+		files[name] = f
+	}
+
+	return files, nil
+}
+
+func closeFiles(files map[string]*os.File) {
+	for _, f := range files {
+		_ = f.Close()
+	}
+}
+
+func listen(path string) (*net.UnixListener, error) {
+	// Remove a socket left behind by a previous process, but nothing else
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		_ = os.Remove(path)
+	}
+
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return nil, failed(err)
+	}
+
+	return listener, nil
+}
+
+// offer Sends the files to the first process to connect to the listener,
+// then waits for it to be Ready.  The listener is closed on return.
+func offer(ctx context.Context, listener *net.UnixListener, files map[string]*os.File) error {
+	var conn atomic.Pointer[net.UnixConn]
+	done := make(chan bool)
+	defer close(done)
+
+	// Unblock Accept and Read once ctx is done
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = listener.Close()
+			if c := conn.Load(); c != nil {
+				_ = c.Close()
+			}
+		case <-done:
+		}
+	}()
+
+	defer func() {
+		_ = listener.Close()
+	}()
+
+	c, err := listener.AcceptUnix()
+	if err != nil {
+		return failed(contextErr(ctx, err))
+	}
+	defer func() {
+		_ = c.Close()
+	}()
+
+	conn.Store(c)
+	if ctx.Err() != nil {
+		return failed(ctx.Err())
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fds := make([]int, len(names))
+	for i, name := range names {
+		fds[i] = int(files[name].Fd())
+	}
+
+	_, _, err = c.WriteMsgUnix([]byte(strings.Join(names, "\n")), unix.UnixRights(fds...), nil)
+	if err != nil {
+		return failed(contextErr(ctx, err))
+	}
+
+	ready := make([]byte, len(readyMessage))
+	_, err = io.ReadFull(c, ready)
+	if err != nil {
+		return failed(contextErr(ctx, err))
+	}
+	if string(ready) != readyMessage {
+		return failed(fmt.Errorf("unexpected reply '%s'", string(ready)))
+	}
+
+	return nil
+}
+
+// shutdown Gracefully stops the sources concurrently, returning the first error
+func shutdown(ctx context.Context, sources map[string]Source) error {
+	var wg sync.WaitGroup
+	errs := make(chan error, len(sources))
+
+	for _, source := range sources {
+		wg.Add(1)
+		go func(s Source) {
+			defer wg.Done()
+			errs <- s.Shutdown(ctx)
+		}(source)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func contextErr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+func failed(err error) error {
+	return fmt.Errorf("%w : %v", comerr.ErrHandoffFailed, err)
+}
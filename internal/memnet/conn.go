@@ -0,0 +1,343 @@
+package memnet
+
+import (
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const bufferSize = 256 * 1024 // bytes buffered in each direction before Write blocks
+
+// Conn One end of an in-process connection, implementing net.Conn over a
+// pair of buffered pipes.  Data written becomes readable by the other end
+// once the connection's latency has elapsed, in order, and Close is seen by
+// the other end as io.EOF once it has read what was written before it.
+// Expired deadlines fail with os.ErrDeadlineExceeded, as with sockets.
+// Thread-safe ✓
+type Conn struct {
+	localAddr  Addr
+	remoteAddr Addr
+
+	rx *pipe // written by the other end
+	tx *pipe // read by the other end
+
+	readDeadline  *deadline
+	writeDeadline *deadline
+
+	closeChan chan struct{}
+	closeOnce sync.Once
+}
+
+// pair Returns both ends of a new connection
+func pair(dialerAddr Addr, listenerAddr Addr, latency time.Duration) (*Conn, *Conn) {
+	toListener := newPipe(latency)
+	toDialer := newPipe(latency)
+
+	dialerConn := newConn(dialerAddr, listenerAddr, toDialer, toListener)
+	listenerConn := newConn(listenerAddr, dialerAddr, toListener, toDialer)
+
+	return dialerConn, listenerConn
+}
+
+func newConn(localAddr Addr, remoteAddr Addr, rx *pipe, tx *pipe) *Conn {
+	return &Conn{
+		localAddr:     localAddr,
+		remoteAddr:    remoteAddr,
+		rx:            rx,
+		tx:            tx,
+		readDeadline:  newDeadline(),
+		writeDeadline: newDeadline(),
+		closeChan:     make(chan struct{}),
+	}
+}
+
+func (c *Conn) Read(buffer []byte) (int, error) {
+	if c.isClosed() {
+		return 0, net.ErrClosed
+	}
+	return c.rx.read(buffer, c.readDeadline.wait(), c.closeChan)
+}
+
+func (c *Conn) Write(data []byte) (int, error) {
+	if c.isClosed() {
+		return 0, net.ErrClosed
+	}
+	return c.tx.write(data, c.writeDeadline.wait(), c.closeChan)
+}
+
+// Close Data already written is still delivered, after which the other end
+// reads io.EOF, while its writes fail with io.ErrClosedPipe right away
+func (c *Conn) Close() error {
+	err := net.ErrClosed
+	c.closeOnce.Do(func() {
+		close(c.closeChan)
+		c.tx.closeWriter()
+		c.rx.closeReader()
+		err = nil
+	})
+	return err
+}
+
+// IsAlive Returns false once the other end has closed the connection and
+// everything it wrote has been read
+func (c *Conn) IsAlive() bool {
+	return !c.isClosed() && c.rx.alive()
+}
+
+func (c *Conn) isClosed() bool {
+	select {
+	case <-c.closeChan:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *Conn) LocalAddr() net.Addr {
+	return c.localAddr
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+func (c *Conn) SetDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	c.writeDeadline.set(t)
+	return nil
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	return nil
+}
+
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	c.writeDeadline.set(t)
+	return nil
+}
+
+// pipe One direction of a connection
+type pipe struct {
+	mutex   sync.Mutex
+	latency time.Duration
+
+	chunks []chunk
+	size   int // bytes buffered
+
+	eof          time.Time // when the writer's close is seen by the reader, zero while open
+	readerClosed bool
+
+	changed chan struct{} // closed and replaced whenever the state above changes
+}
+
+// chunk Data written at once, readable from the given time
+type chunk struct {
+	data []byte
+	due  time.Time
+}
+
+func newPipe(latency time.Duration) *pipe {
+	return &pipe{latency: latency, changed: make(chan struct{})}
+}
+
+// notify Wakes the reader and writer waiting on changed, with mutex held
+func (p *pipe) notify() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+func (p *pipe) read(buffer []byte, deadline <-chan struct{}, closeChan <-chan struct{}) (int, error) {
+	if len(buffer) == 0 {
+		return 0, nil
+	}
+
+	for {
+		p.mutex.Lock()
+
+		now := time.Now()
+		count := 0
+		for len(p.chunks) > 0 && count < len(buffer) && !p.chunks[0].due.After(now) {
+			n := copy(buffer[count:], p.chunks[0].data)
+			count += n
+			p.size -= n
+			if n < len(p.chunks[0].data) {
+				p.chunks[0].data = p.chunks[0].data[n:]
+			} else {
+				p.chunks = p.chunks[1:]
+			}
+		}
+
+		if count > 0 {
+			p.notify()
+			p.mutex.Unlock()
+			return count, nil
+		}
+
+		var wait time.Duration
+		if len(p.chunks) > 0 {
+			wait = p.chunks[0].due.Sub(now)
+		} else if !p.eof.IsZero() {
+			if wait = p.eof.Sub(now); wait <= 0 {
+				p.mutex.Unlock()
+				return 0, io.EOF
+			}
+		}
+		changed := p.changed
+		p.mutex.Unlock()
+
+		var timer *time.Timer
+		var due <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			due = timer.C
+		}
+
+		select {
+		case <-changed:
+		case <-due:
+		case <-deadline:
+			return 0, os.ErrDeadlineExceeded
+		case <-closeChan:
+			return 0, net.ErrClosed
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+func (p *pipe) write(data []byte, deadline <-chan struct{}, closeChan <-chan struct{}) (int, error) {
+	written := 0
+
+	for written < len(data) {
+		p.mutex.Lock()
+
+		if p.readerClosed {
+			p.mutex.Unlock()
+			return written, io.ErrClosedPipe
+		}
+
+		if space := bufferSize - p.size; space > 0 {
+			count := len(data) - written
+			if count > space {
+				count = space
+			}
+
+			p.chunks = append(p.chunks, chunk{
+				data: append([]byte(nil), data[written:written+count]...),
+				due:  time.Now().Add(p.latency),
+			})
+			p.size += count
+			written += count
+
+			p.notify()
+			p.mutex.Unlock()
+			continue
+		}
+
+		changed := p.changed
+		p.mutex.Unlock()
+
+		select {
+		case <-changed:
+		case <-deadline:
+			return written, os.ErrDeadlineExceeded
+		case <-closeChan:
+			return written, net.ErrClosed
+		}
+	}
+
+	return written, nil
+}
+
+func (p *pipe) closeWriter() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.eof = time.Now().Add(p.latency)
+	p.notify()
+}
+
+// closeReader Data not yet read is discarded
+func (p *pipe) closeReader() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.readerClosed = true
+	p.chunks = nil
+	p.size = 0
+	p.notify()
+}
+
+func (p *pipe) alive() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.eof.IsZero() || len(p.chunks) > 0 || time.Now().Before(p.eof)
+}
+
+// deadline A channel closed once the time it is set to has passed
+type deadline struct {
+	mutex      sync.Mutex
+	timer      *time.Timer
+	cancelChan chan struct{}
+}
+
+func newDeadline() *deadline {
+	return &deadline{cancelChan: make(chan struct{})}
+}
+
+// set A zero time clears the deadline, and a time in the past expires it
+// immediately, waking any pending IO
+func (d *deadline) set(t time.Time) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	// Wait for a timer that has already fired to close the channel
+	if d.timer != nil && !d.timer.Stop() {
+		<-d.cancelChan
+	}
+	d.timer = nil
+
+	expired := isClosed(d.cancelChan)
+	if t.IsZero() {
+		if expired {
+			d.cancelChan = make(chan struct{})
+		}
+		return
+	}
+
+	if duration := time.Until(t); duration > 0 {
+		if expired {
+			d.cancelChan = make(chan struct{})
+		}
+		cancelChan := d.cancelChan
+		d.timer = time.AfterFunc(duration, func() {
+			close(cancelChan)
+		})
+		return
+	}
+
+	if !expired {
+		close(d.cancelChan)
+	}
+}
+
+func (d *deadline) wait() <-chan struct{} {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.cancelChan
+}
+
+func isClosed(c chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
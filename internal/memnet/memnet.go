@@ -0,0 +1,163 @@
+package memnet
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"tonysoft.com/comm/pkg/comerr"
+)
+
+const (
+	scheme      = "mem://"
+	network     = "mem"
+	backlogSize = 128 // connections waiting to be accepted, beyond which Dial blocks
+)
+
+var (
+	listenersMutex sync.Mutex
+	listeners      = map[string]*Listener{}
+
+	nextDialerId atomic.Uint64
+)
+
+// Addr The mem:// address of a listener or connection
+type Addr string
+
+func (a Addr) Network() string {
+	return network
+}
+
+func (a Addr) String() string {
+	return string(a)
+}
+
+// canonical Returns address with its scheme in lower case, or an error if
+// it is not a mem:// address or has no name
+func canonical(address string) (string, error) {
+	address = strings.TrimSpace(address)
+	if len(address) <= len(scheme) || !strings.EqualFold(address[:len(scheme)], scheme) {
+		return "", fmt.Errorf("%w : %s", comerr.ErrAddressFormatUnknown, address)
+	}
+	return scheme + address[len(scheme):], nil
+}
+
+// Listener Accepts connections dialed to its mem:// address within this
+// process.  Connections are delayed by the greater of the listener's and the
+// dialer's latency.
+// Thread-safe ✓
+type Listener struct {
+	addr    Addr
+	latency time.Duration
+
+	acceptChan chan *Conn
+	closeChan  chan struct{}
+	closeOnce  sync.Once
+}
+
+// Listen Registers a listener for address, failing with EADDRINUSE if
+// another listener has it
+func Listen(address string, latency time.Duration) (*Listener, error) {
+	address, err := canonical(address)
+	if err != nil {
+		return nil, err
+	}
+
+	listenersMutex.Lock()
+	defer listenersMutex.Unlock()
+
+	if _, ok := listeners[address]; ok {
+		return nil, &net.OpError{Op: "listen", Net: network, Addr: Addr(address), Err: syscall.EADDRINUSE}
+	}
+
+	l := &Listener{
+		addr:       Addr(address),
+		latency:    latency,
+		acceptChan: make(chan *Conn, backlogSize),
+		closeChan:  make(chan struct{}),
+	}
+	listeners[address] = l
+
+	return l, nil
+}
+
+func (l *Listener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.acceptChan:
+		return conn, nil
+	case <-l.closeChan:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close Releases the address, closing the connections not yet accepted
+func (l *Listener) Close() error {
+	err := net.ErrClosed
+	l.closeOnce.Do(func() {
+		listenersMutex.Lock()
+		if listeners[string(l.addr)] == l {
+			delete(listeners, string(l.addr))
+		}
+		listenersMutex.Unlock()
+
+		close(l.closeChan)
+		err = nil
+
+		for {
+			select {
+			case conn := <-l.acceptChan:
+				_ = conn.Close()
+			default:
+				return
+			}
+		}
+	})
+	return err
+}
+
+func (l *Listener) Addr() net.Addr {
+	return l.addr
+}
+
+// Dial Connects to the listener at address, failing with ECONNREFUSED if
+// there is none.  The connection's local address is localAddress if it is
+// a mem:// address, letting the listener identify the dialer, or a unique
+// mem:// address otherwise.
+func Dial(ctx context.Context, address string, localAddress string, latency time.Duration) (*Conn, error) {
+	address, err := canonical(address)
+	if err != nil {
+		return nil, err
+	}
+
+	local, err := canonical(localAddress)
+	if err != nil {
+		local = fmt.Sprintf("%sdialer-%d", scheme, nextDialerId.Add(1))
+	}
+
+	listenersMutex.Lock()
+	l := listeners[address]
+	listenersMutex.Unlock()
+
+	if l == nil {
+		return nil, &net.OpError{Op: "dial", Net: network, Addr: Addr(address), Err: syscall.ECONNREFUSED}
+	}
+
+	if l.latency > latency {
+		latency = l.latency
+	}
+
+	dialerConn, listenerConn := pair(Addr(local), l.addr, latency)
+
+	select {
+	case l.acceptChan <- listenerConn:
+		return dialerConn, nil
+	case <-l.closeChan:
+		return nil, &net.OpError{Op: "dial", Net: network, Addr: Addr(address), Err: syscall.ECONNREFUSED}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,235 @@
+package netif
+
+import (
+	"fmt"
+	"golang.org/x/sys/unix"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+	"tonysoft.com/comm/pkg/comerr"
+	"unsafe"
+)
+
+// pollInterval How often a Watcher checks the address when no change is
+// signalled, in case the change notifications cannot be received
+const pollInterval = time.Second
+
+// Address Returns the IPv4 address of the network interface with the given
+// name (e.g., eth0), or of the address with the given label (e.g., eth0:1).
+// If there are several addresses the primary one is returned.
+func Address(name string) (net.IP, error) {
+	return resolve(name, nil)
+}
+
+// resolve Same as Address, except current is returned while it is still
+// assigned so that adding an address does not cause a change
+func resolve(name string, current net.IP) (net.IP, error) {
+	addresses, err := addresses(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w : %s : %v", comerr.ErrInterfaceAddress, name, err)
+	}
+
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("%w : %s", comerr.ErrInterfaceAddress, name)
+	}
+
+	for _, ip := range addresses {
+		if ip.Equal(current) {
+			return current, nil
+		}
+	}
+
+	return addresses[0], nil
+}
+
+// addresses Lists the IPv4 addresses of the interface or label, primary
+// addresses first
+func addresses(name string) ([]net.IP, error) {
+	index := -1
+	if iface, err := net.InterfaceByName(name); err == nil {
+		index = iface.Index
+	}
+
+	rib, err := syscall.NetlinkRIB(syscall.RTM_GETADDR, syscall.AF_INET)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := syscall.ParseNetlinkMessage(rib)
+	if err != nil {
+		return nil, err
+	}
+
+	var primary, secondary []net.IP
+	for i := range messages {
+		message := &messages[i]
+		if message.Header.Type != syscall.RTM_NEWADDR || len(message.Data) < syscall.SizeofIfAddrmsg {
+			continue
+		}
+		ifAddr := (*syscall.IfAddrmsg)(unsafe.Pointer(&message.Data[0]))
+
+		attrs, attrErr := syscall.ParseNetlinkRouteAttr(message)
+		if attrErr != nil {
+			return nil, attrErr
+		}
+
+		var ip net.IP
+		var label string
+		for _, attr := range attrs {
+			switch attr.Attr.Type {
+			case syscall.IFA_LOCAL:
+				ip = net.IP(attr.Value).To4()
+			case syscall.IFA_LABEL:
+				label = string(trimNull(attr.Value))
+			}
+		}
+
+		if ip == nil || (int(ifAddr.Index) != index && label != name) {
+			continue
+		}
+
+		if ifAddr.Flags&unix.IFA_F_SECONDARY != 0 {
+			secondary = append(secondary, ip)
+		} else {
+			primary = append(primary, ip)
+		}
+	}
+
+	return append(primary, secondary...), nil
+}
+
+func trimNull(b []byte) []byte {
+	for i, c := range b {
+		if c == 0 {
+			return b[:i]
+		}
+	}
+	return b
+}
+
+// Watcher Follows the IPv4 address of a network interface (see Address),
+// for instance as it changes when a DHCP lease is renewed
+type Watcher struct {
+	name     string
+	current  net.IP
+	onChange func(net.IP)
+
+	fd        int // netlink socket subscribed to address changes, -1 if unavailable
+	wakeFd    int // eventfd that interrupts waiting for changes on Close
+	done      chan bool
+	closeOnce sync.Once
+	waitGroup sync.WaitGroup
+}
+
+// Watch Calls onChange with the new address of the interface whenever it
+// differs from current, until the watcher is closed.  While the interface
+// has no address, current is kept.
+func Watch(name string, current net.IP, onChange func(net.IP)) *Watcher {
+	w := &Watcher{
+		name:     name,
+		current:  current,
+		onChange: onChange,
+		fd:       subscribe(),
+		wakeFd:   -1,
+		done:     make(chan bool),
+	}
+
+	if w.fd >= 0 {
+		wakeFd, err := unix.Eventfd(0, unix.EFD_CLOEXEC|unix.EFD_NONBLOCK)
+		if err != nil {
+			_ = unix.Close(w.fd)
+			w.fd = -1
+		} else {
+			w.wakeFd = wakeFd
+		}
+	}
+
+	w.waitGroup.Add(1)
+	go w.watch()
+
+	return w
+}
+
+// subscribe Opens a netlink socket that receives IPv4 address changes,
+// returning -1 if it cannot, in which case the address is polled
+func subscribe() int {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC|unix.SOCK_NONBLOCK, unix.NETLINK_ROUTE)
+	if err != nil {
+		return -1
+	}
+
+	err = unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: unix.RTMGRP_IPV4_IFADDR})
+	if err != nil {
+		_ = unix.Close(fd)
+		return -1
+	}
+
+	return fd
+}
+
+func (w *Watcher) watch() {
+	defer w.waitGroup.Done()
+
+	for w.wait() {
+		ip, err := resolve(w.name, w.current)
+		if err != nil || ip.Equal(w.current) {
+			continue
+		}
+
+		w.current = ip
+		w.onChange(ip)
+	}
+}
+
+// wait Waits for an address change notification or the poll interval to
+// elapse, returning false once the watcher is closed
+func (w *Watcher) wait() bool {
+	if w.fd < 0 {
+		select {
+		case <-w.done:
+			return false
+		case <-time.After(pollInterval):
+			return true
+		}
+	}
+
+	fds := []unix.PollFd{{Fd: int32(w.fd), Events: unix.POLLIN}, {Fd: int32(w.wakeFd), Events: unix.POLLIN}}
+	_, _ = unix.Poll(fds, int(pollInterval/time.Millisecond))
+
+	select {
+	case <-w.done:
+		return false
+	default:
+	}
+
+	// Only the fact that something changed matters, not what changed
+	buffer := make([]byte, 4096)
+	for {
+		if _, _, err := unix.Recvfrom(w.fd, buffer, unix.MSG_DONTWAIT); err != nil {
+			return true
+		}
+	}
+}
+
+// Close Stops watching, waiting for any onChange call in progress to return
+// (so it must not be called by onChange)
+func (w *Watcher) Close() {
+	if w == nil {
+		return
+	}
+
+	w.closeOnce.Do(func() {
+		close(w.done)
+		if w.wakeFd >= 0 {
+			_, _ = unix.Write(w.wakeFd, []byte{1, 0, 0, 0, 0, 0, 0, 0})
+		}
+
+		w.waitGroup.Wait()
+
+		if w.fd >= 0 {
+			_ = unix.Close(w.fd)
+			_ = unix.Close(w.wakeFd)
+		}
+	})
+}
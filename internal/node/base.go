@@ -1,11 +1,15 @@
 package node
 
 import (
+	"fmt"
 	"sync"
+	"sync/atomic"
 	"tonysoft.com/comm/internal/comerr"
 	"tonysoft.com/comm/internal/comobj"
 	"tonysoft.com/comm/internal/config"
 	_node "tonysoft.com/comm/internal/config/node"
+	"tonysoft.com/comm/internal/ratelimit"
+	"tonysoft.com/comm/internal/transport"
 	"tonysoft.com/comm/pkg/server"
 )
 
@@ -16,11 +20,83 @@ type BaseNode[T any] struct {
 	server       server.Server
 	replyAddress string
 	replyPort    uint16
+	webSocket    *transport.WebSocketURL // nil unless Address is a ws:// or wss:// URL
+	memory       bool                    // if true Address is a mem:// address, which identifies the node alone
 
 	connections sync.Map // map[socket.ConnectionID]*Connection
+	streams     sync.Map // map[socket.ConnectionID]*MessageStream[T]
+
+	// The number of messages being sent or delivered
+	inFlight atomic.Int32
 
 	incomingChan chan *Message[T]
 	statusChan   chan *Message[T]
+	stopChan     chan bool // closed on Stop to release blocked sends
+
+	droppedMessages atomic.Uint64
+	droppedReceipts atomic.Uint64
+
+	peerLimiters   sync.Map // map[string]*ratelimit.Limiter, keyed by peer address
+	peerSendLimits sync.Map // map[string]ratelimit.Limit, overrides set by SetPeerSendLimit
 
 	comerr.DefaultProducer
 }
+
+// DroppedMessages Returns the number of messages discarded because the Recv()
+// channel's buffer was full, as per RecvOverflowPolicy
+func (n *BaseNode[T]) DroppedMessages() uint64 {
+	return n.droppedMessages.Load()
+}
+
+// DroppedReceipts Returns the number of receipts discarded because the Status()
+// channel's buffer was full, as per StatusOverflowPolicy
+func (n *BaseNode[T]) DroppedReceipts() uint64 {
+	return n.droppedReceipts.Load()
+}
+
+// SetPeerSendLimit Override PeerSendLimit for messages sent to the given peer
+func (n *BaseNode[T]) SetPeerSendLimit(address string, limit ratelimit.Limit) {
+	n.peerSendLimits.Store(address, limit)
+	n.peerLimiters.Store(address, ratelimit.NewLimiter(limit))
+}
+
+// peerLimiter Returns the limiter applied to messages sent to the given
+// peer, which is nil if they are not limited
+func (n *BaseNode[T]) peerLimiter(address string) *ratelimit.Limiter {
+	if limiter, ok := n.peerLimiters.Load(address); ok {
+		return limiter.(*ratelimit.Limiter)
+	}
+
+	limit := n.Config().PeerSendLimit
+	if override, ok := n.peerSendLimits.Load(address); ok {
+		limit = override.(ratelimit.Limit)
+	}
+
+	limiter, _ := n.peerLimiters.LoadOrStore(address, ratelimit.NewLimiter(limit))
+	return limiter.(*ratelimit.Limiter)
+}
+
+// forgetIdlePeers Discards the limiters that have refilled, so that one is
+// not kept for every peer ever sent to, while a peer that reconnects is not
+// allowed more than waiting would (overrides are kept)
+func (n *BaseNode[T]) forgetIdlePeers() {
+	n.peerLimiters.Range(func(address any, limiter any) bool {
+		if limiter.(*ratelimit.Limiter).Refilled() {
+			n.peerLimiters.CompareAndDelete(address, limiter)
+		}
+		return true
+	})
+}
+
+// nodeAddress Returns the address of the node listening on port at host,
+// which is a ws:// or wss:// URL with the same path as Address if this node
+// listens for WebSocket connections, or host itself for mem:// nodes
+func (n *BaseNode[T]) nodeAddress(host string, port uint16) string {
+	if n.memory {
+		return host
+	}
+	if n.webSocket != nil {
+		return n.webSocket.WithHostPort(host, port).String()
+	}
+	return fmt.Sprintf("%s:%d", host, port)
+}
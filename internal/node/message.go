@@ -87,6 +87,7 @@ import (
 	"strconv"
 	"sync/atomic"
 	"time"
+	"tonysoft.com/comm/internal/stream"
 	"tonysoft.com/comm/pkg/comerr"
 )
 
@@ -333,11 +334,19 @@ func getChecksum(byteSlice []byte) byte {
  STREAM
 *******************************************************************************/
 
+// MessageStream Produces instances of *Message[T] from a byte stream.
+// OverflowPolicy determines what happens to messages produced while the
+// channel's buffer is full, with CloseConnection ending the stream with
+// ErrChannelOverflow (and closing the reader if it implements io.Closer).
 type MessageStream[T any] struct {
+	OverflowPolicy stream.OverflowPolicy
+
 	reader      io.Reader
 	streamChan  chan *Message[T]
 	processChan chan bool
 	err         atomic.Value
+	receiving   atomic.Bool
+	dropped     atomic.Uint64
 }
 
 func (s *MessageStream[T]) Stream(reader io.Reader, bufferSize ...int) <-chan *Message[T] {
@@ -363,6 +372,16 @@ func (s *MessageStream[T]) Error() error {
 	return nil
 }
 
+func (s *MessageStream[T]) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Receiving Returns true while a message has been partially received, or
+// while received messages are waiting to be read from the stream.
+func (s *MessageStream[T]) Receiving() bool {
+	return s.receiving.Load() || len(s.streamChan) > 0
+}
+
 func (s *MessageStream[T]) Close() error {
 	if s.processChan != nil {
 		select {
@@ -377,6 +396,7 @@ func (s *MessageStream[T]) Close() error {
 
 func (s *MessageStream[T]) process() {
 	s.processChan = make(chan bool)
+	processChan := s.processChan
 	buffer := make([]byte, 1500)
 	mb := NewMessageBuilder[T]()
 
@@ -403,10 +423,8 @@ func (s *MessageStream[T]) process() {
 				if e == nil {
 					msg.status.Store(MessageReceived)
 
-					select {
-					case s.streamChan <- msg:
-						break
-					default:
+					if !s.send(msg, processChan) {
+						return
 					}
 				} else {
 					if e == comerr.ErrInvalidMessagePayload {
@@ -419,9 +437,31 @@ func (s *MessageStream[T]) process() {
 				}
 			}
 		}
+
+		s.receiving.Store(mb.InProgress())
 	}
 }
 
+// send Returns false if the stream was closed per OverflowPolicy
+func (s *MessageStream[T]) send(msg *Message[T], done <-chan bool) bool {
+	countDrop := func(*Message[T]) {
+		s.dropped.Add(1)
+	}
+
+	if stream.Send(s.streamChan, msg, s.OverflowPolicy, done, countDrop) ||
+		s.OverflowPolicy != stream.CloseConnection {
+		return true
+	}
+
+	s.err.Store(comerr.ErrChannelOverflow)
+	if closer, ok := s.reader.(io.Closer); ok {
+		_ = closer.Close()
+	}
+
+	close(s.streamChan)
+	return false
+}
+
 func NewMessageStream[T any](reader io.Reader, bufferSize ...int) <-chan *Message[T] {
 	ms := &MessageStream[T]{}
 	return ms.Stream(reader, bufferSize...)
@@ -524,6 +564,11 @@ func (m *MessageBuilder[T]) Reset() {
 	m.inPayload = false
 }
 
+// InProgress Returns true if part of a message has been written to the builder
+func (m *MessageBuilder[T]) InProgress() bool {
+	return m.inPreamble || m.inHeader || m.inPayload
+}
+
 // Message This can be called once WriteByte() returns true, indicating a complete
 // message has been received via WriteByte().
 func (m *MessageBuilder[T]) Message() (*Message[T], error) {
@@ -1,9 +1,12 @@
 package node
 
 import (
+	"context"
 	"fmt"
 	"time"
+	_client "tonysoft.com/comm/internal/config/client"
 	"tonysoft.com/comm/internal/socket"
+	"tonysoft.com/comm/internal/stream"
 	"tonysoft.com/comm/internal/transport"
 	"tonysoft.com/comm/pkg/client"
 	"tonysoft.com/comm/pkg/comerr"
@@ -24,6 +27,7 @@ func (n *TcpNode[T]) Start() error {
 	n.ConfigureErrors(cfg.ErrorChanBufferSize)
 	n.incomingChan = make(chan *Message[T], cfg.RecvChanBufferSize)
 	n.statusChan = make(chan *Message[T], cfg.StatusChanBufferSize)
+	n.stopChan = make(chan bool)
 
 	err := n.startServer(cfg.Address, cfg.IncomingConnectionLimit, cfg.IdleConnectionTimeoutMs, cfg.SendMessageReceipts)
 	if err != nil {
@@ -40,18 +44,13 @@ func (n *TcpNode[T]) Stop() {
 		return
 	}
 
+	close(n.stopChan)
+
 	if n.server != nil {
 		n.server.Stop()
 	}
 
-	n.connections.Range(func(id any, conn any) bool {
-		err := conn.(*Connection).Close()
-		if err != nil {
-			n.SendError(err)
-		}
-		n.connections.Delete(id)
-		return true
-	})
+	n.closeConnections()
 
 	n.SetIsRunning(false)
 
@@ -65,6 +64,93 @@ func (n *TcpNode[T]) Stop() {
 	}
 }
 
+// shutdownNotifier Implemented by the servers that signal when Shutdown begins
+type shutdownNotifier interface {
+	ShuttingDown() <-chan struct{}
+}
+
+// Shutdown Gracefully stop the node.  Send is rejected with ErrNodeStopping
+// while messages already being sent or received are allowed to complete,
+// after which the server stops accepting connections, open connections are
+// closed and the node is stopped.  If ctx is done first the node is stopped
+// regardless and ctx.Err() is returned.
+func (n *TcpNode[T]) Shutdown(ctx context.Context) error {
+	if !n.IsRunning() {
+		return nil
+	}
+
+	n.SetIsStopping(true)
+	defer n.SetIsStopping(false)
+
+	err := n.awaitDelivered(ctx)
+
+	if n.server != nil {
+		shutdownErrChan := make(chan error, 1)
+		go func() {
+			shutdownErrChan <- n.server.Shutdown(ctx)
+		}()
+
+		// Connections closed while the server is shutting down are closed gracefully
+		if s, ok := n.server.(shutdownNotifier); ok {
+			select {
+			case <-s.ShuttingDown():
+			case <-ctx.Done():
+			}
+		}
+		n.closeConnections()
+
+		select {
+		case shutdownErr := <-shutdownErrChan:
+			if err == nil {
+				err = shutdownErr
+			}
+		case <-ctx.Done():
+			if err == nil {
+				err = ctx.Err()
+			}
+		}
+	}
+
+	n.Stop()
+
+	return err
+}
+
+func (n *TcpNode[T]) awaitDelivered(ctx context.Context) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for n.inFlight.Load() > 0 || n.isReceiving() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	return nil
+}
+
+func (n *TcpNode[T]) isReceiving() bool {
+	receiving := false
+	n.streams.Range(func(_ any, value any) bool {
+		receiving = value.(*MessageStream[T]).Receiving()
+		return !receiving
+	})
+	return receiving
+}
+
+func (n *TcpNode[T]) closeConnections() {
+	n.connections.Range(func(id any, conn any) bool {
+		err := conn.(*Connection).Close()
+		if err != nil {
+			n.SendError(err)
+		}
+		n.connections.Delete(id)
+		return true
+	})
+}
+
 func (n *TcpNode[T]) ConnectionCount() int {
 	count := 0
 	n.connections.Range(func(_ any, _ any) bool {
@@ -84,6 +170,13 @@ func (n *TcpNode[T]) ConnectedNodes() []string {
 }
 
 func (n *TcpNode[T]) Send(toNode string, data *T) (*Message[T], error) {
+	if n.IsStopping() {
+		return nil, comerr.ErrNodeStopping
+	}
+
+	n.inFlight.Add(1)
+	defer n.inFlight.Add(-1)
+
 	msg := NewMessage[T](n.replyPort, toNode, data)
 
 	conn := n.getConnectionByAddress(toNode)
@@ -100,6 +193,14 @@ func (n *TcpNode[T]) Send(toNode string, data *T) (*Message[T], error) {
 		return nil, err
 	}
 
+	// A send waiting for the peer's limit is abandoned once the node stops
+	ctx, cancel := n.stopContext()
+	err = n.peerLimiter(toNode).Acquire(ctx, len(msgBytes))
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+
 	_, err = conn.Write(msgBytes)
 	if err != nil {
 		return nil, err
@@ -108,6 +209,22 @@ func (n *TcpNode[T]) Send(toNode string, data *T) (*Message[T], error) {
 	return msg, nil
 }
 
+// stopContext Returns a context cancelled once the node is stopped
+func (n *TcpNode[T]) stopContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stopChan := n.stopChan
+
+	go func() {
+		select {
+		case <-stopChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, cancel
+}
+
 func (n *TcpNode[T]) Recv() <-chan *Message[T] {
 	return n.incomingChan
 }
@@ -117,17 +234,37 @@ func (n *TcpNode[T]) Status() <-chan *Message[T] {
 }
 
 func (n *TcpNode[T]) startServer(address string, connectionLimit int, idleConnTimeoutMs int, sendReceipts bool) error {
-	host, port, err := transport.GetHostAndPortFromTcpAddress(address)
+	cfg := n.Config()
+
+	host, port, err := n.listenHostAndPort(address)
 	if err != nil {
 		return err
 	}
 
-	n.replyAddress = fmt.Sprintf("%s:%d", host, port)
+	n.replyAddress = n.nodeAddress(host, port)
 	n.replyPort = port
 
-	serverCfg := server.NewConfig(host, port)
+	// WebSocket servers take the URL, whose path must be requested by callers
+	serverAddress := host
+	if n.webSocket != nil {
+		serverAddress = address
+	}
+
+	serverCfg := server.NewConfig(serverAddress, port)
 	serverCfg.ClientConnectionLimit = connectionLimit
 	serverCfg.IdleConnectionTimeoutMs = idleConnTimeoutMs
+	serverCfg.HostConnectionLimit = cfg.HostConnectionLimit
+	serverCfg.AllowList = cfg.AllowList
+	serverCfg.DenyList = cfg.DenyList
+	serverCfg.Listener = cfg.Listener
+	serverCfg.SocketActivation = cfg.SocketActivation
+	serverCfg.SocketActivationName = cfg.SocketActivationName
+	serverCfg.SocketOptions = cfg.SocketOptions
+	serverCfg.Interface = cfg.Interface
+	serverCfg.ResolveHostnames = cfg.ResolveHostnames
+	serverCfg.Resolver = cfg.Resolver
+	serverCfg.TLSConfig = cfg.TLSConfig
+	serverCfg.MemoryLatencyMs = cfg.MemoryLatencyMs
 
 	s, err := server.New(serverCfg)
 	if err != nil {
@@ -146,16 +283,63 @@ func (n *TcpNode[T]) startServer(address string, connectionLimit int, idleConnTi
 		}
 	}()
 
+	go n.forwardAccessErrors(s.Errors(), n.stopChan)
+
 	go n.pruneIdleConnections()
 
 	return nil
 }
 
-func (n *TcpNode[T]) handleIncomingConnection(conn socket.Connection, idleTimeoutMs int, sendReceipts bool) {
-	callerHost, _, err := transport.GetHostAndPortFromTcpAddress(conn.RemoteAddress())
+// listenHostAndPort Returns the host and port of Address, which is either
+// host:port or a ws:// or wss:// URL, or else the mem:// address itself
+// (with no port)
+func (n *TcpNode[T]) listenHostAndPort(address string) (string, uint16, error) {
+	n.webSocket = nil
+	n.memory = transport.IsMemoryAddress(address)
+	if n.memory {
+		return address, 0, nil
+	}
+	if !transport.IsWebSocketAddress(address) {
+		return transport.GetHostAndPortFromHostnameOrAddress(address, n.Config().ResolveHostnames)
+	}
+
+	wsURL, err := transport.ParseWebSocketAddress(address, 0)
 	if err != nil {
-		n.SendError(err)
-		return
+		return "", 0, err
+	}
+	n.webSocket = &wsURL
+
+	return wsURL.Host, wsURL.Port, nil
+}
+
+// forwardAccessErrors Report incoming connections rejected by the server
+// (see AllowList, DenyList and HostConnectionLimit) through Errors()
+func (n *TcpNode[T]) forwardAccessErrors(errs <-chan error, stopChan <-chan bool) {
+	for {
+		select {
+		case err, ok := <-errs:
+			if !ok {
+				return
+			}
+			if comerr.Is(err, comerr.ErrConnectionDenied) || comerr.Is(err, comerr.ErrHostConnectionLimit) {
+				n.SendError(err)
+			}
+		case <-stopChan:
+			return
+		}
+	}
+}
+
+func (n *TcpNode[T]) handleIncomingConnection(conn socket.Connection, idleTimeoutMs int, sendReceipts bool) {
+	// mem:// callers dial from their own address (see addOutgoingConnection)
+	callerHost := conn.RemoteAddress()
+	if !n.memory {
+		host, _, err := transport.GetHostAndPortFromTcpAddress(conn.RemoteAddress())
+		if err != nil {
+			n.SendError(err)
+			return
+		}
+		callerHost = host
 	}
 
 	c := NewConnection(conn.RemoteAddress(), int64(idleTimeoutMs), n.closeConnection)
@@ -171,26 +355,64 @@ func (n *TcpNode[T]) handleIncomingConnection(conn socket.Connection, idleTimeou
 
 	n.connections.Store(c.ID(), c)
 
+	// Overflow is handled when delivering to Recv() as per RecvOverflowPolicy
+	ms := &MessageStream[T]{OverflowPolicy: stream.Block}
+	n.streams.Store(c.ID(), ms)
+	defer func() {
+		n.streams.Delete(c.ID())
+		_ = ms.Close()
+	}()
+
 	// Receive incoming messages until the connection is closed
-	for msg := range NewMessageStream[T](conn) {
-		msg.receivedOn = time.Now().UTC()
-		msg.fromNode = fmt.Sprintf("%s:%d", callerHost, msg.replyPort)
-		msg.toNode = n.replyAddress
+	for msg := range ms.Stream(conn) {
+		if !n.deliver(msg, callerHost, conn, sendReceipts) {
+			return
+		}
+	}
+}
 
-		n.incomingChan <- msg
+// deliver Returns false if the node is no longer running or the connection
+// should be closed as per RecvOverflowPolicy
+func (n *TcpNode[T]) deliver(msg *Message[T], callerHost string, conn socket.Connection, sendReceipt bool) bool {
+	n.inFlight.Add(1)
+	defer n.inFlight.Add(-1)
 
-		if !n.IsRunning() {
-			close(n.incomingChan)
-			return
+	msg.receivedOn = time.Now().UTC()
+	msg.fromNode = n.nodeAddress(callerHost, msg.replyPort)
+	msg.toNode = n.replyAddress
+
+	policy := n.Config().RecvOverflowPolicy
+	if !stream.Send(n.incomingChan, msg, policy, n.stopChan, n.dropMessage) {
+		if policy == stream.CloseConnection {
+			return false
 		}
+		// Receipts are only sent for delivered messages
+		return n.IsRunning()
+	}
 
-		if sendReceipts {
-			e := n.sendReceipt(msg, conn)
-			if e != nil {
-				n.SendError(e)
-			}
+	if !n.IsRunning() {
+		close(n.incomingChan)
+		return false
+	}
+
+	if sendReceipt {
+		e := n.sendReceipt(msg, conn)
+		if e != nil {
+			n.SendError(e)
 		}
 	}
+
+	return true
+}
+
+func (n *TcpNode[T]) dropMessage(msg *Message[T]) {
+	n.droppedMessages.Add(1)
+	n.SendError(fmt.Errorf("%w : dropped message %d from %s", comerr.ErrChannelOverflow, msg.ID(), msg.fromNode))
+}
+
+func (n *TcpNode[T]) dropReceipt(rcpt *Message[T]) {
+	n.droppedReceipts.Add(1)
+	n.SendError(fmt.Errorf("%w : dropped receipt for message %d from %s", comerr.ErrChannelOverflow, rcpt.ID(), rcpt.fromNode))
 }
 
 func (n *TcpNode[T]) sendReceipt(message *Message[T], conn socket.Connection) error {
@@ -217,12 +439,24 @@ func (n *TcpNode[T]) addOutgoingConnection(toNode string) (*Connection, error) {
 		return nil, err
 	}
 
-	calleeHost, calleePort, err := transport.GetHostAndPortFromTcpAddress(toNode)
+	clientCfg, calleeAddress, err := n.calleeConfig(toNode)
 	if err != nil {
 		return nil, err
 	}
 
-	clientCfg := client.NewConfig(calleeHost, calleePort)
+	clientCfg.SocketOptions = cfg.SocketOptions
+	clientCfg.ResolveHostnames = cfg.ResolveHostnames
+	clientCfg.Resolver = cfg.Resolver
+	clientCfg.DialProxy = cfg.DialProxy
+	clientCfg.DialProxyFromEnvironment = cfg.DialProxyFromEnvironment
+	clientCfg.TLSConfig = cfg.TLSConfig
+	clientCfg.MemoryLatencyMs = cfg.MemoryLatencyMs
+
+	// Callers are identified by their source address, which must be the one the server is bound to
+	clientCfg.LocalAddress = cfg.Interface
+	if n.memory {
+		clientCfg.LocalAddress = n.replyAddress
+	}
 	c, err := client.New(clientCfg)
 	if err != nil {
 		return nil, err
@@ -240,16 +474,25 @@ func (n *TcpNode[T]) addOutgoingConnection(toNode string) (*Connection, error) {
 	n.connections.Store(conn.ID(), conn)
 
 	go func() {
+		// Overflow is handled when delivering to Status() as per StatusOverflowPolicy
+		ms := &MessageStream[T]{OverflowPolicy: stream.Block}
+		defer func() {
+			_ = ms.Close()
+		}()
+
 		// Receive incoming message receipts until the connection is closed
-		for rcpt := range NewMessageStream[T](c) {
+		for rcpt := range ms.Stream(c) {
 			rcpt.receivedOn = time.Now().UTC()
-			rcpt.fromNode = fmt.Sprintf("%s:%d", calleeHost, rcpt.replyPort)
+			rcpt.fromNode = calleeAddress(rcpt.replyPort)
 			rcpt.toNode = n.replyAddress
 
-			select {
-			case n.statusChan <- rcpt:
+			policy := n.Config().StatusOverflowPolicy
+			if !stream.Send(n.statusChan, rcpt, policy, n.stopChan, n.dropReceipt) && policy == stream.CloseConnection {
+				e := conn.Close()
+				if e != nil {
+					n.SendError(e)
+				}
 				break
-			default:
 			}
 
 			if !n.IsRunning() {
@@ -269,6 +512,38 @@ func (n *TcpNode[T]) addOutgoingConnection(toNode string) (*Connection, error) {
 	return conn, nil
 }
 
+// calleeConfig Returns the configuration of the client connecting to the
+// node at toNode, which is either host:port, a ws:// or wss:// URL or a
+// mem:// address, along with a function returning the address of that node
+// listening on a given port (as per the receipts it sends)
+func (n *TcpNode[T]) calleeConfig(toNode string) (_client.Config, func(uint16) string, error) {
+	if transport.IsMemoryAddress(toNode) {
+		return client.NewConfig(toNode, 0), func(uint16) string {
+			return toNode
+		}, nil
+	}
+
+	if transport.IsWebSocketAddress(toNode) {
+		wsURL, err := transport.ParseWebSocketAddress(toNode, 0)
+		if err != nil {
+			return _client.Config{}, nil, err
+		}
+
+		return client.NewConfig(toNode, wsURL.Port), func(port uint16) string {
+			return wsURL.WithHostPort(wsURL.Host, port).String()
+		}, nil
+	}
+
+	calleeHost, calleePort, err := transport.GetHostAndPortFromHostnameOrAddress(toNode, n.Config().ResolveHostnames)
+	if err != nil {
+		return _client.Config{}, nil, err
+	}
+
+	return client.NewConfig(calleeHost, calleePort), func(port uint16) string {
+		return fmt.Sprintf("%s:%d", calleeHost, port)
+	}, nil
+}
+
 func (n *TcpNode[T]) verifyConnectionLimit(connectionLimit int) error {
 	if connectionLimit < 0 {
 		connectionLimit = 4096
@@ -319,6 +594,7 @@ func (n *TcpNode[T]) pruneIdleConnections() {
 			}
 			return true
 		})
+		n.forgetIdlePeers()
 
 		time.Sleep(500 * time.Millisecond)
 
@@ -0,0 +1,105 @@
+package poll
+
+import (
+	"errors"
+	"golang.org/x/sys/unix"
+	"sync/atomic"
+)
+
+// Poller Waits for any number of sockets to become readable using a single
+// epoll instance, rather than a Go routine (and read deadline) per socket.
+// Sockets are armed one-shot: once reported readable a socket is not
+// reported again until it is re-armed, so each socket is only ever handled
+// by one Go routine at a time.
+// Thread-safe ✓
+type Poller struct {
+	epollFd int
+	wakeFd  int // eventfd used to wake Wait when the poller is closed
+	closed  atomic.Bool
+}
+
+const readEvents = unix.EPOLLIN | unix.EPOLLRDHUP | unix.EPOLLONESHOT
+
+func NewPoller() (*Poller, error) {
+	epollFd, err := unix.EpollCreate1(unix.EPOLL_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+
+	wakeFd, err := unix.Eventfd(0, unix.EFD_NONBLOCK|unix.EFD_CLOEXEC)
+	if err != nil {
+		_ = unix.Close(epollFd)
+		return nil, err
+	}
+
+	err = unix.EpollCtl(epollFd, unix.EPOLL_CTL_ADD, wakeFd, &unix.EpollEvent{Events: unix.EPOLLIN, Fd: int32(wakeFd)})
+	if err != nil {
+		_ = unix.Close(wakeFd)
+		_ = unix.Close(epollFd)
+		return nil, err
+	}
+
+	return &Poller{epollFd: epollFd, wakeFd: wakeFd}, nil
+}
+
+// Add Start watching fd, which is armed immediately
+func (p *Poller) Add(fd int) error {
+	return unix.EpollCtl(p.epollFd, unix.EPOLL_CTL_ADD, fd, &unix.EpollEvent{Events: readEvents, Fd: int32(fd)})
+}
+
+// Rearm Watch fd for the next time it is readable, after it was reported
+func (p *Poller) Rearm(fd int) error {
+	return unix.EpollCtl(p.epollFd, unix.EPOLL_CTL_MOD, fd, &unix.EpollEvent{Events: readEvents, Fd: int32(fd)})
+}
+
+// Remove Stop watching fd, which must be done before fd is closed
+func (p *Poller) Remove(fd int) error {
+	err := unix.EpollCtl(p.epollFd, unix.EPOLL_CTL_DEL, fd, nil)
+	if errors.Is(err, unix.ENOENT) || errors.Is(err, unix.EBADF) {
+		return nil
+	}
+	return err
+}
+
+// Wait Invoke onReadable for each socket reported readable (or hung up)
+// until the poller is closed, releasing the epoll instance on return.
+// Must only be called once.
+func (p *Poller) Wait(onReadable func(fd int)) error {
+	defer func() {
+		_ = unix.Close(p.wakeFd)
+		_ = unix.Close(p.epollFd)
+	}()
+
+	events := make([]unix.EpollEvent, 256)
+
+	for {
+		count, err := unix.EpollWait(p.epollFd, events, -1)
+		if p.closed.Load() {
+			return nil
+		}
+		if err != nil {
+			if errors.Is(err, unix.EINTR) {
+				continue
+			}
+			return err
+		}
+
+		for i := 0; i < count; i++ {
+			fd := int(events[i].Fd)
+			if fd == p.wakeFd {
+				continue
+			}
+			onReadable(fd)
+		}
+	}
+}
+
+// Close Wake Wait so that it returns
+func (p *Poller) Close() error {
+	if !p.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+
+	_, err := unix.Write(p.wakeFd, []byte{1, 0, 0, 0, 0, 0, 0, 0})
+	return err
+}
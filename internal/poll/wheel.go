@@ -0,0 +1,127 @@
+package poll
+
+import (
+	"sync"
+	"time"
+)
+
+// TimerWheel Hashed timing wheel used to expire large numbers of keys (e.g.,
+// idle connections) at the cost of one map operation per Reset, rather than
+// periodically checking every key.  Expiry is accurate to within one tick.
+// Thread-safe ✓
+type TimerWheel struct {
+	mutex    sync.Mutex
+	tick     time.Duration
+	slots    []map[uint64]int // key -> remaining rotations
+	keySlots map[uint64]int   // key -> slot index
+	cursor   int
+
+	onExpire func(uint64)
+	stopChan chan bool
+	stopOnce sync.Once
+}
+
+// NewTimerWheel Keys are passed to onExpire (on the wheel's Go routine)
+// once their timeout elapses, unless Reset or Removed before then.
+func NewTimerWheel(tick time.Duration, slotCount int, onExpire func(uint64)) *TimerWheel {
+	if slotCount < 1 {
+		slotCount = 1
+	}
+
+	w := &TimerWheel{
+		tick:     tick,
+		slots:    make([]map[uint64]int, slotCount),
+		keySlots: make(map[uint64]int),
+		onExpire: onExpire,
+		stopChan: make(chan bool),
+	}
+	for i := range w.slots {
+		w.slots[i] = make(map[uint64]int)
+	}
+
+	go w.run()
+
+	return w
+}
+
+// Reset (Re)schedule key to expire after timeout
+func (w *TimerWheel) Reset(key uint64, timeout time.Duration) {
+	ticks := int((timeout + w.tick - 1) / w.tick)
+	if ticks < 1 {
+		ticks = 1
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.remove(key)
+
+	slot := (w.cursor + ticks) % len(w.slots)
+	w.slots[slot][key] = (ticks - 1) / len(w.slots)
+	w.keySlots[key] = slot
+}
+
+func (w *TimerWheel) Remove(key uint64) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.remove(key)
+}
+
+func (w *TimerWheel) Len() int {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return len(w.keySlots)
+}
+
+func (w *TimerWheel) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stopChan)
+	})
+}
+
+func (w *TimerWheel) remove(key uint64) {
+	if slot, ok := w.keySlots[key]; ok {
+		delete(w.slots[slot], key)
+		delete(w.keySlots, key)
+	}
+}
+
+func (w *TimerWheel) run() {
+	ticker := time.NewTicker(w.tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stopChan:
+			return
+		case <-ticker.C:
+			for _, key := range w.advance() {
+				w.onExpire(key)
+			}
+		}
+	}
+}
+
+// advance Move to the next slot, returning the keys that expired
+func (w *TimerWheel) advance() []uint64 {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.cursor = (w.cursor + 1) % len(w.slots)
+	slot := w.slots[w.cursor]
+
+	var expired []uint64
+	for key, rotations := range slot {
+		if rotations > 0 {
+			slot[key] = rotations - 1
+			continue
+		}
+		expired = append(expired, key)
+		delete(slot, key)
+		delete(w.keySlots, key)
+	}
+
+	return expired
+}
@@ -0,0 +1,59 @@
+package poll
+
+import "sync"
+
+// WorkerPool Runs submitted tasks on a fixed number of Go routines
+// Thread-safe ✓
+type WorkerPool struct {
+	mutex     sync.RWMutex
+	closed    bool
+	tasks     chan func()
+	waitGroup sync.WaitGroup
+}
+
+// NewWorkerPool Starts size workers (at least one) sharing a queue of
+// queueSize pending tasks, beyond which Submit blocks.
+func NewWorkerPool(size int, queueSize int) *WorkerPool {
+	if size < 1 {
+		size = 1
+	}
+
+	p := &WorkerPool{tasks: make(chan func(), queueSize)}
+
+	p.waitGroup.Add(size)
+	for i := 0; i < size; i++ {
+		go func() {
+			defer p.waitGroup.Done()
+			for task := range p.tasks {
+				task()
+			}
+		}()
+	}
+
+	return p
+}
+
+// Submit Queue the task, returning false if the pool is closed
+func (p *WorkerPool) Submit(task func()) bool {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	if p.closed {
+		return false
+	}
+
+	p.tasks <- task
+	return true
+}
+
+// Close Stop accepting tasks and wait for those already queued to finish
+func (p *WorkerPool) Close() {
+	p.mutex.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.tasks)
+	}
+	p.mutex.Unlock()
+
+	p.waitGroup.Wait()
+}
@@ -0,0 +1,254 @@
+package proxyproto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"tonysoft.com/comm/pkg/comerr"
+)
+
+// Command Whether the connection was proxied on behalf of a client, or
+// opened by the proxy itself (e.g., for a health check)
+type Command byte
+
+const (
+	Local Command = 0x0
+	Proxy Command = 0x1
+)
+
+// TLV A type-length-value vector from a version 2 header, such as
+// PP2_TYPE_AUTHORITY (0x02) or vendor specific values (0xE0-0xEF)
+type TLV struct {
+	Type  byte
+	Value []byte
+}
+
+// Header A HAProxy PROXY protocol header, as sent by a proxy (or load
+// balancer) ahead of the proxied data to convey the real client address.
+// Source and Destination are nil for Local headers and protocols other
+// than TCP (UDP and UNIX addresses are skipped).
+type Header struct {
+	Version     int // 1 (text) or 2 (binary)
+	Command     Command
+	Source      *net.TCPAddr
+	Destination *net.TCPAddr
+	TLVs        []TLV
+}
+
+const (
+	v1Prefix    = "PROXY "
+	v1MaxLength = 107
+
+	familyUnspec     = 0x00
+	familyTcp4       = 0x11
+	familyUdp4       = 0x12
+	familyTcp6       = 0x21
+	familyUdp6       = 0x22
+	familyUnixStream = 0x31
+	familyUnixDgram  = 0x32
+)
+
+var v2Signature = []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+
+// Read Reads a version 1 or 2 header from r without reading past its end,
+// so that r can be used for the proxied data once the header is read.
+func Read(r io.Reader) (*Header, error) {
+	// The shortest header (v1 "PROXY UNKNOWN\r\n") is longer than the v2 signature
+	start := make([]byte, len(v2Signature))
+	if _, err := io.ReadFull(r, start); err != nil {
+		return nil, invalid(err)
+	}
+
+	if bytes.Equal(start, v2Signature) {
+		return readV2(r)
+	}
+	if bytes.HasPrefix(start, []byte(v1Prefix)) {
+		return readV1(r, start)
+	}
+
+	return nil, invalid(fmt.Errorf("unknown signature"))
+}
+
+func readV1(r io.Reader, start []byte) (*Header, error) {
+	line := append([]byte{}, start...)
+	b := make([]byte, 1)
+
+	for !bytes.HasSuffix(line, []byte("\r\n")) {
+		if len(line) >= v1MaxLength {
+			return nil, invalid(fmt.Errorf("version 1 header exceeds %d bytes", v1MaxLength))
+		}
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, invalid(err)
+		}
+		line = append(line, b[0])
+	}
+
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	h := &Header{Version: 1, Command: Proxy}
+
+	switch {
+	case len(fields) >= 2 && fields[1] == "UNKNOWN":
+		h.Command = Local
+		return h, nil
+	case len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6"):
+		return nil, invalid(fmt.Errorf("malformed version 1 header"))
+	}
+
+	var err error
+	if h.Source, err = parseV1Addr(fields[2], fields[4]); err != nil {
+		return nil, err
+	}
+	if h.Destination, err = parseV1Addr(fields[3], fields[5]); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+func parseV1Addr(host string, port string) (*net.TCPAddr, error) {
+	ip := net.ParseIP(host)
+	p, err := strconv.ParseUint(port, 10, 16)
+	if ip == nil || err != nil {
+		return nil, invalid(fmt.Errorf("malformed address %s:%s", host, port))
+	}
+	return &net.TCPAddr{IP: ip, Port: int(p)}, nil
+}
+
+func readV2(r io.Reader) (*Header, error) {
+	fixed := make([]byte, 4)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return nil, invalid(err)
+	}
+
+	if fixed[0]>>4 != 2 {
+		return nil, invalid(fmt.Errorf("unsupported version %d", fixed[0]>>4))
+	}
+
+	h := &Header{Version: 2, Command: Command(fixed[0] & 0x0F)}
+	if h.Command != Local && h.Command != Proxy {
+		return nil, invalid(fmt.Errorf("unsupported command %d", h.Command))
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint16(fixed[2:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, invalid(err)
+	}
+
+	var addrLength int
+	switch fixed[1] {
+	case familyTcp4, familyUdp4:
+		addrLength = 12
+	case familyTcp6, familyUdp6:
+		addrLength = 36
+	case familyUnixStream, familyUnixDgram:
+		addrLength = 216
+	}
+
+	if len(payload) < addrLength {
+		return nil, invalid(fmt.Errorf("address block is truncated"))
+	}
+
+	// Addresses are ignored for Local headers, as they are for families other than TCP
+	if h.Command == Proxy && (fixed[1] == familyTcp4 || fixed[1] == familyTcp6) {
+		ipLength := (addrLength - 4) / 2
+		h.Source = &net.TCPAddr{
+			IP:   net.IP(append([]byte{}, payload[:ipLength]...)),
+			Port: int(binary.BigEndian.Uint16(payload[2*ipLength:])),
+		}
+		h.Destination = &net.TCPAddr{
+			IP:   net.IP(append([]byte{}, payload[ipLength:2*ipLength]...)),
+			Port: int(binary.BigEndian.Uint16(payload[2*ipLength+2:])),
+		}
+	}
+
+	tlvs := payload[addrLength:]
+	for len(tlvs) > 0 {
+		if len(tlvs) < 3 {
+			return nil, invalid(fmt.Errorf("TLV is truncated"))
+		}
+		length := int(binary.BigEndian.Uint16(tlvs[1:]))
+		if len(tlvs) < 3+length {
+			return nil, invalid(fmt.Errorf("TLV is truncated"))
+		}
+		h.TLVs = append(h.TLVs, TLV{Type: tlvs[0], Value: append([]byte{}, tlvs[3:3+length]...)})
+		tlvs = tlvs[3+length:]
+	}
+
+	return h, nil
+}
+
+// TLV Returns the value of the first TLV of the given type, if any
+func (h *Header) TLV(tlvType byte) ([]byte, bool) {
+	for _, tlv := range h.TLVs {
+		if tlv.Type == tlvType {
+			return tlv.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Bytes Encodes the header as per its Version (TLVs are dropped from
+// version 1 headers, which do not support them)
+func (h *Header) Bytes() ([]byte, error) {
+	if h.Version == 1 {
+		return h.v1Bytes(), nil
+	}
+	return h.v2Bytes()
+}
+
+func (h *Header) v1Bytes() []byte {
+	if h.Command == Local || h.Source == nil || h.Destination == nil {
+		return []byte("PROXY UNKNOWN\r\n")
+	}
+
+	protocol := "TCP4"
+	if h.Source.IP.To4() == nil {
+		protocol = "TCP6"
+	}
+
+	return []byte(fmt.Sprintf("PROXY %s %s %s %d %d\r\n", protocol,
+		h.Source.IP.String(), h.Destination.IP.String(), h.Source.Port, h.Destination.Port))
+}
+
+func (h *Header) v2Bytes() ([]byte, error) {
+	var family byte = familyUnspec
+	var addresses []byte
+
+	if h.Command == Proxy && h.Source != nil && h.Destination != nil {
+		srcIP, dstIP := h.Source.IP.To4(), h.Destination.IP.To4()
+		family = familyTcp4
+		if srcIP == nil || dstIP == nil {
+			srcIP, dstIP = h.Source.IP.To16(), h.Destination.IP.To16()
+			family = familyTcp6
+		}
+
+		addresses = append(addresses, srcIP...)
+		addresses = append(addresses, dstIP...)
+		addresses = binary.BigEndian.AppendUint16(addresses, uint16(h.Source.Port))
+		addresses = binary.BigEndian.AppendUint16(addresses, uint16(h.Destination.Port))
+	}
+
+	payload := addresses
+	for _, tlv := range h.TLVs {
+		payload = append(payload, tlv.Type)
+		payload = binary.BigEndian.AppendUint16(payload, uint16(len(tlv.Value)))
+		payload = append(payload, tlv.Value...)
+	}
+
+	if len(payload) > 0xFFFF {
+		return nil, invalid(fmt.Errorf("header exceeds %d bytes", 0xFFFF))
+	}
+
+	b := append([]byte{}, v2Signature...)
+	b = append(b, 0x20|byte(h.Command), family)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(payload)))
+	return append(b, payload...), nil
+}
+
+func invalid(err error) error {
+	return fmt.Errorf("%w : %v", comerr.ErrInvalidProxyHeader, err)
+}
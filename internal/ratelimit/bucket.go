@@ -0,0 +1,122 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Bucket Thread-safe token bucket that refills at a fixed rate (tokens per
+// second) up to its burst size.  Taking more tokens than are available puts
+// the bucket in debt, so that requests larger than the burst size are still
+// possible but delay subsequent ones accordingly.
+type Bucket struct {
+	mutex  sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewBucket A burst <1 defaults to one second's worth of tokens
+func NewBucket(rate float64, burst int) *Bucket {
+	b := &Bucket{
+		rate:  rate,
+		burst: float64(burst),
+		last:  time.Now(),
+	}
+	if b.burst < 1 {
+		b.burst = math.Max(rate, 1)
+	}
+	b.tokens = b.burst
+	return b
+}
+
+// Allow Take n tokens if they are available right now, otherwise take none
+func (b *Bucket) Allow(n int) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.refill()
+
+	if b.tokens < float64(n) {
+		return false
+	}
+
+	b.tokens -= float64(n)
+	return true
+}
+
+// Wait Take n tokens, blocking until the bucket has recovered from any debt
+// incurred, or until the context is done (in which case the tokens are returned).
+func (b *Bucket) Wait(ctx context.Context, n int) error {
+	b.mutex.Lock()
+	b.refill()
+	b.tokens -= float64(n)
+	delay := b.delay()
+	b.mutex.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	select {
+	case <-time.After(delay):
+		return nil
+	case <-ctx.Done():
+		b.Return(n)
+		return ctx.Err()
+	}
+}
+
+// Take Take n tokens without waiting, putting the bucket in debt if there
+// are not enough
+func (b *Bucket) Take(n int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.refill()
+	b.tokens -= float64(n)
+}
+
+// Return Give back n tokens that were taken but not used
+func (b *Bucket) Return(n int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.tokens = math.Min(b.burst, b.tokens+float64(n))
+}
+
+// Full Returns true if the bucket has refilled to its burst size
+func (b *Bucket) Full() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.refill()
+	return b.tokens >= b.burst
+}
+
+// InDebt Returns true if more tokens were taken than were available
+func (b *Bucket) InDebt() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.refill()
+	return b.tokens < 0
+}
+
+// refill Must be called while holding the mutex
+func (b *Bucket) refill() {
+	now := time.Now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+// delay Must be called while holding the mutex
+func (b *Bucket) delay() time.Duration {
+	if b.tokens >= 0 || b.rate <= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
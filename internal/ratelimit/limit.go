@@ -0,0 +1,198 @@
+package ratelimit
+
+import (
+	"context"
+	"tonysoft.com/comm/pkg/comerr"
+)
+
+// Mode Determines what happens to an operation that exceeds a Limit
+type Mode int
+
+const (
+	// Block Wait until the limit allows the operation
+	Block Mode = iota
+	// Reject Fail the operation with comerr.ErrRateLimited
+	Reject
+)
+
+func (m Mode) String() string {
+	switch m {
+	case Block:
+		return "Block"
+	case Reject:
+		return "Reject"
+	}
+	return "Unknown"
+}
+
+// Limit Token bucket limits on the bytes and messages (reads/writes, or
+// node messages) per second, where 0 means no limit.  A burst <1 defaults
+// to one second's worth.
+type Limit struct {
+	BytesPerSec    float64
+	BytesBurst     int
+	MessagesPerSec float64
+	MessagesBurst  int
+	Mode           Mode
+}
+
+// IsZero Returns true if the limit does not limit anything
+func (l Limit) IsZero() bool {
+	return l.BytesPerSec <= 0 && l.MessagesPerSec <= 0
+}
+
+// Limiter Applies a Limit, a nil Limiter allows everything
+// Thread-safe ✓
+type Limiter struct {
+	mode     Mode
+	bytes    *Bucket
+	messages *Bucket
+}
+
+// NewLimiter Returns nil if the limit does not limit anything
+func NewLimiter(limit Limit) *Limiter {
+	if limit.IsZero() {
+		return nil
+	}
+
+	l := &Limiter{mode: limit.Mode}
+	if limit.BytesPerSec > 0 {
+		l.bytes = NewBucket(limit.BytesPerSec, limit.BytesBurst)
+	}
+	if limit.MessagesPerSec > 0 {
+		l.messages = NewBucket(limit.MessagesPerSec, limit.MessagesBurst)
+	}
+	return l
+}
+
+// Acquire Take one message and n bytes before they are written
+func (l *Limiter) Acquire(ctx context.Context, n int) error {
+	if l == nil {
+		return nil
+	}
+
+	if l.mode == Reject {
+		if l.messages != nil && !l.messages.Allow(1) {
+			return comerr.ErrRateLimited
+		}
+		if l.bytes != nil && !l.bytes.Allow(n) {
+			if l.messages != nil {
+				l.messages.Return(1)
+			}
+			return comerr.ErrRateLimited
+		}
+		return nil
+	}
+
+	if l.messages != nil {
+		if err := l.messages.Wait(ctx, 1); err != nil {
+			return err
+		}
+	}
+	if l.bytes != nil {
+		if err := l.bytes.Wait(ctx, n); err != nil {
+			if l.messages != nil {
+				l.messages.Return(1)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Refilled Returns true if the limiter allows as much as a new one would,
+// having been idle for long enough to refill
+func (l *Limiter) Refilled() bool {
+	if l == nil {
+		return true
+	}
+
+	return (l.bytes == nil || l.bytes.Full()) && (l.messages == nil || l.messages.Full())
+}
+
+// release Give back the message and n bytes taken by Acquire
+func (l *Limiter) release(n int) {
+	if l == nil {
+		return
+	}
+
+	if l.bytes != nil {
+		l.bytes.Return(n)
+	}
+	if l.messages != nil {
+		l.messages.Return(1)
+	}
+}
+
+// AcquireRead Wait (or fail, if rejecting) until the bytes and messages
+// previously read (see Consume) have been paid for
+func (l *Limiter) AcquireRead(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	for _, b := range []*Bucket{l.bytes, l.messages} {
+		if b == nil {
+			continue
+		}
+		if l.mode == Reject {
+			if b.InDebt() {
+				return comerr.ErrRateLimited
+			}
+			continue
+		}
+		if err := b.Wait(ctx, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Consume Take one message and the n bytes that were just read, which may
+// put the buckets in debt since the size of a read is only known once it
+// is done.  The next AcquireRead pays for it.
+func (l *Limiter) Consume(n int) {
+	if l == nil || n < 1 {
+		return
+	}
+
+	if l.bytes != nil {
+		l.bytes.Take(n)
+	}
+	if l.messages != nil {
+		l.messages.Take(1)
+	}
+}
+
+// Limiters Applies several limiters (e.g., per connection and per server)
+// in order, nil elements are ignored
+type Limiters []*Limiter
+
+// Acquire Take one message and n bytes from every limiter, giving back what
+// was taken from the others if one fails
+func (ls Limiters) Acquire(ctx context.Context, n int) error {
+	for i, l := range ls {
+		if err := l.Acquire(ctx, n); err != nil {
+			for _, acquired := range ls[:i] {
+				acquired.release(n)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (ls Limiters) AcquireRead(ctx context.Context) error {
+	for _, l := range ls {
+		if err := l.AcquireRead(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ls Limiters) Consume(n int) {
+	for _, l := range ls {
+		l.Consume(n)
+	}
+}
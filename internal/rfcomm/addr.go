@@ -0,0 +1,52 @@
+package rfcomm
+
+import (
+	"fmt"
+	"golang.org/x/sys/unix"
+)
+
+// Addr Implements net.Addr for RFCOMM sockets, where the "port" is the channel
+type Addr struct {
+	MAC     string
+	Channel uint8
+}
+
+func (a *Addr) Network() string {
+	return "rfcomm"
+}
+
+func (a *Addr) String() string {
+	return fmt.Sprintf("[%s]:%d", a.MAC, a.Channel)
+}
+
+// LocalAddr Returns the address the socket is bound to, or nil if unknown
+func LocalAddr(sock int) *Addr {
+	sa, err := unix.Getsockname(sock)
+	if err != nil {
+		return nil
+	}
+	return AddrFromSockaddr(sa)
+}
+
+// RemoteAddr Returns the address the socket is connected to, or nil if unknown
+func RemoteAddr(sock int) *Addr {
+	sa, err := unix.Getpeername(sock)
+	if err != nil {
+		return nil
+	}
+	return AddrFromSockaddr(sa)
+}
+
+func AddrFromSockaddr(sa unix.Sockaddr) *Addr {
+	rc, ok := sa.(*unix.SockaddrRFCOMM)
+	if !ok {
+		return nil
+	}
+
+	mac, err := ByteArrayToMacString(rc.Addr)
+	if err != nil {
+		return nil
+	}
+
+	return &Addr{MAC: mac, Channel: rc.Channel}
+}
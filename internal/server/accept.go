@@ -0,0 +1,111 @@
+package server
+
+import (
+	"fmt"
+	"tonysoft.com/comm/internal/acl"
+	"tonysoft.com/comm/internal/socket"
+	"tonysoft.com/comm/internal/stream"
+	"tonysoft.com/comm/pkg/comerr"
+)
+
+// DroppedConnections Returns the number of connections closed because the
+// Accept channel's buffer was full, as per AcceptOverflowPolicy.
+func (s *BaseServer) DroppedConnections() uint64 {
+	return s.droppedConnections.Load()
+}
+
+// DroppedDatagrams Returns the number of datagrams dropped because the queue
+// of their UDP session was full (see UdpSessionQueueSize), 0 for other servers.
+func (s *BaseServer) DroppedDatagrams() uint64 {
+	return s.droppedDatagrams.Load()
+}
+
+// acceptConnection Send the new connection on the Accept channel as per
+// AcceptOverflowPolicy, closing any connection that ends up being dropped
+// so that connections are never registered without being surfaced.
+func (s *BaseServer) acceptConnection(conn socket.Connection) {
+	s.acceptMutex.RLock()
+	defer s.acceptMutex.RUnlock()
+
+	if s.acceptClosed {
+		_ = conn.Close()
+		return
+	}
+
+	onDrop := func(c socket.Connection) {
+		s.droppedConnections.Add(1)
+		_ = c.Close()
+		s.SendError(fmt.Errorf("%w : dropped connection from %s", comerr.ErrChannelOverflow, c.RemoteAddress()))
+	}
+
+	policy := s.Config().AcceptOverflowPolicy
+	if !stream.Send(s.newConnChan, conn, policy, s.acceptDoneChan, onDrop) && policy == stream.Block {
+		// The server stopped while waiting
+		_ = conn.Close()
+	}
+}
+
+// configureAccess Called on Start to parse AllowList and DenyList
+func (s *BaseServer) configureAccess() error {
+	cfg := s.Config()
+
+	filter, err := acl.New(cfg.AllowList, cfg.DenyList)
+	if err != nil {
+		return err
+	}
+	s.accessFilter = filter
+
+	return nil
+}
+
+// verifyAccess Checks the remote address against AllowList, DenyList and
+// HostConnectionLimit before a connection from it is registered
+func (s *BaseServer) verifyAccess(remoteAddress string) error {
+	host := acl.Host(remoteAddress)
+
+	if !s.accessFilter.Allowed(host) {
+		return fmt.Errorf("%w : %s", comerr.ErrConnectionDenied, remoteAddress)
+	}
+
+	limit := s.Config().HostConnectionLimit
+	if limit > 0 && s.hostConnectionCount(host) >= limit {
+		return fmt.Errorf("%w : %s (%d)", comerr.ErrHostConnectionLimit, remoteAddress, limit)
+	}
+
+	return nil
+}
+
+func (s *BaseServer) hostConnectionCount(host string) int {
+	count := 0
+	s.connections.Range(func(_, conn any) bool {
+		if acl.Host(conn.(*Connection).RemoteAddress()) == host {
+			count++
+		}
+		return true
+	})
+	return count
+}
+
+func (s *BaseServer) verifyConnectionLimit(connectionLimit int) error {
+	clientCount := s.ClientCount()
+	if connectionLimit < 0 {
+		connectionLimit = 4096
+	}
+	if clientCount >= connectionLimit {
+		return fmt.Errorf("%w : %d", comerr.ErrConnectionLimitReached, clientCount)
+	}
+
+	return nil
+}
+
+// closeAccept Close the Accept channel, waking any sender that is blocked
+// on it and waiting for it to return first.
+func (s *BaseServer) closeAccept() {
+	close(s.acceptDoneChan)
+
+	s.acceptMutex.Lock()
+	defer s.acceptMutex.Unlock()
+
+	s.acceptClosed = true
+	close(s.newConnChan)
+}
@@ -2,11 +2,17 @@ package server
 
 import (
 	"context"
+	"os"
 	"sync"
+	"sync/atomic"
+	"time"
+	"tonysoft.com/comm/internal/acl"
 	"tonysoft.com/comm/internal/comerr"
 	"tonysoft.com/comm/internal/comobj"
 	"tonysoft.com/comm/internal/config"
 	_server "tonysoft.com/comm/internal/config/server"
+	"tonysoft.com/comm/internal/netif"
+	"tonysoft.com/comm/internal/ratelimit"
 	"tonysoft.com/comm/internal/socket"
 )
 
@@ -20,6 +26,26 @@ type BaseServer struct {
 
 	connections sync.Map // map[socket.ConnectionID]*Connection
 	newConnChan chan socket.Connection
+
+	acceptMutex        sync.RWMutex
+	acceptClosed       bool
+	acceptDoneChan     chan bool
+	droppedConnections atomic.Uint64
+	droppedDatagrams   atomic.Uint64 // see UdpServer.dropDatagram
+	accessFilter       *acl.Filter
+	activatedFile      *os.File // socket taken from systemd, kept for restarts
+	interfaceWatcher   *netif.Watcher
+
+	readLimiter  *ratelimit.Limiter // ServerReadLimit, shared by all connections
+	writeLimiter *ratelimit.Limiter // ServerWriteLimit, shared by all connections
+
+	handlerContext    context.Context
+	handlerCancelFunc context.CancelFunc
+	handlerWaitGroup  sync.WaitGroup
+	activeHandlers    atomic.Int32
+	stoppedChan       chan bool
+
+	eventLoop *eventLoop // nil unless EventLoop is configured
 }
 
 func (s *BaseServer) Stop() {
@@ -32,6 +58,51 @@ func (s *BaseServer) Stop() {
 	}
 }
 
+// shutdown Stops accepting new connections, signals handlers to finish by
+// cancelling their context, then waits for the open connections to be closed
+// before stopping the server.  If ctx is done first the remaining connections
+// are closed forcibly and ctx.Err() is returned.
+func (s *BaseServer) shutdown(ctx context.Context, stopAccepting func() error, stop func()) error {
+	if !s.IsRunning() {
+		return nil
+	}
+
+	stoppedChan := s.stoppedChan
+
+	if !s.IsStopping() {
+		s.SetIsStopping(true)
+
+		err := stopAccepting()
+		if err != nil {
+			s.SendError(err)
+		}
+
+		s.handlerCancelFunc()
+	}
+
+	err := s.awaitDrained(ctx)
+
+	stop()
+	<-stoppedChan
+
+	return err
+}
+
+func (s *BaseServer) awaitDrained(ctx context.Context) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for s.ClientCount() > 0 || s.activeHandlers.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	return nil
+}
+
 func (s *BaseServer) Accept() <-chan socket.Connection {
 	return s.newConnChan
 }
@@ -56,4 +127,54 @@ func (s *BaseServer) clearConnections() {
 		clientLimit = 4096
 	}
 	s.newConnChan = make(chan socket.Connection, clientLimit)
+	s.acceptDoneChan = make(chan bool)
+	s.acceptClosed = false
+}
+
+// configureRateLimits Called on Start to reset the server-wide limits
+func (s *BaseServer) configureRateLimits() {
+	cfg := s.Config()
+	s.readLimiter = ratelimit.NewLimiter(cfg.ServerReadLimit)
+	s.writeLimiter = ratelimit.NewLimiter(cfg.ServerWriteLimit)
+}
+
+// limitConnection Apply the configured rate limits to a new connection
+func (s *BaseServer) limitConnection(conn *Connection) {
+	cfg := s.Config()
+	conn.SetReadLimit(cfg.ConnectionReadLimit)
+	conn.SetWriteLimit(cfg.ConnectionWriteLimit)
+	conn.serverReadLimiter = s.readLimiter
+	conn.serverWriteLimiter = s.writeLimiter
+}
+
+// shardCount The number of listeners to bind, see ListenerShards
+func (s *BaseServer) shardCount() int {
+	if shards := s.Config().ListenerShards; shards > 1 {
+		return shards
+	}
+	return 1
+}
+
+// configureShutdown Called on Start to reset the state used by Shutdown
+func (s *BaseServer) configureShutdown() {
+	s.SetIsStopping(false)
+	s.handlerContext, s.handlerCancelFunc = context.WithCancel(context.Background())
+	s.stoppedChan = make(chan bool)
+}
+
+// ShuttingDown Returns a channel closed once the server begins shutting down
+// (or stops), after which the connections closed are closed gracefully
+func (s *BaseServer) ShuttingDown() <-chan struct{} {
+	return s.handlerContext.Done()
+}
+
+// setStopped Called once the server is closed to release Shutdown callers
+func (s *BaseServer) setStopped() {
+	// Read before the server can be restarted, which replaces it
+	stoppedChan := s.stoppedChan
+
+	s.handlerCancelFunc()
+	s.SetIsRunning(false)
+	s.SetIsStopping(false)
+	close(stoppedChan)
 }
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"tonysoft.com/comm/internal/netif"
+	"tonysoft.com/comm/internal/transport"
+	"tonysoft.com/comm/pkg/comerr"
+)
+
+// bindHost Returns the host to bind to, which is the address of Interface
+// (if set) rather than Address, or the first IPv4 address of Address if it
+// is a hostname and ResolveHostnames is set
+func (s *BaseServer) bindHost() (string, error) {
+	cfg := s.Config()
+	host := s.host()
+	if s.preOpened() {
+		return host, nil
+	}
+
+	if cfg.Interface == "" {
+		if !cfg.ResolveHostnames || !transport.IsHostname(host) {
+			return host, nil
+		}
+		return resolveBindHost(cfg.Resolver, host)
+	}
+
+	ip, err := netif.Address(cfg.Interface)
+	if err != nil {
+		return "", err
+	}
+
+	return ip.String(), nil
+}
+
+// host Returns Address, or its host if it is a ws:// or wss:// URL
+func (s *BaseServer) host() string {
+	address := s.Config().Address
+	if wsURL, err := transport.ParseWebSocketAddress(address, 0); err == nil {
+		return wsURL.Host
+	}
+	return address
+}
+
+func resolveBindHost(resolver transport.Resolver, hostname string) (string, error) {
+	ips, err := transport.ResolveHost(context.Background(), resolver, hostname)
+	if err != nil {
+		return "", err
+	}
+
+	// Listeners are IPv4 only
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			return ip.String(), nil
+		}
+	}
+
+	return "", fmt.Errorf("%w : %s : no IPv4 address", comerr.ErrResolveHostname, hostname)
+}
+
+// watchInterface Calls rebind with the new address of Interface (if set)
+// whenever it changes, e.g., as a DHCP lease is renewed
+func (s *BaseServer) watchInterface(host string, rebind func(host string) error) {
+	cfg := s.Config()
+	if cfg.Interface == "" || s.preOpened() {
+		return
+	}
+
+	s.interfaceWatcher = netif.Watch(cfg.Interface, net.ParseIP(host), func(ip net.IP) {
+		err := rebind(ip.String())
+		if err != nil {
+			s.SendError(err)
+		}
+	})
+}
+
+// stopWatchingInterface Waits for a rebind in progress, if any, so that the
+// listeners can be closed
+func (s *BaseServer) stopWatchingInterface() {
+	s.interfaceWatcher.Close()
+}
+
+// rebind Binds to the new address of Interface before closing the previous
+// listeners, leaving accepted connections open
+func (s *TcpServer) rebind(host string) error {
+	port := s.Config().Port
+	if addr, ok := s.Addr().(*net.TCPAddr); ok {
+		port = uint16(addr.Port)
+	}
+
+	address, err := transport.GetTcpAddressFromHostAndPort(host, port)
+	if err != nil {
+		return err
+	}
+
+	listener, shards, err := s.bind(address)
+	if err != nil {
+		return err
+	}
+
+	previous, previousShards := s.setListeners(listener, shards)
+
+	s.startAccepting()
+
+	for _, l := range append([]net.Listener{previous}, previousShards...) {
+		_ = l.Close()
+	}
+
+	return nil
+}
+
+// rebind Binds to the new address of Interface before closing the previous
+// sockets, leaving sessions open
+func (s *UdpServer) rebind(host string) error {
+	port := s.Config().Port
+	if addr, ok := s.Addr().(*net.UDPAddr); ok {
+		port = uint16(addr.Port)
+	}
+
+	address, err := transport.GetTcpAddressFromHostAndPort(host, port)
+	if err != nil {
+		return err
+	}
+
+	listener, shards, err := s.bind(address)
+	if err != nil {
+		return err
+	}
+
+	previous, previousShards := s.setListeners(listener, shards)
+
+	s.startReading()
+
+	for _, l := range append([]*net.UDPConn{previous}, previousShards...) {
+		_ = l.Close()
+	}
+
+	return nil
+}
@@ -1,9 +1,18 @@
 package server
 
 import (
+	"context"
+	"io"
 	"net"
+	"sync/atomic"
+	"syscall"
 	"time"
+	"tonysoft.com/comm/internal/memnet"
+	"tonysoft.com/comm/internal/proxyproto"
+	"tonysoft.com/comm/internal/ratelimit"
+	"tonysoft.com/comm/internal/rfcomm"
 	"tonysoft.com/comm/internal/socket"
+	"tonysoft.com/comm/internal/websocket"
 )
 
 type Connection struct {
@@ -11,31 +20,134 @@ type Connection struct {
 
 	server ReadWriter
 
+	localAddr  net.Addr
+	remoteAddr net.Addr
+
 	tcpConn    *net.TCPConn
+	wsConn     *websocket.Conn // layered over tcpConn for ws:// and wss:// addresses
 	udpConn    *UdpConn
 	rfcommConn int
+	memConn    *memnet.Conn
+
+	proxyHeader *proxyproto.Header
+
+	readLimiter        atomic.Pointer[ratelimit.Limiter]
+	writeLimiter       atomic.Pointer[ratelimit.Limiter]
+	serverReadLimiter  *ratelimit.Limiter
+	serverWriteLimiter *ratelimit.Limiter
+
+	// Used only by the event loop, see eventLoop
+	polled      bool
+	pollFd      int
+	pollConn    syscall.RawConn
+	pollAdded   bool
+	pollArmed   atomic.Bool
+	pollServing atomic.Bool
 }
 
 func (c *Connection) Read(buffer []byte) (int, error) {
-	return c.server.read(c, buffer)
+	return c.ReadContext(context.Background(), buffer)
 }
 
 func (c *Connection) Write(data []byte) (int, error) {
-	return c.server.write(c, data)
+	return c.WriteContext(context.Background(), data)
+}
+
+func (c *Connection) ReadContext(ctx context.Context, buffer []byte) (int, error) {
+	limiters := ratelimit.Limiters{c.readLimiter.Load(), c.serverReadLimiter}
+
+	err := limiters.AcquireRead(ctx)
+	if err != nil {
+		return -1, err
+	}
+
+	count, err := c.server.read(ctx, c, buffer)
+	limiters.Consume(count)
+	return count, err
+}
+
+func (c *Connection) WriteContext(ctx context.Context, data []byte) (int, error) {
+	limiters := ratelimit.Limiters{c.writeLimiter.Load(), c.serverWriteLimiter}
+
+	err := limiters.Acquire(ctx, len(data))
+	if err != nil {
+		return -1, err
+	}
+
+	return c.server.write(ctx, c, data)
+}
+
+// SetReadLimit Override the ConnectionReadLimit of this connection
+func (c *Connection) SetReadLimit(limit ratelimit.Limit) {
+	c.readLimiter.Store(ratelimit.NewLimiter(limit))
+}
+
+// SetWriteLimit Override the ConnectionWriteLimit of this connection
+func (c *Connection) SetWriteLimit(limit ratelimit.Limit) {
+	c.writeLimiter.Store(ratelimit.NewLimiter(limit))
+}
+
+func (c *Connection) LocalAddr() net.Addr {
+	return c.localAddr
+}
+
+func (c *Connection) RemoteAddr() net.Addr {
+	return c.remoteAddr
 }
 
 func (c *Connection) ConfigureTCP(server ReadWriter, conn *net.TCPConn, idleTimeoutMs int64,
 	closeHandler func(socket.ConnectionID) error) {
 	c.DefaultConnection.Configure(conn.RemoteAddr().String(), idleTimeoutMs, closeHandler)
 	c.server = server
+	c.localAddr = conn.LocalAddr()
+	c.remoteAddr = conn.RemoteAddr()
 	c.tcpConn = conn
 }
 
-func (c *Connection) ConfigureUDP(server ReadWriter, conn *UdpConn) {
+// tcpStream Returns the WebSocket layered over tcpConn, if any, or tcpConn
+func (c *Connection) tcpStream() io.ReadWriter {
+	if c.wsConn != nil {
+		return c.wsConn
+	}
+	return c.tcpConn
+}
+
+// ProxyHeader Returns the PROXY protocol header received on the connection
+// (see ProxyProtocol), nil if there was none
+func (c *Connection) ProxyHeader() *proxyproto.Header {
+	return c.proxyHeader
+}
+
+// setProxyHeader The addresses in the header replace those of the proxy
+func (c *Connection) setProxyHeader(header *proxyproto.Header) {
+	c.proxyHeader = header
+	if header.Source != nil {
+		c.remoteAddr = header.Source
+		c.SetRemoteAddress(header.Source.String())
+	}
+	if header.Destination != nil {
+		c.localAddr = header.Destination
+	}
+}
+
+func (c *Connection) ConfigureUDP(server ReadWriter, conn *UdpConn, localAddr net.Addr) {
 	c.DefaultConnection.Configure(conn.RemoteAddr().String(), -1, nil)
 	c.SetDisconnectTime(time.Now().UTC())
 	c.SetIsConnected(false)
 	c.server = server
+	c.localAddr = localAddr
+	c.remoteAddr = conn.RemoteAddr()
+	c.udpConn = conn
+}
+
+// ConfigureUDPSession Unlike ConfigureUDP, the connection is long-lived
+// and is closed by the server when idle (see UdpServer).
+func (c *Connection) ConfigureUDPSession(server ReadWriter, conn *UdpConn, localAddr net.Addr, idleTimeoutMs int64,
+	closeHandler func(socket.ConnectionID) error) {
+	c.DefaultConnection.Configure(conn.RemoteAddr().String(), idleTimeoutMs, closeHandler)
+	c.server = server
+	c.localAddr = localAddr
+	c.remoteAddr = conn.RemoteAddr()
 	c.udpConn = conn
 }
 
@@ -43,5 +155,20 @@ func (c *Connection) ConfigureRFCOMM(server ReadWriter, conn int, remoteAddress
 	closeHandler func(socket.ConnectionID) error) {
 	c.DefaultConnection.Configure(remoteAddress, idleTimeoutMs, closeHandler)
 	c.server = server
+	if addr := rfcomm.LocalAddr(conn); addr != nil {
+		c.localAddr = addr
+	}
+	if addr := rfcomm.RemoteAddr(conn); addr != nil {
+		c.remoteAddr = addr
+	}
 	c.rfcommConn = conn
 }
+
+func (c *Connection) ConfigureMemory(server ReadWriter, conn *memnet.Conn, idleTimeoutMs int64,
+	closeHandler func(socket.ConnectionID) error) {
+	c.DefaultConnection.Configure(conn.RemoteAddr().String(), idleTimeoutMs, closeHandler)
+	c.server = server
+	c.localAddr = conn.LocalAddr()
+	c.remoteAddr = conn.RemoteAddr()
+	c.memConn = conn
+}
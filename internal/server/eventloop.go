@@ -0,0 +1,260 @@
+package server
+
+import (
+	"errors"
+	"golang.org/x/sys/unix"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"tonysoft.com/comm/internal/poll"
+	"tonysoft.com/comm/internal/socket"
+)
+
+// eventLoop Optional engine (see EventLoop) that watches every connection
+// with a single epoll instance rather than a Go routine blocked in Read
+// per connection.  A connection is armed once it has been read until no
+// data remains (or its handler returns) and is then dispatched again when
+// it next becomes readable: to a worker pool when serving a Handler,
+// otherwise on the Accept channel.  Idle connections are closed by a
+// timer wheel rather than by polling IsIdle().
+type eventLoop struct {
+	server      *BaseServer
+	closeClient func(socket.ConnectionID) error
+
+	poller      *poll.Poller
+	wheel       *poll.TimerWheel
+	idleTimeout time.Duration
+
+	connections sync.Map // map[int]*Connection, keyed by file descriptor
+	workers     atomic.Pointer[poll.WorkerPool]
+	handler     Handler
+}
+
+const (
+	timerWheelTick      = 100 * time.Millisecond
+	timerWheelSlotCount = 600
+)
+
+// startEventLoop Starts the event loop if EventLoop is configured
+func (s *BaseServer) startEventLoop(closeClient func(socket.ConnectionID) error) error {
+	s.eventLoop = nil
+
+	cfg := s.Config()
+	if !cfg.EventLoop {
+		return nil
+	}
+
+	poller, err := poll.NewPoller()
+	if err != nil {
+		return err
+	}
+
+	l := &eventLoop{
+		server:      s,
+		closeClient: closeClient,
+		poller:      poller,
+		idleTimeout: time.Duration(cfg.IdleConnectionTimeoutMs) * time.Millisecond,
+	}
+
+	if l.idleTimeout > 0 {
+		l.wheel = poll.NewTimerWheel(timerWheelTick, timerWheelSlotCount, l.expire)
+	}
+
+	go func() {
+		waitErr := poller.Wait(l.onReadable)
+		if waitErr != nil {
+			s.SendError(waitErr)
+		}
+	}()
+
+	s.eventLoop = l
+
+	return nil
+}
+
+// add Start managing the connection, which is armed once first read
+func (l *eventLoop) add(conn *Connection, fd int) {
+	conn.pollFd = fd
+	conn.polled = true
+	l.connections.Store(fd, conn)
+	l.touch(conn)
+}
+
+// remove Stop managing the connection, which must be done before it is closed
+func (l *eventLoop) remove(conn *Connection) {
+	if !conn.polled {
+		return
+	}
+
+	l.connections.Delete(conn.pollFd)
+	if l.wheel != nil {
+		l.wheel.Remove(uint64(conn.ID()))
+	}
+
+	err := l.poller.Remove(conn.pollFd)
+	if err != nil {
+		l.server.SendError(err)
+	}
+}
+
+func (l *eventLoop) close() {
+	_ = l.poller.Close()
+	if l.wheel != nil {
+		l.wheel.Stop()
+	}
+}
+
+// arm Watch the connection for the next time it becomes readable, unless
+// it already is being watched
+func (l *eventLoop) arm(conn *Connection) {
+	if value, ok := l.connections.Load(conn.pollFd); !ok || value != conn {
+		// Already removed, possibly closed
+		return
+	}
+
+	if !conn.IsConnected() || !conn.pollArmed.CompareAndSwap(false, true) {
+		return
+	}
+
+	var err error
+	if conn.pollAdded {
+		err = l.poller.Rearm(conn.pollFd)
+	} else {
+		err = l.poller.Add(conn.pollFd)
+		conn.pollAdded = err == nil
+	}
+
+	if err != nil {
+		conn.pollArmed.Store(false)
+		l.server.SendError(err)
+	}
+}
+
+func (l *eventLoop) onReadable(fd int) {
+	value, ok := l.connections.Load(fd)
+	if !ok {
+		return
+	}
+
+	conn := value.(*Connection)
+	conn.pollArmed.Store(false)
+	l.dispatch(conn)
+}
+
+func (l *eventLoop) dispatch(conn *Connection) {
+	if workers := l.workers.Load(); workers != nil {
+		l.submit(workers, conn)
+		return
+	}
+	l.server.acceptConnection(conn)
+}
+
+// serve Invoke the handler on the worker pool each time a connection is
+// accepted or becomes readable, until the server is stopped
+func (l *eventLoop) serve(handler Handler) error {
+	s := l.server
+
+	workerCount := s.Config().EventLoopWorkers
+	if workerCount < 1 {
+		workerCount = runtime.NumCPU()
+	}
+
+	l.handler = handler
+	workers := poll.NewWorkerPool(workerCount, cap(s.newConnChan))
+	l.workers.Store(workers)
+
+	for conn := range s.Accept() {
+		l.submit(workers, conn.(*Connection))
+	}
+
+	workers.Close()
+	s.handlerWaitGroup.Wait()
+
+	return nil
+}
+
+func (l *eventLoop) submit(workers *poll.WorkerPool, conn *Connection) {
+	s := l.server
+	ctx := s.handlerContext
+
+	s.handlerWaitGroup.Add(1)
+	s.activeHandlers.Add(1)
+	done := func() {
+		s.activeHandlers.Add(-1)
+		s.handlerWaitGroup.Done()
+	}
+
+	submitted := workers.Submit(func() {
+		defer done()
+		conn.pollServing.Store(true)
+		l.handler.ServeConn(ctx, conn)
+		conn.pollServing.Store(false)
+		l.arm(conn)
+	})
+	if !submitted {
+		done()
+	}
+}
+
+// read Read from the connection without blocking.  When no data remains
+// 0 bytes are returned and the connection is armed, unless a handler is
+// serving it (it is armed once the handler returns so that the handler is
+// never invoked concurrently); io.EOF is returned once the remote end has
+// closed the connection.
+func (l *eventLoop) read(conn *Connection, buffer []byte, read func([]byte) (int, error)) (int, error) {
+	count, err := read(buffer)
+	switch {
+	case errors.Is(err, unix.EAGAIN):
+		if !conn.pollServing.Load() {
+			l.arm(conn)
+		}
+		return 0, nil
+	case err == nil && count == 0 && len(buffer) > 0:
+		return 0, io.EOF
+	case count > 0:
+		l.touch(conn)
+	}
+	return count, err
+}
+
+// touch Reset the connection's idle timeout
+func (l *eventLoop) touch(conn *Connection) {
+	conn.NotIdle()
+	if l.wheel != nil {
+		l.wheel.Reset(uint64(conn.ID()), l.idleTimeout)
+	}
+}
+
+func (l *eventLoop) expire(key uint64) {
+	err := l.closeClient(socket.ConnectionID(key))
+	if err != nil {
+		l.server.SendError(err)
+	}
+}
+
+// readNow Read from a connection managed by the Go runtime without
+// waiting for it to become readable
+func readNow(rawConn syscall.RawConn, buffer []byte) (int, error) {
+	var count int
+	var readErr error
+	err := rawConn.Read(func(fd uintptr) bool {
+		count, readErr = unix.Read(int(fd), buffer)
+		return true
+	})
+	if err != nil {
+		return -1, err
+	}
+	return count, readErr
+}
+
+// rawFd Returns the file descriptor of a connection managed by the Go runtime
+func rawFd(rawConn syscall.RawConn) (int, error) {
+	fd := -1
+	err := rawConn.Control(func(f uintptr) {
+		fd = int(f)
+	})
+	return fd, err
+}
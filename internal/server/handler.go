@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"tonysoft.com/comm/internal/socket"
+)
+
+// Handler Serves a single connection accepted by a server.  The connection
+// is closed once ServeConn returns and the context is cancelled when the
+// server stops or begins shutting down.  With EventLoop, ServeConn is
+// instead invoked on a worker each time the connection becomes readable,
+// should read until Read returns 0 bytes, and the connection stays open
+// once it returns (until closed by the handler, the remote end or for being idle).
+type Handler interface {
+	ServeConn(context.Context, socket.Connection)
+}
+
+// HandlerFunc Allows ordinary functions to be used as instances of Handler
+type HandlerFunc func(context.Context, socket.Connection)
+
+func (f HandlerFunc) ServeConn(ctx context.Context, conn socket.Connection) {
+	f(ctx, conn)
+}
+
+// Middleware Wraps a Handler to add behavior before and/or after it serves
+// the connection (logging, authentication, etc).
+type Middleware func(Handler) Handler
+
+// Chain Wrap the handler with the given middleware, with the first
+// middleware being the outermost (i.e., the first to see the connection).
+func Chain(handler Handler, middleware ...Middleware) Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
+
+// serve Starts the server if it is not already running, then invokes the
+// handler on its own Go routine for each accepted connection until the
+// server stops, returning once every handler has returned.
+func (s *BaseServer) serve(start func() error, handler Handler) error {
+	if !s.IsRunning() {
+		err := start()
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.eventLoop != nil {
+		return s.eventLoop.serve(handler)
+	}
+
+	ctx := s.handlerContext
+
+	for conn := range s.Accept() {
+		s.handlerWaitGroup.Add(1)
+		s.activeHandlers.Add(1)
+		go func(c socket.Connection) {
+			defer s.handlerWaitGroup.Done()
+			defer s.activeHandlers.Add(-1)
+			defer func() {
+				_ = c.Close()
+			}()
+			handler.ServeConn(ctx, c)
+		}(conn)
+	}
+
+	s.handlerWaitGroup.Wait()
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"fmt"
+	"golang.org/x/sys/unix"
+	"net"
+	"os"
+	"tonysoft.com/comm/internal/activation"
+	"tonysoft.com/comm/pkg/comerr"
+)
+
+// inheritedFile Returns the pre-opened socket to use rather than binding a
+// new one (see ListenerFile and SocketActivation), nil if there is none.
+// The socket passed via socket activation is kept so that the server can
+// be restarted.
+func (s *BaseServer) inheritedFile(sockType int) (*os.File, error) {
+	cfg := s.Config()
+
+	if cfg.ListenerFile != nil {
+		return cfg.ListenerFile, nil
+	}
+
+	if !cfg.SocketActivation {
+		return nil, nil
+	}
+
+	if s.activatedFile == nil {
+		f, err := activation.Take(cfg.SocketActivationName, sockType)
+		if err != nil {
+			return nil, err
+		}
+		s.activatedFile = f
+	}
+
+	return s.activatedFile, nil
+}
+
+// inheritedListener Returns the pre-opened listener to use rather than
+// binding a new one (see Listener, ListenerFile and SocketActivation)
+func (s *TcpServer) inheritedListener() (net.Listener, error) {
+	if listener := s.Config().Listener; listener != nil {
+		return listener, nil
+	}
+
+	f, err := s.inheritedFile(unix.SOCK_STREAM)
+	if f == nil || err != nil {
+		return nil, err
+	}
+
+	// The file descriptor is duplicated, so the file remains open
+	return net.FileListener(f)
+}
+
+// inheritedPacketConn Returns the pre-opened socket to use rather than
+// binding a new one (see PacketConn, ListenerFile and SocketActivation)
+func (s *UdpServer) inheritedPacketConn() (*net.UDPConn, error) {
+	packetConn := s.Config().PacketConn
+
+	if packetConn == nil {
+		f, err := s.inheritedFile(unix.SOCK_DGRAM)
+		if f == nil || err != nil {
+			return nil, err
+		}
+
+		// The file descriptor is duplicated, so the file remains open
+		packetConn, err = net.FilePacketConn(f)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	udpConn, ok := packetConn.(*net.UDPConn)
+	if !ok {
+		_ = packetConn.Close()
+		return nil, fmt.Errorf("%w : %s is not a UDP socket", comerr.ErrPreOpenedSocketType, packetConn.LocalAddr())
+	}
+
+	return udpConn, nil
+}
+
+// ListenerFile Returns a duplicate of the listening socket, which another
+// process can use as its ListenerFile
+func (s *TcpServer) ListenerFile() (*os.File, error) {
+	current, _ := s.listeners()
+	listener, ok := current.(interface{ File() (*os.File, error) })
+	if !ok || !s.IsRunning() {
+		return nil, comerr.ErrServerNotRunning
+	}
+	return listener.File()
+}
+
+// ListenerFile Returns a duplicate of the socket, which another process can
+// use as its ListenerFile
+func (s *UdpServer) ListenerFile() (*os.File, error) {
+	listener, _ := s.listeners()
+	if listener == nil || !s.IsRunning() {
+		return nil, comerr.ErrServerNotRunning
+	}
+	return listener.File()
+}
+
+// preOpened Returns true if a pre-opened socket is used rather than binding
+// one (see Listener, PacketConn, ListenerFile and SocketActivation)
+func (s *BaseServer) preOpened() bool {
+	cfg := s.Config()
+	return cfg.Listener != nil || cfg.PacketConn != nil || cfg.ListenerFile != nil || cfg.SocketActivation
+}
@@ -1,11 +1,13 @@
 package server
 
+import "context"
+
 type Reader interface {
-	read(*Connection, []byte) (int, error)
+	read(context.Context, *Connection, []byte) (int, error)
 }
 
 type Writer interface {
-	write(*Connection, []byte) (int, error)
+	write(context.Context, *Connection, []byte) (int, error)
 }
 
 type ReadWriter interface {
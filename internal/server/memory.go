@@ -0,0 +1,234 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+	"tonysoft.com/comm/internal/memnet"
+	"tonysoft.com/comm/internal/socket"
+	"tonysoft.com/comm/pkg/comerr"
+)
+
+// MemoryServer Listens on a mem:// address for connections from clients
+// (and nodes) within the same process, e.g., for testing without sockets.
+// EventLoop does not apply, connections being served by their own Go routines.
+type MemoryServer struct {
+	BaseServer
+
+	listener      *memnet.Listener
+	readTimeoutUs int
+}
+
+func (s *MemoryServer) Start() error {
+	if s.IsRunning() {
+		return comerr.ErrServerAlreadyRunning
+	}
+
+	cfg := s.Config()
+
+	s.clearConnections()
+	s.configureShutdown()
+	s.configureRateLimits()
+	s.readTimeoutUs = cfg.ReadTimeoutUs
+	s.eventLoop = nil
+
+	s.ConfigureErrors(cfg.ErrorChanBufferSize)
+
+	err := s.configureAccess()
+	if err != nil {
+		return err
+	}
+
+	listener, err := memnet.Listen(cfg.Address, time.Duration(cfg.MemoryLatencyMs)*time.Millisecond)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.listenContext = ctx
+	s.listenCancelFunc = cancel
+
+	go s.listenForClientConnections(listener)
+	go s.handleListenCancel()
+
+	s.SetIsRunning(true)
+
+	return nil
+}
+
+// Shutdown Gracefully stop the server, see BaseServer.shutdown
+func (s *MemoryServer) Shutdown(ctx context.Context) error {
+	return s.shutdown(ctx, s.stopAccepting, s.Stop)
+}
+
+// Serve Invoke the handler for each accepted connection until the server is stopped
+func (s *MemoryServer) Serve(handler Handler) error {
+	return s.serve(s.Start, handler)
+}
+
+func (s *MemoryServer) Addr() net.Addr {
+	listener := s.listener
+	if listener == nil {
+		return nil
+	}
+	return listener.Addr()
+}
+
+func (s *MemoryServer) CloseClient(id socket.ConnectionID) error {
+	conn, ok := s.connections.Load(id)
+	if !ok {
+		return nil
+	}
+
+	s.connections.Delete(id)
+
+	return conn.(*Connection).memConn.Close()
+}
+
+// stopAccepting Closes the listener, leaving accepted connections open
+func (s *MemoryServer) stopAccepting() error {
+	err := s.listener.Close()
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+func (s *MemoryServer) close() error {
+	err := s.listener.Close()
+	s.listener = nil
+	s.listenContext = nil
+	s.listenCancelFunc = nil
+
+	s.connections.Range(func(_, conn any) bool {
+		closeErr := s.CloseClient(conn.(*Connection).ID())
+		if closeErr != nil {
+			s.SendError(closeErr)
+		}
+		return true
+	})
+
+	s.closeAccept()
+	s.CloseErrors()
+	s.setStopped()
+
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+func (s *MemoryServer) listenForClientConnections(listener *memnet.Listener) {
+	for {
+		conn, acceptErr := listener.Accept()
+		if acceptErr != nil {
+			return
+		}
+
+		go func() {
+			addClientErr := s.addClientConnection(conn.(*memnet.Conn))
+			if addClientErr != nil {
+				s.SendError(addClientErr)
+			}
+		}()
+	}
+}
+
+func (s *MemoryServer) addClientConnection(memConn *memnet.Conn) error {
+	cfg := s.Config()
+
+	err := s.verifyConnectionLimit(cfg.ClientConnectionLimit)
+	if err != nil {
+		_ = memConn.Close()
+		return err
+	}
+
+	err = s.verifyAccess(memConn.RemoteAddr().String())
+	if err != nil {
+		_ = memConn.Close()
+		return err
+	}
+
+	conn := &Connection{}
+	conn.ConfigureMemory(s, memConn, int64(cfg.IdleConnectionTimeoutMs), s.CloseClient)
+	s.limitConnection(conn)
+
+	s.connections.Store(conn.ID(), conn)
+	s.acceptConnection(conn)
+
+	return nil
+}
+
+func (s *MemoryServer) read(ctx context.Context, conn *Connection, buffer []byte) (int, error) {
+	if conn == nil || conn.memConn == nil {
+		return -1, net.ErrClosed
+	}
+
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+
+	err := conn.memConn.SetReadDeadline(time.Now().Add(time.Duration(s.readTimeoutUs) * time.Microsecond))
+	if err != nil {
+		return -1, fmt.Errorf("%w : %v", comerr.ErrSetReadTimeout, err)
+	}
+
+	aborted := socket.AbortOnDone(ctx, conn.memConn.SetReadDeadline)
+	count, err := conn.memConn.Read(buffer)
+	if aborted() {
+		return count, ctx.Err()
+	}
+
+	err = socket.SinkReadWriteError(err)
+	if err != nil {
+		closeErr := s.CloseClient(conn.ID())
+		if closeErr != nil {
+			s.SendError(closeErr)
+		}
+	}
+
+	return count, err
+}
+
+func (s *MemoryServer) write(ctx context.Context, conn *Connection, data []byte) (int, error) {
+	if conn == nil || conn.memConn == nil {
+		return -1, net.ErrClosed
+	}
+
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+
+	aborted := socket.AbortOnDone(ctx, conn.memConn.SetWriteDeadline)
+	count, err := conn.memConn.Write(data)
+	if aborted() {
+		_ = conn.memConn.SetWriteDeadline(time.Time{})
+		return count, ctx.Err()
+	}
+
+	err = socket.SinkReadWriteError(err)
+	if err != nil {
+		closeErr := s.CloseClient(conn.ID())
+		if closeErr != nil {
+			s.SendError(closeErr)
+		}
+	}
+
+	return count, err
+}
+
+func (s *MemoryServer) handleListenCancel() {
+	for {
+		select {
+		case <-s.listenContext.Done():
+			err := s.close()
+			if err != nil {
+				s.SendError(err)
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+	"tonysoft.com/comm/internal/ratelimit"
+	"tonysoft.com/comm/internal/socket"
+)
+
+// Logging Logs when each connection is opened and closed (along with how
+// long it was served) using the given logger, or the standard logger if nil.
+func Logging(logger *log.Logger) Middleware {
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, conn socket.Connection) {
+			startTime := time.Now()
+			logger.Printf("[%d] connection opened: %s", conn.ID(), conn.RemoteAddress())
+			defer func() {
+				logger.Printf("[%d] connection closed: %s (%v)", conn.ID(), conn.RemoteAddress(), time.Since(startTime))
+			}()
+			next.ServeConn(ctx, conn)
+		})
+	}
+}
+
+// Recover Recovers from a panic raised while serving a connection, passing
+// the recovered value to onPanic (if not nil) rather than crashing the process.
+func Recover(onPanic func(socket.Connection, any)) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, conn socket.Connection) {
+			defer func() {
+				if r := recover(); r != nil && onPanic != nil {
+					onPanic(conn, r)
+				}
+			}()
+			next.ServeConn(ctx, conn)
+		})
+	}
+}
+
+// RateLimit Limits how many new connections are served per second (with
+// bursts up to the given size), closing those that exceed the limit.
+func RateLimit(connectionsPerSec float64, burst int) Middleware {
+	bucket := ratelimit.NewBucket(connectionsPerSec, burst)
+
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, conn socket.Connection) {
+			if !bucket.Allow(1) {
+				return
+			}
+			next.ServeConn(ctx, conn)
+		})
+	}
+}
+
+// Auth Serves only the connections for which authenticate returns nil,
+// closing the others.  The function may perform IO on the connection (to
+// complete a handshake, etc) before the connection is handed off.
+func Auth(authenticate func(context.Context, socket.Connection) error) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, conn socket.Connection) {
+			if authenticate(ctx, conn) != nil {
+				return
+			}
+			next.ServeConn(ctx, conn)
+		})
+	}
+}
+
+// Metrics Counters updated by the middleware returned from Middleware()
+// Thread-safe ✓
+type Metrics struct {
+	total           atomic.Uint64
+	active          atomic.Int64
+	totalDurationNs atomic.Int64
+}
+
+func (m *Metrics) Total() uint64 {
+	return m.total.Load()
+}
+
+func (m *Metrics) Active() int64 {
+	return m.active.Load()
+}
+
+// AverageDuration How long, on average, a connection was served for
+func (m *Metrics) AverageDuration() time.Duration {
+	completed := int64(m.total.Load()) - m.active.Load()
+	if completed < 1 {
+		return 0
+	}
+	return time.Duration(m.totalDurationNs.Load() / completed)
+}
+
+func (m *Metrics) Middleware() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, conn socket.Connection) {
+			startTime := time.Now()
+			m.total.Add(1)
+			m.active.Add(1)
+			defer func() {
+				m.totalDurationNs.Add(int64(time.Since(startTime)))
+				m.active.Add(-1)
+			}()
+			next.ServeConn(ctx, conn)
+		})
+	}
+}
@@ -28,12 +28,25 @@ func (s *RfcommServer) Start() error {
 	cfg := s.Config()
 
 	s.clearConnections()
+	s.configureShutdown()
+	s.configureRateLimits()
 	s.ConfigureErrors(cfg.ErrorChanBufferSize)
 
+	err := s.configureAccess()
+	if err != nil {
+		return err
+	}
+
 	rfcomm.BecomeDiscoverable()
 
-	err := s.configureListener(cfg.Port, cfg.ClientConnectionLimit)
+	err = s.configureListener(cfg.Port, cfg.ClientConnectionLimit)
+	if err != nil {
+		return err
+	}
+
+	err = s.startEventLoop(s.CloseClient)
 	if err != nil {
+		_ = unix.Close(s.listener)
 		return err
 	}
 
@@ -45,6 +58,16 @@ func (s *RfcommServer) Start() error {
 	return nil
 }
 
+// Shutdown Gracefully stop the server, see BaseServer.shutdown
+func (s *RfcommServer) Shutdown(ctx context.Context) error {
+	return s.shutdown(ctx, s.stopAccepting, s.Stop)
+}
+
+// Serve Invoke the handler for each accepted connection until the server is stopped
+func (s *RfcommServer) Serve(handler Handler) error {
+	return s.serve(s.Start, handler)
+}
+
 func (s *RfcommServer) Stop() {
 	if !s.IsRunning() {
 		return
@@ -55,32 +78,73 @@ func (s *RfcommServer) Stop() {
 	}
 }
 
+func (s *RfcommServer) Addr() net.Addr {
+	if s.listener < 1 {
+		return nil
+	}
+	if addr := rfcomm.LocalAddr(s.listener); addr != nil {
+		return addr
+	}
+	return nil
+}
+
 func (s *RfcommServer) CloseClient(id socket.ConnectionID) error {
+	return s.closeClient(id, s.IsStopping())
+}
+
+// closeClient Connections are reset on close unless graceful, in which case
+// any data still pending is sent before the connection is closed.
+func (s *RfcommServer) closeClient(id socket.ConnectionID, graceful bool) error {
 	conn, ok := s.connections.Load(id)
 	if !ok {
 		return nil
 	}
 
 	s.connections.Delete(id)
-	return unix.Close(conn.(*Connection).rfcommConn)
+
+	if s.eventLoop != nil {
+		s.eventLoop.remove(conn.(*Connection))
+	}
+
+	rfcommConn := conn.(*Connection).rfcommConn
+	if graceful {
+		_ = unix.SetsockoptLinger(rfcommConn, unix.SOL_SOCKET, unix.SO_LINGER, &unix.Linger{})
+	}
+	return unix.Close(rfcommConn)
+}
+
+// stopAccepting Shutting down the listener wakes the blocked accept call,
+// leaving accepted connections open
+func (s *RfcommServer) stopAccepting() error {
+	err := unix.Shutdown(s.listener, unix.SHUT_RDWR)
+	if errors.Is(err, unix.ENOTCONN) {
+		return nil
+	}
+	return err
 }
 
 func (s *RfcommServer) close() error {
+	_ = unix.Shutdown(s.listener, unix.SHUT_RDWR)
 	err := unix.Close(s.listener)
 	s.listener = 0
 	s.listenContext = nil
 	s.listenCancelFunc = nil
 
 	s.connections.Range(func(_, conn any) bool {
-		closeErr := s.CloseClient(conn.(*Connection).ID())
+		closeErr := s.closeClient(conn.(*Connection).ID(), false)
 		if closeErr != nil {
 			s.SendError(closeErr)
 		}
 		return true
 	})
 
+	if s.eventLoop != nil {
+		s.eventLoop.close()
+	}
+
+	s.closeAccept()
 	s.CloseErrors()
-	s.SetIsRunning(false)
+	s.setStopped()
 
 	if errors.Is(err, syscall.EINVAL) {
 		return nil
@@ -130,7 +194,7 @@ func (s *RfcommServer) listenForClientConnections() {
 	for {
 		conn, addr, acceptErr := unix.Accept(s.listener)
 		if acceptErr != nil {
-			if errors.Is(acceptErr, net.ErrClosed) {
+			if errors.Is(acceptErr, net.ErrClosed) || errors.Is(acceptErr, unix.EINVAL) || s.IsStopping() {
 				return
 			}
 
@@ -152,6 +216,7 @@ func (s *RfcommServer) addClientConnection(rfcommConn int, address unix.Sockaddr
 
 	clientCount := s.ClientCount()
 	if cfg.ClientConnectionLimit > -1 && clientCount >= cfg.ClientConnectionLimit {
+		_ = unix.Close(rfcommConn)
 		return fmt.Errorf("%w : %d", comerr.ErrConnectionLimitReached, clientCount)
 	}
 
@@ -165,17 +230,23 @@ func (s *RfcommServer) addClientConnection(rfcommConn int, address unix.Sockaddr
 		return err
 	}
 
+	err = s.verifyAccess(remoteAddress)
+	if err != nil {
+		_ = unix.Close(rfcommConn)
+		return err
+	}
+
 	conn := &Connection{}
 	conn.ConfigureRFCOMM(s, rfcommConn, remoteAddress, int64(cfg.IdleConnectionTimeoutMs), s.CloseClient)
+	s.limitConnection(conn)
 
-	s.connections.Store(conn.ID(), conn)
-
-	select {
-	case s.newConnChan <- conn:
-		break
-	default:
+	if s.eventLoop != nil {
+		s.eventLoop.add(conn, rfcommConn)
 	}
 
+	s.connections.Store(conn.ID(), conn)
+	s.acceptConnection(conn)
+
 	return nil
 }
 
@@ -205,15 +276,28 @@ func (s *RfcommServer) setConnectionOptions(connection int, readTimeoutUs int) e
 		return fmt.Errorf("%w : %v", comerr.ErrSetLingerTimeout, err)
 	}
 
-	return nil
+	return s.Config().SocketOptions.Set(connection, "rfcomm")
 }
 
-func (s *RfcommServer) read(conn *Connection, buffer []byte) (int, error) {
+func (s *RfcommServer) read(ctx context.Context, conn *Connection, buffer []byte) (int, error) {
 	if conn == nil || conn.rfcommConn == 0 {
 		return -1, net.ErrClosed
 	}
 
-	count, err := unix.Read(conn.rfcommConn, buffer)
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+
+	var count int
+	var err error
+	if conn.polled {
+		count, err = s.eventLoop.read(conn, buffer, func(b []byte) (int, error) {
+			n, _, e := unix.Recvfrom(conn.rfcommConn, b, unix.MSG_DONTWAIT)
+			return n, e
+		})
+	} else {
+		count, err = unix.Read(conn.rfcommConn, buffer)
+	}
 
 	err = socket.SinkReadWriteError(err)
 	if err != nil {
@@ -226,12 +310,19 @@ func (s *RfcommServer) read(conn *Connection, buffer []byte) (int, error) {
 	return count, err
 }
 
-func (s *RfcommServer) write(conn *Connection, data []byte) (int, error) {
+func (s *RfcommServer) write(ctx context.Context, conn *Connection, data []byte) (int, error) {
 	if conn == nil || conn.rfcommConn < 1 {
 		return -1, net.ErrClosed
 	}
 
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+
 	count, err := unix.Write(conn.rfcommConn, data)
+	if conn.polled && count > 0 {
+		s.eventLoop.touch(conn)
+	}
 
 	err = socket.SinkReadWriteError(err)
 	if err != nil {
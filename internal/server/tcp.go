@@ -5,18 +5,28 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sync"
 	"syscall"
 	"time"
+	"tonysoft.com/comm/internal/acl"
+	"tonysoft.com/comm/internal/proxyproto"
 	"tonysoft.com/comm/internal/socket"
 	"tonysoft.com/comm/internal/transport"
+	"tonysoft.com/comm/internal/websocket"
 	"tonysoft.com/comm/pkg/comerr"
 )
 
 type TcpServer struct {
 	BaseServer
 
+	listenerMutex sync.RWMutex // guards listener and shards, which rebind replaces
 	listener      net.Listener
+	shards        []net.Listener // bound in addition to listener, see ListenerShards
 	readTimeoutUs int
+
+	trustedProxies *acl.Filter // see TrustedProxies
+
+	webSocket *transport.WebSocketURL // nil unless Address is a ws:// or wss:// URL
 }
 
 func (s *TcpServer) Start() error {
@@ -27,11 +37,43 @@ func (s *TcpServer) Start() error {
 	cfg := s.Config()
 
 	s.clearConnections()
+	s.configureShutdown()
+	s.configureRateLimits()
 	s.readTimeoutUs = cfg.ReadTimeoutUs
 
 	s.ConfigureErrors(cfg.ErrorChanBufferSize)
 
-	addr, err := transport.GetTcpAddressFromHostAndPort(cfg.Address, cfg.Port)
+	err := s.configureAccess()
+	if err != nil {
+		return err
+	}
+
+	// Without any trusted proxies, anyone could spoof their address
+	if cfg.ProxyProtocol && len(cfg.TrustedProxies) == 0 {
+		return comerr.ErrTrustedProxiesRequired
+	}
+
+	s.trustedProxies, err = acl.New(cfg.TrustedProxies, nil)
+	if err != nil {
+		return err
+	}
+
+	s.webSocket, err = s.webSocketURL()
+	if err != nil {
+		return err
+	}
+
+	port := cfg.Port
+	if s.webSocket != nil {
+		port = s.webSocket.Port
+	}
+
+	host, err := s.bindHost()
+	if err != nil {
+		return err
+	}
+
+	addr, err := transport.GetTcpAddressFromHostAndPort(host, port)
 	if err != nil {
 		return err
 	}
@@ -41,7 +83,14 @@ func (s *TcpServer) Start() error {
 		return err
 	}
 
-	go s.listenForClientConnections()
+	err = s.startEventLoop(s.CloseClient)
+	if err != nil {
+		_ = s.closeListeners()
+		return err
+	}
+
+	s.startAccepting()
+	s.watchInterface(host, s.rebind)
 	go s.handleListenCancel()
 
 	s.SetIsRunning(true)
@@ -49,35 +98,124 @@ func (s *TcpServer) Start() error {
 	return nil
 }
 
+// Shutdown Gracefully stop the server, see BaseServer.shutdown
+func (s *TcpServer) Shutdown(ctx context.Context) error {
+	return s.shutdown(ctx, s.stopAccepting, s.Stop)
+}
+
+// Serve Invoke the handler for each accepted connection until the server is stopped
+func (s *TcpServer) Serve(handler Handler) error {
+	return s.serve(s.Start, handler)
+}
+
+func (s *TcpServer) Addr() net.Addr {
+	listener, _ := s.listeners()
+	if listener == nil {
+		return nil
+	}
+	return listener.Addr()
+}
+
+// listeners Returns the listener and its shards
+func (s *TcpServer) listeners() (net.Listener, []net.Listener) {
+	s.listenerMutex.RLock()
+	defer s.listenerMutex.RUnlock()
+	return s.listener, s.shards
+}
+
+// setListeners Replaces the listener and its shards, returning the previous ones
+func (s *TcpServer) setListeners(listener net.Listener, shards []net.Listener) (net.Listener, []net.Listener) {
+	s.listenerMutex.Lock()
+	defer s.listenerMutex.Unlock()
+
+	previous, previousShards := s.listener, s.shards
+	s.listener, s.shards = listener, shards
+	return previous, previousShards
+}
+
 func (s *TcpServer) CloseClient(id socket.ConnectionID) error {
+	return s.closeClient(id, s.IsStopping())
+}
+
+// closeClient Connections are reset on close unless graceful, in which case
+// any data still pending is sent before the connection is closed.
+func (s *TcpServer) closeClient(id socket.ConnectionID, graceful bool) error {
 	conn, ok := s.connections.Load(id)
 	if !ok {
 		return nil
 	}
 
 	s.connections.Delete(id)
-	return conn.(*Connection).tcpConn.Close()
+
+	if s.eventLoop != nil {
+		s.eventLoop.remove(conn.(*Connection))
+	}
+
+	tcpConn := conn.(*Connection).tcpConn
+	if graceful {
+		_ = tcpConn.SetLinger(-1)
+	}
+	if ws := conn.(*Connection).wsConn; ws != nil {
+		return ws.Close()
+	}
+	return tcpConn.Close()
+}
+
+// startAccepting Starts accepting connections on the listener and its shards
+func (s *TcpServer) startAccepting() {
+	listener, shards := s.listeners()
+	go s.listenForClientConnections(listener)
+	for _, shard := range shards {
+		go s.listenForClientConnections(shard)
+	}
+}
+
+// stopAccepting Closes the listeners, leaving accepted connections open
+func (s *TcpServer) stopAccepting() error {
+	s.stopWatchingInterface()
+	err := s.closeListeners()
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+// closeListeners Closes the listener and its shards, returning the first error
+func (s *TcpServer) closeListeners() error {
+	listener, shards := s.listeners()
+	err := listener.Close()
+	for _, shard := range shards {
+		if shardErr := shard.Close(); err == nil {
+			err = shardErr
+		}
+	}
+	return err
 }
 
 func (s *TcpServer) close() error {
-	err := s.listener.Close()
-	s.listener = nil
+	s.stopWatchingInterface()
+	err := s.closeListeners()
+	s.setListeners(nil, nil)
 	s.listenContext = nil
 	s.listenCancelFunc = nil
 
 	s.connections.Range(func(_, conn any) bool {
-		closeErr := s.CloseClient(conn.(*Connection).ID())
+		closeErr := s.closeClient(conn.(*Connection).ID(), false)
 		if closeErr != nil {
 			s.SendError(closeErr)
 		}
 		return true
 	})
 
-	close(s.newConnChan)
+	if s.eventLoop != nil {
+		s.eventLoop.close()
+	}
+
+	s.closeAccept()
 	s.CloseErrors()
-	s.SetIsRunning(false)
+	s.setStopped()
 
-	if errors.Is(err, syscall.EINVAL) {
+	if errors.Is(err, syscall.EINVAL) || errors.Is(err, net.ErrClosed) {
 		return nil
 	} else {
 		return err
@@ -85,33 +223,67 @@ func (s *TcpServer) close() error {
 }
 
 func (s *TcpServer) configureListener(bindAddress string) error {
-	s.listener = nil
+	s.setListeners(nil, nil)
 
-	addr, err := net.ResolveTCPAddr("tcp", bindAddress)
+	ctx, cancel := context.WithCancel(context.Background())
+	s.listenContext = ctx
+	s.listenCancelFunc = cancel
+
+	listener, err := s.inheritedListener()
 	if err != nil {
 		return err
 	}
 
-	var cfg = net.ListenConfig{
-		Control: socket.ControlFunc,
+	if listener != nil {
+		s.setListeners(listener, nil)
+		return nil
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	s.listenContext = ctx
-	s.listenCancelFunc = cancel
-
-	listener, err := cfg.Listen(s.listenContext, "tcp4", addr.String())
+	listener, shards, err := s.bind(bindAddress)
 	if err != nil {
 		return err
 	}
-	s.listener = listener
 
+	s.setListeners(listener, shards)
 	return nil
 }
 
-func (s *TcpServer) listenForClientConnections() {
+// bind Binds a listener to the address, along with its shards (see ListenerShards)
+func (s *TcpServer) bind(bindAddress string) (net.Listener, []net.Listener, error) {
+	addr, err := net.ResolveTCPAddr("tcp", bindAddress)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var cfg = net.ListenConfig{
+		Control: s.Config().SocketOptions.Control(socket.ControlFunc),
+	}
+
+	listener, err := cfg.Listen(s.listenContext, "tcp4", addr.String())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The shards bind to the address of the first listener, in case the port is 0
+	var shards []net.Listener
+	for i := 1; i < s.shardCount(); i++ {
+		shard, shardErr := cfg.Listen(s.listenContext, "tcp4", listener.Addr().String())
+		if shardErr != nil {
+			_ = listener.Close()
+			for _, opened := range shards {
+				_ = opened.Close()
+			}
+			return nil, nil, shardErr
+		}
+		shards = append(shards, shard)
+	}
+
+	return listener, shards, nil
+}
+
+func (s *TcpServer) listenForClientConnections(listener net.Listener) {
 	for {
-		conn, acceptErr := s.listener.Accept()
+		conn, acceptErr := listener.Accept()
 		if acceptErr != nil {
 			if errors.Is(acceptErr, net.ErrClosed) {
 				return
@@ -140,51 +312,133 @@ func (s *TcpServer) addClientConnection(netConn *net.TCPConn) error {
 
 	err := s.verifyConnectionLimit(cfg.ClientConnectionLimit)
 	if err != nil {
+		_ = netConn.Close()
+		return err
+	}
+
+	var header *proxyproto.Header
+	if cfg.ProxyProtocol {
+		header, err = s.readProxyHeader(netConn, cfg.ProxyHeaderTimeoutMs)
+		if err != nil {
+			_ = netConn.Close()
+			return err
+		}
+	}
+
+	remoteAddress := netConn.RemoteAddr().String()
+	if header != nil && header.Source != nil {
+		remoteAddress = header.Source.String()
+	}
+
+	err = s.verifyAccess(remoteAddress)
+	if err != nil {
+		_ = netConn.Close()
 		return err
 	}
 
 	err = s.setConnectionOptions(netConn)
 	if err != nil {
+		_ = netConn.Close()
 		return err
 	}
 
+	var ws *websocket.Conn
+	if s.webSocket != nil {
+		ws, err = s.acceptWebSocket(netConn)
+		if err != nil {
+			_ = netConn.Close()
+			return err
+		}
+	}
+
 	conn := &Connection{}
 	conn.ConfigureTCP(s, netConn, int64(cfg.IdleConnectionTimeoutMs), s.CloseClient)
+	if header != nil {
+		conn.setProxyHeader(header)
+	}
+	conn.wsConn = ws
+	s.limitConnection(conn)
+
+	// WebSocket frames are decoded as they are read, which the event loop does not do
+	if s.eventLoop != nil && ws == nil {
+		err = s.addToEventLoop(conn)
+		if err != nil {
+			_ = netConn.Close()
+			return err
+		}
+	}
 
 	s.connections.Store(conn.ID(), conn)
+	s.acceptConnection(conn)
+
+	return nil
+}
 
-	select {
-	case s.newConnChan <- conn:
-		break
-	default:
+// readProxyHeader Reads the PROXY protocol header that trusted proxies send
+// ahead of the proxied data, rejecting connections from any other source
+func (s *TcpServer) readProxyHeader(netConn *net.TCPConn, timeoutMs int) (*proxyproto.Header, error) {
+	remoteAddress := netConn.RemoteAddr().String()
+	if !s.trustedProxies.Allowed(acl.Host(remoteAddress)) {
+		return nil, fmt.Errorf("%w : %s", comerr.ErrUntrustedProxy, remoteAddress)
 	}
 
-	return nil
+	err := netConn.SetReadDeadline(time.Now().Add(time.Duration(timeoutMs) * time.Millisecond))
+	if err != nil {
+		return nil, fmt.Errorf("%w : %v", comerr.ErrSetReadTimeout, err)
+	}
+
+	header, err := proxyproto.Read(netConn)
+	if err != nil {
+		return nil, fmt.Errorf("%w (from %s)", err, remoteAddress)
+	}
+
+	return header, netConn.SetReadDeadline(time.Time{})
 }
 
-func (s *TcpServer) verifyConnectionLimit(connectionLimit int) error {
-	clientCount := s.ClientCount()
-	if connectionLimit < 0 {
-		connectionLimit = 4096
+func (s *TcpServer) addToEventLoop(conn *Connection) error {
+	rawConn, err := conn.tcpConn.SyscallConn()
+	if err != nil {
+		return err
 	}
-	if clientCount >= connectionLimit {
-		return fmt.Errorf("%w : %d", comerr.ErrConnectionLimitReached, clientCount)
+
+	fd, err := rawFd(rawConn)
+	if err != nil {
+		return err
 	}
 
+	conn.pollConn = rawConn
+	s.eventLoop.add(conn, fd)
+
 	return nil
 }
 
-func (s *TcpServer) read(conn *Connection, buffer []byte) (int, error) {
+func (s *TcpServer) read(ctx context.Context, conn *Connection, buffer []byte) (int, error) {
 	if conn == nil || conn.tcpConn == nil {
 		return -1, net.ErrClosed
 	}
 
-	err := conn.tcpConn.SetReadDeadline(time.Now().Add(time.Duration(s.readTimeoutUs) * time.Microsecond))
-	if err != nil {
-		return -1, fmt.Errorf("%w : %v", comerr.ErrSetReadTimeout, err)
+	if err := ctx.Err(); err != nil {
+		return -1, err
 	}
 
-	count, err := conn.tcpConn.Read(buffer)
+	var count int
+	var err error
+	if conn.polled {
+		count, err = s.eventLoop.read(conn, buffer, func(b []byte) (int, error) {
+			return readNow(conn.pollConn, b)
+		})
+	} else {
+		err = conn.tcpConn.SetReadDeadline(time.Now().Add(time.Duration(s.readTimeoutUs) * time.Microsecond))
+		if err != nil {
+			return -1, fmt.Errorf("%w : %v", comerr.ErrSetReadTimeout, err)
+		}
+
+		aborted := socket.AbortOnDone(ctx, conn.tcpConn.SetReadDeadline)
+		count, err = conn.tcpStream().Read(buffer)
+		if aborted() {
+			return count, ctx.Err()
+		}
+	}
 
 	err = socket.SinkReadWriteError(err)
 	if err != nil {
@@ -197,12 +451,25 @@ func (s *TcpServer) read(conn *Connection, buffer []byte) (int, error) {
 	return count, err
 }
 
-func (s *TcpServer) write(conn *Connection, data []byte) (int, error) {
+func (s *TcpServer) write(ctx context.Context, conn *Connection, data []byte) (int, error) {
 	if conn == nil || conn.tcpConn == nil {
 		return -1, net.ErrClosed
 	}
 
-	count, err := conn.tcpConn.Write(data)
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+
+	aborted := socket.AbortOnDone(ctx, conn.tcpConn.SetWriteDeadline)
+	count, err := conn.tcpStream().Write(data)
+	if aborted() {
+		_ = conn.tcpConn.SetWriteDeadline(time.Time{})
+		return count, ctx.Err()
+	}
+
+	if conn.polled && count > 0 {
+		s.eventLoop.touch(conn)
+	}
 
 	err = socket.SinkReadWriteError(err)
 	if err != nil {
@@ -220,7 +487,13 @@ func (s *TcpServer) setConnectionOptions(conn *net.TCPConn) error {
 	if err != nil {
 		return fmt.Errorf("%w : %v", comerr.ErrSetLingerTimeout, err)
 	}
-	return nil
+
+	// Applied again as Go sets TCP_NODELAY and keep-alive on accept
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return fmt.Errorf("%w : %v", comerr.ErrSetSocketOption, err)
+	}
+	return s.Config().SocketOptions.Apply(rawConn, "tcp")
 }
 
 func (s *TcpServer) handleListenCancel() {
@@ -3,19 +3,32 @@ package server
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net"
+	"sync"
 	"syscall"
+	"time"
+	"tonysoft.com/comm/internal/acl"
 	"tonysoft.com/comm/internal/socket"
 	"tonysoft.com/comm/internal/transport"
 	"tonysoft.com/comm/pkg/comerr"
 	"tonysoft.com/comm/pkg/stream"
 )
 
+// UdpServer By default every datagram received is surfaced as a new
+// Connection.  With UdpSessions enabled, datagrams from the same remote
+// address are instead queued on one long-lived Connection (a session) that
+// is subject to ClientConnectionLimit and IdleConnectionTimeoutMs, as with TCP.
 type UdpServer struct {
 	BaseServer
 
+	listenerMutex sync.RWMutex // guards listener, shards and localAddr, which rebind replaces
 	listener      *net.UDPConn
+	shards        []*net.UDPConn // bound in addition to listener, see ListenerShards
+	localAddr     net.Addr
 	readTimeoutUs int
+
+	sessions sync.Map // map[string]*Connection, keyed by remote address
 }
 
 func (s *UdpServer) Start() error {
@@ -26,11 +39,23 @@ func (s *UdpServer) Start() error {
 	cfg := s.Config()
 
 	s.clearConnections()
+	s.configureShutdown()
+	s.configureRateLimits()
 	s.readTimeoutUs = cfg.ReadTimeoutUs
 
 	s.ConfigureErrors(cfg.ErrorChanBufferSize)
 
-	addr, err := transport.GetTcpAddressFromHostAndPort(cfg.Address, cfg.Port)
+	err := s.configureAccess()
+	if err != nil {
+		return err
+	}
+
+	host, err := s.bindHost()
+	if err != nil {
+		return err
+	}
+
+	addr, err := transport.GetTcpAddressFromHostAndPort(host, cfg.Port)
 	if err != nil {
 		return err
 	}
@@ -40,28 +65,124 @@ func (s *UdpServer) Start() error {
 		return err
 	}
 
-	go s.listenForClientConnections(cfg.ReadBufferSize)
+	s.startReading()
+	s.watchInterface(host, s.rebind)
 	go s.handleListenCancel()
 
+	if cfg.UdpSessions {
+		go s.pruneIdleSessions(s.listenContext.Done())
+	}
+
 	s.SetIsRunning(true)
 
 	return nil
 }
 
-// CloseClient Needed to implement Server interface
-func (s *UdpServer) CloseClient(_ socket.ConnectionID) error {
+// Shutdown Gracefully stop the server, see BaseServer.shutdown
+func (s *UdpServer) Shutdown(ctx context.Context) error {
+	return s.shutdown(ctx, s.stopAccepting, s.Stop)
+}
+
+// Serve Invoke the handler for each accepted connection until the server is stopped
+func (s *UdpServer) Serve(handler Handler) error {
+	return s.serve(s.Start, handler)
+}
+
+func (s *UdpServer) Addr() net.Addr {
+	listener, _ := s.listeners()
+	if listener == nil {
+		return nil
+	}
+	return listener.LocalAddr()
+}
+
+// listeners Returns the socket and its shards
+func (s *UdpServer) listeners() (*net.UDPConn, []*net.UDPConn) {
+	s.listenerMutex.RLock()
+	defer s.listenerMutex.RUnlock()
+	return s.listener, s.shards
+}
+
+// localAddress Returns the address the socket is bound to
+func (s *UdpServer) localAddress() net.Addr {
+	s.listenerMutex.RLock()
+	defer s.listenerMutex.RUnlock()
+	return s.localAddr
+}
+
+// setListeners Replaces the socket and its shards, returning the previous ones
+func (s *UdpServer) setListeners(listener *net.UDPConn, shards []*net.UDPConn) (*net.UDPConn, []*net.UDPConn) {
+	s.listenerMutex.Lock()
+	defer s.listenerMutex.Unlock()
+
+	previous, previousShards := s.listener, s.shards
+	s.listener, s.shards = listener, shards
+	if listener != nil {
+		s.localAddr = listener.LocalAddr()
+	}
+	return previous, previousShards
+}
+
+// CloseClient Closes the session with the given ID, which is a no-op for
+// connections that are not sessions (see UdpSessions).
+func (s *UdpServer) CloseClient(id socket.ConnectionID) error {
+	conn, ok := s.connections.Load(id)
+	if !ok {
+		return nil
+	}
+
+	s.connections.Delete(id)
+
+	c := conn.(*Connection)
+	s.sessions.Delete(c.RemoteAddress())
+	c.udpConn.close()
+
+	return nil
+}
+
+// startReading Starts reading datagrams from the socket and its shards.
+// Datagrams from a remote address are always read by the same shard, as
+// SO_REUSEPORT distributes them by source and destination address.
+func (s *UdpServer) startReading() {
+	cfg := s.Config()
+
+	listener, shards := s.listeners()
+	for _, listener := range append([]*net.UDPConn{listener}, shards...) {
+		if cfg.UdpBatchSize > 1 {
+			go s.listenForClientConnectionsBatched(listener, cfg.ReadBufferSize, cfg.UdpSessions, cfg.UdpBatchSize)
+		} else {
+			go s.listenForClientConnections(listener, cfg.ReadBufferSize, cfg.UdpSessions)
+		}
+	}
+}
+
+// stopAccepting Nothing to do, datagrams that are not part of an existing
+// session are ignored once the server is stopping
+func (s *UdpServer) stopAccepting() error {
 	return nil
 }
 
 func (s *UdpServer) close() error {
-	err := s.listener.Close()
-	s.listener = nil
+	s.stopWatchingInterface()
+	listener, shards := s.setListeners(nil, nil)
+	err := listener.Close()
+	for _, shard := range shards {
+		_ = shard.Close()
+	}
 	s.listenContext = nil
 	s.listenCancelFunc = nil
 
-	close(s.newConnChan)
+	s.connections.Range(func(_, conn any) bool {
+		closeErr := s.CloseClient(conn.(*Connection).ID())
+		if closeErr != nil {
+			s.SendError(closeErr)
+		}
+		return true
+	})
+
+	s.closeAccept()
 	s.CloseErrors()
-	s.SetIsRunning(false)
+	s.setStopped()
 
 	if errors.Is(err, syscall.EINVAL) {
 		return nil
@@ -71,40 +192,113 @@ func (s *UdpServer) close() error {
 }
 
 func (s *UdpServer) configureListener(bindAddress string) error {
-	s.listener = nil
+	s.setListeners(nil, nil)
 
-	addr, err := net.ResolveUDPAddr("udp", bindAddress)
+	ctx, cancel := context.WithCancel(context.Background())
+	s.listenContext = ctx
+	s.listenCancelFunc = cancel
+
+	udpListener, err := s.inheritedPacketConn()
 	if err != nil {
 		return err
 	}
 
-	var cfg = net.ListenConfig{
-		Control: socket.ControlFunc,
+	var shards []*net.UDPConn
+	if udpListener == nil {
+		udpListener, shards, err = s.bind(bindAddress)
+		if err != nil {
+			return err
+		}
+	} else {
+		// Multicast options are not applied to pre-opened sockets, only the groups are joined
+		err = s.multicastOptions().JoinGroups(udpListener, s.multicastGroups()...)
+		if err != nil {
+			_ = udpListener.Close()
+			return err
+		}
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	s.listenContext = ctx
-	s.listenCancelFunc = cancel
+	s.setListeners(udpListener, shards)
+
+	return nil
+}
+
+// bind Binds a socket to the address and joins the multicast groups, or
+// binds the shards along with it if there are none (see ListenerShards)
+func (s *UdpServer) bind(bindAddress string) (*net.UDPConn, []*net.UDPConn, error) {
+	addr, err := net.ResolveUDPAddr("udp", bindAddress)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	multicastOptions := s.multicastOptions()
+	var cfg = net.ListenConfig{
+		Control: multicastOptions.Control(s.Config().SocketOptions.Control(socket.ControlFunc)),
+	}
 
 	listener, err := cfg.ListenPacket(s.listenContext, "udp4", addr.String())
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
+	udpListener := listener.(*net.UDPConn)
 
-	s.listener = listener.(*net.UDPConn)
+	// Multicast datagrams are delivered to every shard, so are not sharded
+	groups := s.multicastGroups()
+	if len(groups) > 0 {
+		err = multicastOptions.JoinGroups(udpListener, groups...)
+		if err != nil {
+			_ = udpListener.Close()
+			return nil, nil, err
+		}
+		return udpListener, nil, nil
+	}
 
-	return nil
+	// The shards bind to the address of the first socket, in case the port is 0
+	var shards []*net.UDPConn
+	for i := 1; i < s.shardCount(); i++ {
+		shard, shardErr := cfg.ListenPacket(s.listenContext, "udp4", udpListener.LocalAddr().String())
+		if shardErr != nil {
+			_ = udpListener.Close()
+			for _, opened := range shards {
+				_ = opened.Close()
+			}
+			return nil, nil, shardErr
+		}
+		shards = append(shards, shard.(*net.UDPConn))
+	}
+
+	return udpListener, shards, nil
+}
+
+func (s *UdpServer) multicastOptions() socket.MulticastOptions {
+	cfg := s.Config()
+	return socket.MulticastOptions{
+		Interface: cfg.MulticastInterface,
+		TTL:       cfg.MulticastTTL,
+		Loopback:  cfg.MulticastLoopback,
+	}
 }
 
-func (s *UdpServer) listenForClientConnections(readBufferSize int) {
+// multicastGroups Binding to a multicast address only filters datagrams, the
+// group must be joined
+func (s *UdpServer) multicastGroups() []string {
+	cfg := s.Config()
+	groups := cfg.MulticastGroups
+	if socket.IsMulticast(cfg.Address) {
+		groups = append([]string{cfg.Address}, groups...)
+	}
+	return groups
+}
+
+func (s *UdpServer) listenForClientConnections(listener *net.UDPConn, readBufferSize int, useSessions bool) {
 	buffer := make([]byte, readBufferSize)
 
 	for {
-		if !s.IsRunning() || s.listener == nil {
+		if current, _ := s.listeners(); !s.IsRunning() || current == nil {
 			return
 		}
 
-		count, remoteAddr, err := s.listener.ReadFrom(buffer)
+		count, remoteAddr, err := listener.ReadFrom(buffer)
 		if err != nil {
 			if errors.Is(err, net.ErrClosed) {
 				return
@@ -121,37 +315,139 @@ func (s *UdpServer) listenForClientConnections(readBufferSize int) {
 		data := make([]byte, count)
 		copy(data, buffer[:count])
 
-		udpConn := &UdpConn{}
-		udpConn.DataReader = *stream.NewDataReader(data)
-		udpConn.remoteAddress = remoteAddr
+		s.handleDatagram(remoteAddr, data, useSessions)
+	}
+}
 
-		conn := &Connection{}
-		conn.ConfigureUDP(s, udpConn)
+// listenForClientConnectionsBatched Same as listenForClientConnections,
+// except up to batchSize datagrams are read per system call (recvmmsg)
+// and the datagrams of each batch share a single allocation.
+func (s *UdpServer) listenForClientConnectionsBatched(listener *net.UDPConn, readBufferSize int, useSessions bool, batchSize int) {
+	batchConn, err := socket.NewBatchConn(listener, batchSize, readBufferSize)
+	if err != nil {
+		s.SendError(err)
+		s.listenForClientConnections(listener, readBufferSize, useSessions)
+		return
+	}
 
-		select {
-		case s.newConnChan <- conn:
-			break
-		default:
+	for {
+		if current, _ := s.listeners(); !s.IsRunning() || current == nil {
+			return
+		}
+
+		datagrams, err := batchConn.ReadBatch()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+
+			s.SendError(err)
+			continue
+		}
+
+		size := 0
+		for _, datagram := range datagrams {
+			size += len(datagram.Data)
+		}
+		slab := make([]byte, size)
+
+		for _, datagram := range datagrams {
+			count := len(datagram.Data)
+			if count < 1 {
+				continue
+			}
+
+			data := slab[:count:count]
+			slab = slab[count:]
+			copy(data, datagram.Data)
+
+			s.handleDatagram(datagram.Addr, data, useSessions)
 		}
 	}
 }
 
-func (s *UdpServer) read(conn *Connection, buffer []byte) (int, error) {
+func (s *UdpServer) handleDatagram(remoteAddr net.Addr, data []byte, useSessions bool) {
+	if useSessions {
+		s.routeToSession(remoteAddr, data)
+		return
+	}
+
+	if s.IsStopping() {
+		return
+	}
+
+	// Datagrams are not registered as connections, so HostConnectionLimit does not apply
+	if !s.accessFilter.Allowed(acl.Host(remoteAddr.String())) {
+		s.SendError(fmt.Errorf("%w : %s", comerr.ErrConnectionDenied, remoteAddr))
+		return
+	}
+
+	udpConn := &UdpConn{}
+	udpConn.DataReader = *stream.NewDataReader(data)
+	udpConn.remoteAddress = remoteAddr
+
+	conn := &Connection{}
+	conn.ConfigureUDP(s, udpConn, s.localAddress())
+	s.limitConnection(conn)
+	s.acceptConnection(conn)
+}
+
+// WriteBatch Write each datagram to its address using as few system calls
+// as possible (sendmmsg), returning the number of datagrams written.
+func (s *UdpServer) WriteBatch(datagrams []socket.Datagram) (int, error) {
+	listener, _ := s.listeners()
+	if listener == nil {
+		return 0, net.ErrClosed
+	}
+
+	if len(datagrams) == 0 {
+		return 0, nil
+	}
+
+	batchConn, err := socket.NewBatchWriter(listener, len(datagrams))
+	if err != nil {
+		return 0, err
+	}
+
+	return batchConn.WriteBatch(datagrams)
+}
+
+func (s *UdpServer) read(ctx context.Context, conn *Connection, buffer []byte) (int, error) {
 	if conn == nil || conn.udpConn == nil {
 		return -1, net.ErrClosed
 	}
 
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+
+	if conn.udpConn.queue != nil {
+		return s.readSession(ctx, conn, buffer)
+	}
+
 	count, err := conn.udpConn.Read(buffer)
 	err = socket.SinkReadWriteError(err)
 	return count, err
 }
 
-func (s *UdpServer) write(conn *Connection, data []byte) (int, error) {
-	if conn == nil || conn.udpConn == nil || s.listener == nil {
+func (s *UdpServer) write(ctx context.Context, conn *Connection, data []byte) (int, error) {
+	listener, _ := s.listeners()
+	if conn == nil || conn.udpConn == nil || listener == nil {
 		return -1, net.ErrClosed
 	}
 
-	count, err := s.listener.WriteTo(data, conn.udpConn.RemoteAddr())
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+
+	if conn.udpConn.queue != nil {
+		if conn.udpConn.isClosed() {
+			return -1, net.ErrClosed
+		}
+		conn.NotIdle()
+	}
+
+	count, err := listener.WriteTo(data, conn.udpConn.RemoteAddr())
 	err = socket.SinkReadWriteError(err)
 	return count, err
 }
@@ -169,6 +465,108 @@ func (s *UdpServer) handleListenCancel() {
 	}
 }
 
+/*******************************************************************************
+ SESSIONS
+*******************************************************************************/
+
+// routeToSession Queue the datagram on the remote address's session,
+// starting a new session if there is none and the server is not stopping.
+func (s *UdpServer) routeToSession(remoteAddr net.Addr, data []byte) {
+	if value, ok := s.sessions.Load(remoteAddr.String()); ok {
+		conn := value.(*Connection)
+		conn.NotIdle()
+		conn.udpConn.enqueue(data, s.dropDatagram)
+		return
+	}
+
+	if s.IsStopping() {
+		return
+	}
+
+	cfg := s.Config()
+
+	err := s.verifyConnectionLimit(cfg.ClientConnectionLimit)
+	if err != nil {
+		s.SendError(err)
+		return
+	}
+
+	err = s.verifyAccess(remoteAddr.String())
+	if err != nil {
+		s.SendError(err)
+		return
+	}
+
+	udpConn := newUdpSessionConn(remoteAddr, cfg.UdpSessionQueueSize)
+	udpConn.enqueue(data, s.dropDatagram)
+
+	conn := &Connection{}
+	conn.ConfigureUDPSession(s, udpConn, s.localAddress(), int64(cfg.IdleConnectionTimeoutMs), s.CloseClient)
+	s.limitConnection(conn)
+
+	s.sessions.Store(remoteAddr.String(), conn)
+	s.connections.Store(conn.ID(), conn)
+	s.acceptConnection(conn)
+}
+
+// readSession Reads what remains of the current datagram, otherwise waits
+// up to ReadTimeoutUs for the next one, returning 0 bytes if none arrive.
+func (s *UdpServer) readSession(ctx context.Context, conn *Connection, buffer []byte) (int, error) {
+	udpConn := conn.udpConn
+
+	count, _ := udpConn.Read(buffer)
+	if count > 0 {
+		return count, nil
+	}
+
+	timer := time.NewTimer(time.Duration(s.readTimeoutUs) * time.Microsecond)
+	defer timer.Stop()
+
+	select {
+	case data := <-udpConn.queue:
+		udpConn.DataReader = *stream.NewDataReader(data)
+		count, _ = udpConn.Read(buffer)
+		return count, nil
+	case <-timer.C:
+		return 0, nil
+	case <-ctx.Done():
+		return -1, ctx.Err()
+	case <-udpConn.closeChan:
+		return -1, net.ErrClosed
+	}
+}
+
+func (s *UdpServer) dropDatagram(remoteAddr net.Addr) {
+	s.droppedDatagrams.Add(1)
+	s.SendError(fmt.Errorf("%w : dropped datagram from %s", comerr.ErrChannelOverflow, remoteAddr))
+}
+
+// pruneIdleSessions Closes idle sessions until doneChan, which belongs to
+// the current run of the server, is closed
+func (s *UdpServer) pruneIdleSessions(doneChan <-chan struct{}) {
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		s.connections.Range(func(_ any, value any) bool {
+			conn := value.(*Connection)
+			if conn.IsIdle() {
+				err := conn.Close()
+				if err != nil {
+					s.SendError(err)
+				}
+			}
+			return true
+		})
+
+		select {
+		case <-doneChan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
 /*******************************************************************************
  CONNECTION
 *******************************************************************************/
@@ -176,8 +574,50 @@ func (s *UdpServer) handleListenCancel() {
 type UdpConn struct {
 	stream.DataReader
 	remoteAddress net.Addr
+
+	// Only used by sessions, nil otherwise
+	queue     chan []byte
+	closeChan chan bool
+	closeOnce sync.Once
+}
+
+func newUdpSessionConn(remoteAddr net.Addr, queueSize int) *UdpConn {
+	if queueSize < 1 {
+		queueSize = 1
+	}
+	return &UdpConn{
+		remoteAddress: remoteAddr,
+		queue:         make(chan []byte, queueSize),
+		closeChan:     make(chan bool),
+	}
 }
 
 func (c *UdpConn) RemoteAddr() net.Addr {
 	return c.remoteAddress
 }
+
+// enqueue Datagrams received while the queue is full are dropped
+func (c *UdpConn) enqueue(data []byte, onDrop func(net.Addr)) {
+	select {
+	case c.queue <- data:
+	default:
+		onDrop(c.remoteAddress)
+	}
+}
+
+func (c *UdpConn) close() {
+	if c.closeChan != nil {
+		c.closeOnce.Do(func() {
+			close(c.closeChan)
+		})
+	}
+}
+
+func (c *UdpConn) isClosed() bool {
+	select {
+	case <-c.closeChan:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"crypto/tls"
+	"net"
+	"time"
+	"tonysoft.com/comm/internal/transport"
+	"tonysoft.com/comm/internal/websocket"
+	"tonysoft.com/comm/pkg/comerr"
+)
+
+// webSocketURL Returns the parts of Address if it is a ws:// or wss:// URL,
+// nil otherwise
+func (s *TcpServer) webSocketURL() (*transport.WebSocketURL, error) {
+	cfg := s.Config()
+	if !transport.IsWebSocketAddress(cfg.Address) {
+		return nil, nil
+	}
+
+	wsURL, err := transport.ParseWebSocketAddress(cfg.Address, cfg.Port)
+	if err != nil {
+		return nil, err
+	}
+
+	if wsURL.Secure && cfg.TLSConfig == nil {
+		return nil, comerr.ErrTLSConfigRequired
+	}
+
+	return &wsURL, nil
+}
+
+// acceptWebSocket Performs the TLS (for wss://) and WebSocket handshakes
+// within HandshakeTimeoutMs.  Upgrades are accepted for the URL's path only,
+// unless it is "/", in which case any path is accepted.
+func (s *TcpServer) acceptWebSocket(netConn *net.TCPConn) (*websocket.Conn, error) {
+	cfg := s.Config()
+
+	err := netConn.SetDeadline(time.Now().Add(time.Duration(cfg.HandshakeTimeoutMs) * time.Millisecond))
+	if err != nil {
+		return nil, err
+	}
+
+	var conn net.Conn = netConn
+	if s.webSocket.Secure {
+		tlsConn := tls.Server(netConn, cfg.TLSConfig)
+		err = tlsConn.Handshake()
+		if err != nil {
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	path := s.webSocket.Path
+	if path == "/" {
+		path = ""
+	}
+
+	ws, err := websocket.Accept(conn, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ws, netConn.SetDeadline(time.Time{})
+}
@@ -0,0 +1,15 @@
+package socket
+
+// Addr Implements net.Addr for connections whose address is only known as a string
+type Addr struct {
+	Net     string
+	Address string
+}
+
+func (a *Addr) Network() string {
+	return a.Net
+}
+
+func (a *Addr) String() string {
+	return a.Address
+}
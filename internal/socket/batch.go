@@ -0,0 +1,200 @@
+package socket
+
+import (
+	"fmt"
+	"golang.org/x/sys/unix"
+	"net"
+	"syscall"
+	"tonysoft.com/comm/pkg/comerr"
+	"unsafe"
+)
+
+// Datagram A UDP datagram read or written in a batch.  Addr may be nil
+// when writing to a connected socket.
+type Datagram struct {
+	Data []byte
+	Addr *net.UDPAddr
+}
+
+// mmsghdr Matches struct mmsghdr from <sys/socket.h>
+type mmsghdr struct {
+	hdr unix.Msghdr
+	len uint32
+}
+
+// BatchConn Reads and writes batches of IPv4 datagrams with a single
+// recvmmsg or sendmmsg system call, rather than one system call per
+// datagram.  The buffers used for reading are allocated once and reused,
+// so datagrams returned by ReadBatch are only valid until the next call.
+// Not thread-safe, use one BatchConn per reading or writing Go routine.
+type BatchConn struct {
+	rawConn syscall.RawConn
+
+	hdrs    []mmsghdr
+	iovecs  []unix.Iovec
+	names   []unix.RawSockaddrInet4
+	buffers [][]byte // nil if created by NewBatchWriter
+}
+
+// NewBatchConn Reads and writes up to batchSize datagrams per system call,
+// reading each into a buffer of readBufferSize bytes
+func NewBatchConn(conn *net.UDPConn, batchSize int, readBufferSize int) (*BatchConn, error) {
+	if batchSize < 1 || readBufferSize < 1 {
+		return nil, fmt.Errorf("%w : batch size %d, read buffer size %d", comerr.ErrInvalidBatchSize,
+			batchSize, readBufferSize)
+	}
+
+	b, err := newBatchConn(conn, batchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	// One contiguous allocation shared by all read buffers
+	b.buffers = make([][]byte, batchSize)
+	slab := make([]byte, batchSize*readBufferSize)
+	for i := range b.buffers {
+		b.buffers[i] = slab[i*readBufferSize : (i+1)*readBufferSize : (i+1)*readBufferSize]
+	}
+
+	return b, nil
+}
+
+// NewBatchWriter Only writes, up to batchSize datagrams per system call,
+// so no read buffers are allocated
+func NewBatchWriter(conn *net.UDPConn, batchSize int) (*BatchConn, error) {
+	if batchSize < 1 {
+		return nil, fmt.Errorf("%w : batch size %d", comerr.ErrInvalidBatchSize, batchSize)
+	}
+	return newBatchConn(conn, batchSize)
+}
+
+func newBatchConn(conn *net.UDPConn, batchSize int) (*BatchConn, error) {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	return &BatchConn{
+		rawConn: rawConn,
+		hdrs:    make([]mmsghdr, batchSize),
+		iovecs:  make([]unix.Iovec, batchSize),
+		names:   make([]unix.RawSockaddrInet4, batchSize),
+	}, nil
+}
+
+// ReadBatch Blocks until at least one datagram is available, then reads
+// as many as are available up to the batch size, returning the datagrams
+// read.  Datagrams larger than the read buffer size are truncated.
+func (b *BatchConn) ReadBatch() ([]Datagram, error) {
+	if b.buffers == nil {
+		return nil, fmt.Errorf("%w : created by NewBatchWriter", comerr.ErrNotImplemented)
+	}
+
+	for i := range b.hdrs {
+		b.iovecs[i].Base = &b.buffers[i][0]
+		b.iovecs[i].SetLen(len(b.buffers[i]))
+
+		b.hdrs[i] = mmsghdr{}
+		b.hdrs[i].hdr.Name = (*byte)(unsafe.Pointer(&b.names[i]))
+		b.hdrs[i].hdr.Namelen = unix.SizeofSockaddrInet4
+		b.hdrs[i].hdr.Iov = &b.iovecs[i]
+		b.hdrs[i].hdr.SetIovlen(1)
+	}
+
+	count, err := b.do(b.rawConn.Read, unix.SYS_RECVMMSG)
+	if err != nil {
+		return nil, err
+	}
+
+	datagrams := make([]Datagram, count)
+	for i := 0; i < count; i++ {
+		datagrams[i].Data = b.buffers[i][:b.hdrs[i].len]
+		datagrams[i].Addr = udpAddrFromRaw(&b.names[i])
+	}
+
+	return datagrams, nil
+}
+
+// WriteBatch Writes the datagrams using as few system calls as possible,
+// returning the number of datagrams written.
+func (b *BatchConn) WriteBatch(datagrams []Datagram) (int, error) {
+	written := 0
+
+	for written < len(datagrams) {
+		batch := datagrams[written:]
+		if len(batch) > len(b.hdrs) {
+			batch = batch[:len(b.hdrs)]
+		}
+
+		for i, datagram := range batch {
+			b.hdrs[i] = mmsghdr{}
+			b.iovecs[i] = unix.Iovec{}
+			if len(datagram.Data) > 0 {
+				b.iovecs[i].Base = &datagram.Data[0]
+				b.iovecs[i].SetLen(len(datagram.Data))
+			}
+			b.hdrs[i].hdr.Iov = &b.iovecs[i]
+			b.hdrs[i].hdr.SetIovlen(1)
+
+			if datagram.Addr != nil {
+				ip4 := datagram.Addr.IP.To4()
+				if ip4 == nil {
+					return written, &net.AddrError{Err: "not an IPv4 address", Addr: datagram.Addr.String()}
+				}
+				b.names[i] = unix.RawSockaddrInet4{Family: unix.AF_INET}
+				copy(b.names[i].Addr[:], ip4)
+				port := (*[2]byte)(unsafe.Pointer(&b.names[i].Port))
+				port[0] = byte(datagram.Addr.Port >> 8)
+				port[1] = byte(datagram.Addr.Port)
+				b.hdrs[i].hdr.Name = (*byte)(unsafe.Pointer(&b.names[i]))
+				b.hdrs[i].hdr.Namelen = unix.SizeofSockaddrInet4
+			}
+		}
+
+		count, err := b.do(b.rawConn.Write, unix.SYS_SENDMMSG, len(batch))
+		written += count
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+// do Performs the system call once the socket is ready, as per the poller
+func (b *BatchConn) do(wait func(func(uintptr) bool) error, trap uintptr, length ...int) (int, error) {
+	vlen := len(b.hdrs)
+	if len(length) > 0 {
+		vlen = length[0]
+	}
+
+	var count int
+	var opErr error
+
+	err := wait(func(fd uintptr) bool {
+		r, _, errno := unix.Syscall6(trap, fd, uintptr(unsafe.Pointer(&b.hdrs[0])), uintptr(vlen),
+			unix.MSG_DONTWAIT, 0, 0)
+		switch {
+		case errno == unix.EAGAIN || errno == unix.EINTR:
+			return false
+		case errno != 0:
+			opErr = errno
+		default:
+			count = int(r)
+		}
+		return true
+	})
+
+	if err != nil {
+		return count, err
+	}
+	return count, opErr
+}
+
+func udpAddrFromRaw(raw *unix.RawSockaddrInet4) *net.UDPAddr {
+	port := (*[2]byte)(unsafe.Pointer(&raw.Port))
+	return &net.UDPAddr{
+		IP:   net.IPv4(raw.Addr[0], raw.Addr[1], raw.Addr[2], raw.Addr[3]),
+		Port: int(port[0])<<8 | int(port[1]),
+	}
+}
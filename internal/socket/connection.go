@@ -1,7 +1,9 @@
 package socket
 
 import (
+	"context"
 	"io"
+	"net"
 	"sync/atomic"
 	"time"
 	"tonysoft.com/comm/internal/comobj"
@@ -26,6 +28,10 @@ type Connection interface {
 	io.Reader
 	io.Writer
 	io.Closer
+	ReadContext(context.Context, []byte) (int, error)
+	WriteContext(context.Context, []byte) (int, error)
+	LocalAddr() net.Addr
+	RemoteAddr() net.Addr
 }
 
 type DefaultConnection struct {
@@ -46,6 +52,12 @@ func (c *DefaultConnection) RemoteAddress() string {
 	return c.remoteAddress
 }
 
+// SetRemoteAddress Used when the address of the remote end is learned after
+// the connection is configured (e.g., from a PROXY protocol header)
+func (c *DefaultConnection) SetRemoteAddress(remoteAddress string) {
+	c.remoteAddress = remoteAddress
+}
+
 func (c *DefaultConnection) Read(_ []byte) (int, error) {
 	// Structs that embed DefaultConnection to implement the
 	// Connection interface should shadow/override this function
@@ -58,6 +70,28 @@ func (c *DefaultConnection) Write(_ []byte) (int, error) {
 	return -1, comerr.ErrNotImplemented
 }
 
+func (c *DefaultConnection) ReadContext(_ context.Context, _ []byte) (int, error) {
+	// Structs that embed DefaultConnection to implement the
+	// Connection interface should shadow/override this function
+	return -1, comerr.ErrNotImplemented
+}
+
+func (c *DefaultConnection) WriteContext(_ context.Context, _ []byte) (int, error) {
+	// Structs that embed DefaultConnection to implement the
+	// Connection interface should shadow/override this function
+	return -1, comerr.ErrNotImplemented
+}
+
+func (c *DefaultConnection) LocalAddr() net.Addr {
+	// Structs that embed DefaultConnection to implement the
+	// Connection interface should shadow/override this function
+	return nil
+}
+
+func (c *DefaultConnection) RemoteAddr() net.Addr {
+	return &Addr{Net: "unknown", Address: c.remoteAddress}
+}
+
 func (c *DefaultConnection) Close() error {
 	if c == nil || !c.IsConnected() {
 		return nil
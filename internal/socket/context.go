@@ -0,0 +1,35 @@
+package socket
+
+import (
+	"context"
+	"time"
+)
+
+// AbortOnDone Watches the context while a blocking IO operation is in flight,
+// setting the deadline to a time in the past (via the given function) if the
+// context is done before the operation completes, which unblocks the pending
+// IO immediately.  The returned function must be called once the operation
+// returns and reports whether the operation was aborted by the context.
+func AbortOnDone(ctx context.Context, setDeadline func(time.Time) error) func() bool {
+	if ctx.Done() == nil {
+		return func() bool { return false }
+	}
+
+	doneChan := make(chan bool)
+	abortedChan := make(chan bool, 1)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = setDeadline(time.Unix(1, 0))
+			abortedChan <- true
+		case <-doneChan:
+			abortedChan <- false
+		}
+	}()
+
+	return func() bool {
+		close(doneChan)
+		return <-abortedChan
+	}
+}
@@ -16,3 +16,18 @@ func SinkReadWriteError(err error) error {
 		return err
 	}
 }
+
+// IsAlive Peeks at the socket, without blocking or consuming any data, to
+// determine whether the remote end has closed the connection.
+func IsAlive(fd int) bool {
+	buffer := make([]byte, 1)
+	count, _, err := unix.Recvfrom(fd, buffer, unix.MSG_PEEK|unix.MSG_DONTWAIT)
+	switch err {
+	case nil:
+		return count > 0
+	case unix.EAGAIN, unix.EINTR:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,140 @@
+package socket
+
+import (
+	"fmt"
+	"golang.org/x/sys/unix"
+	"net"
+	"net/netip"
+	"syscall"
+)
+
+// MulticastOptions Options applied to UDP sockets used to send or receive
+// multicast and broadcast datagrams.
+type MulticastOptions struct {
+	Interface string // name of the interface used for multicast, "" means the system default
+	TTL       int    // hop limit for outgoing multicast datagrams
+	Loopback  bool   // if true outgoing multicast datagrams are looped back to the host
+	Broadcast bool   // if true datagrams may be sent to broadcast addresses
+}
+
+// IsMulticast Returns true if host is an IPv4 multicast address
+func IsMulticast(host string) bool {
+	addr, err := netip.ParseAddr(host)
+	return err == nil && addr.Is4() && addr.IsMulticast()
+}
+
+// IsBroadcast Returns true if host is the IPv4 limited broadcast address.
+// Directed (subnet) broadcast addresses cannot be recognized without
+// knowing the subnet, which is why Broadcast can be set explicitly.
+func IsBroadcast(host string) bool {
+	addr, err := netip.ParseAddr(host)
+	return err == nil && addr == netip.AddrFrom4([4]byte{255, 255, 255, 255})
+}
+
+// Control Returns a function for use with net.Dialer or net.ListenConfig
+// that applies the options to the socket, after applying control if not nil.
+func (o MulticastOptions) Control(control func(string, string, syscall.RawConn) error) func(string, string, syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		if control != nil {
+			if err := control(network, address, c); err != nil {
+				return err
+			}
+		}
+
+		ifIndex, err := interfaceIndex(o.Interface)
+		if err != nil {
+			return err
+		}
+
+		var sockErr error
+		err = c.Control(func(fd uintptr) {
+			sockErr = o.apply(int(fd), ifIndex)
+		})
+		if err != nil {
+			return err
+		}
+		return sockErr
+	}
+}
+
+func (o MulticastOptions) apply(fd int, ifIndex int) error {
+	if o.Broadcast {
+		err := unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_BROADCAST, 1)
+		if err != nil {
+			return fmt.Errorf("failed to enable broadcast : %w", err)
+		}
+	}
+
+	if o.TTL > 0 {
+		err := unix.SetsockoptInt(fd, unix.IPPROTO_IP, unix.IP_MULTICAST_TTL, o.TTL)
+		if err != nil {
+			return fmt.Errorf("failed to set multicast TTL : %w", err)
+		}
+	}
+
+	loopback := 0
+	if o.Loopback {
+		loopback = 1
+	}
+	err := unix.SetsockoptInt(fd, unix.IPPROTO_IP, unix.IP_MULTICAST_LOOP, loopback)
+	if err != nil {
+		return fmt.Errorf("failed to set multicast loopback : %w", err)
+	}
+
+	if ifIndex > 0 {
+		err = unix.SetsockoptIPMreqn(fd, unix.IPPROTO_IP, unix.IP_MULTICAST_IF, &unix.IPMreqn{Ifindex: int32(ifIndex)})
+		if err != nil {
+			return fmt.Errorf("failed to set multicast interface : %w", err)
+		}
+	}
+
+	return nil
+}
+
+// JoinGroups Join the multicast groups on the options' interface
+func (o MulticastOptions) JoinGroups(conn *net.UDPConn, groups ...string) error {
+	ifIndex, err := interfaceIndex(o.Interface)
+	if err != nil {
+		return err
+	}
+
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	for _, group := range groups {
+		addr, e := netip.ParseAddr(group)
+		if e != nil || !addr.Is4() || !addr.IsMulticast() {
+			return fmt.Errorf("%s is not an IPv4 multicast address", group)
+		}
+
+		mreq := &unix.IPMreqn{Multiaddr: addr.As4(), Ifindex: int32(ifIndex)}
+
+		var sockErr error
+		err = rawConn.Control(func(fd uintptr) {
+			sockErr = unix.SetsockoptIPMreqn(int(fd), unix.IPPROTO_IP, unix.IP_ADD_MEMBERSHIP, mreq)
+		})
+		if err == nil {
+			err = sockErr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to join multicast group %s : %w", group, err)
+		}
+	}
+
+	return nil
+}
+
+func interfaceIndex(name string) (int, error) {
+	if name == "" {
+		return 0, nil
+	}
+
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return 0, err
+	}
+
+	return iface.Index, nil
+}
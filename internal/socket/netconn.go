@@ -0,0 +1,348 @@
+package socket
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	minIdleReadBackoff = time.Millisecond      // after the first read returning without data or waiting
+	maxIdleReadBackoff = 50 * time.Millisecond // reads waiting at least this long are not backed off
+)
+
+// ContextReadWriter Implemented by clients and server connections
+type ContextReadWriter interface {
+	ReadContext(context.Context, []byte) (int, error)
+	WriteContext(context.Context, []byte) (int, error)
+	LocalAddr() net.Addr
+	RemoteAddr() net.Addr
+}
+
+/*******************************************************************************
+ CONNECTION
+*******************************************************************************/
+
+// NetConn Adapts a client or server connection to net.Conn.  Unlike the
+// adapted connection, Read blocks until data is available, the connection
+// is closed, or the read deadline expires (rather than returning zero bytes
+// after ReadTimeoutUs elapses), as expected by consumers of net.Conn.
+type NetConn struct {
+	conn      ContextReadWriter
+	closeFunc func() error
+
+	readDeadline  *deadline
+	writeDeadline *deadline
+
+	closeOnce sync.Once
+	closeChan chan bool
+}
+
+func NewNetConn(conn ContextReadWriter, closeFunc func() error) *NetConn {
+	return &NetConn{
+		conn:          conn,
+		closeFunc:     closeFunc,
+		readDeadline:  newDeadline(),
+		writeDeadline: newDeadline(),
+		closeChan:     make(chan bool),
+	}
+}
+
+func (c *NetConn) Read(buffer []byte) (int, error) {
+	if len(buffer) == 0 {
+		return 0, nil
+	}
+
+	backoff := time.Duration(0)
+	for {
+		if err := c.checkState(c.readDeadline); err != nil {
+			return 0, err
+		}
+
+		start := time.Now()
+		ctx, cancel := c.opContext(c.readDeadline)
+		count, err := c.conn.ReadContext(ctx, buffer)
+		cancel()
+
+		if count > 0 {
+			return count, nil
+		}
+
+		if err == nil {
+			// The adapted connection's read timeout elapsed without data, or it
+			// returned right away (e.g., a non-blocking RFCOMM socket), in which
+			// case back off rather than spin
+			backoff = idleReadBackoff(backoff, time.Since(start))
+			if backoff > 0 {
+				c.pause(c.readDeadline, backoff)
+			}
+			continue
+		}
+
+		if errors.Is(err, context.Canceled) {
+			// Closed, deadline expired, or deadline moved (in which case retry)
+			continue
+		}
+
+		if errors.Is(err, net.ErrClosed) {
+			if c.isClosed() {
+				return 0, net.ErrClosed
+			}
+			return 0, io.EOF
+		}
+
+		return 0, err
+	}
+}
+
+func (c *NetConn) Write(data []byte) (int, error) {
+	written := 0
+
+	for written < len(data) {
+		if err := c.checkState(c.writeDeadline); err != nil {
+			return written, err
+		}
+
+		ctx, cancel := c.opContext(c.writeDeadline)
+		count, err := c.conn.WriteContext(ctx, data[written:])
+		cancel()
+
+		if count > 0 {
+			written += count
+		}
+
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				continue
+			}
+			return written, err
+		}
+
+		if count < 1 {
+			return written, io.ErrShortWrite
+		}
+	}
+
+	return written, nil
+}
+
+func (c *NetConn) Close() error {
+	err := net.ErrClosed
+	c.closeOnce.Do(func() {
+		close(c.closeChan)
+		err = c.closeFunc()
+	})
+	return err
+}
+
+func (c *NetConn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *NetConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *NetConn) SetDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	c.writeDeadline.set(t)
+	return nil
+}
+
+func (c *NetConn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	return nil
+}
+
+func (c *NetConn) SetWriteDeadline(t time.Time) error {
+	c.writeDeadline.set(t)
+	return nil
+}
+
+func (c *NetConn) isClosed() bool {
+	select {
+	case <-c.closeChan:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *NetConn) checkState(d *deadline) error {
+	if c.isClosed() {
+		return net.ErrClosed
+	}
+
+	select {
+	case <-d.wait():
+		return os.ErrDeadlineExceeded
+	default:
+		return nil
+	}
+}
+
+// pause Waits for the given duration, unless the connection is closed or
+// the deadline expires first
+func (c *NetConn) pause(d *deadline, duration time.Duration) {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-c.closeChan:
+	case <-d.wait():
+	}
+}
+
+// idleReadBackoff Returns how long to wait before reading again after a read
+// that took elapsed and returned no data, doubling the previous backoff
+// unless the read waited itself
+func idleReadBackoff(previous time.Duration, elapsed time.Duration) time.Duration {
+	if elapsed >= maxIdleReadBackoff {
+		return 0
+	}
+
+	backoff := previous * 2
+	if backoff < minIdleReadBackoff {
+		backoff = minIdleReadBackoff
+	} else if backoff > maxIdleReadBackoff {
+		backoff = maxIdleReadBackoff
+	}
+	return backoff
+}
+
+// opContext Returns a context that is cancelled when the connection is
+// closed or the deadline expires, aborting the pending operation.
+func (c *NetConn) opContext(d *deadline) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	expiredChan := d.wait()
+
+	go func() {
+		select {
+		case <-expiredChan:
+		case <-c.closeChan:
+		case <-ctx.Done():
+		}
+		cancel()
+	}()
+
+	return ctx, cancel
+}
+
+/*******************************************************************************
+ LISTENER
+*******************************************************************************/
+
+// NetListener Adapts a server to net.Listener, with every accepted
+// connection adapted to net.Conn via NetConn.
+type NetListener struct {
+	accept    func() <-chan Connection
+	addr      func() net.Addr
+	closeFunc func() error
+
+	closeOnce sync.Once
+	closeChan chan bool
+}
+
+func NewNetListener(accept func() <-chan Connection, addr func() net.Addr, closeFunc func() error) *NetListener {
+	return &NetListener{
+		accept:    accept,
+		addr:      addr,
+		closeFunc: closeFunc,
+		closeChan: make(chan bool),
+	}
+}
+
+func (l *NetListener) Accept() (net.Conn, error) {
+	select {
+	case conn, ok := <-l.accept():
+		if !ok {
+			return nil, net.ErrClosed
+		}
+		return NewNetConn(conn, conn.Close), nil
+	case <-l.closeChan:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *NetListener) Close() error {
+	err := net.ErrClosed
+	l.closeOnce.Do(func() {
+		close(l.closeChan)
+		err = l.closeFunc()
+	})
+	return err
+}
+
+func (l *NetListener) Addr() net.Addr {
+	return l.addr()
+}
+
+/*******************************************************************************
+ DEADLINE
+*******************************************************************************/
+
+// deadline Modeled after the one used by net.Pipe, the channel returned
+// by wait() is closed once the deadline expires.
+type deadline struct {
+	mutex      sync.Mutex
+	timer      *time.Timer
+	cancelChan chan bool
+}
+
+func newDeadline() *deadline {
+	return &deadline{cancelChan: make(chan bool)}
+}
+
+// set A zero value for t means the deadline is cleared
+func (d *deadline) set(t time.Time) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.timer != nil && !d.timer.Stop() {
+		<-d.cancelChan // wait for the timer's function to finish
+	}
+	d.timer = nil
+
+	closed := isClosedChan(d.cancelChan)
+	if t.IsZero() {
+		if closed {
+			d.cancelChan = make(chan bool)
+		}
+		return
+	}
+
+	if duration := time.Until(t); duration > 0 {
+		if closed {
+			d.cancelChan = make(chan bool)
+		}
+		cancelChan := d.cancelChan
+		d.timer = time.AfterFunc(duration, func() {
+			close(cancelChan)
+		})
+		return
+	}
+
+	if !closed {
+		close(d.cancelChan)
+	}
+}
+
+func (d *deadline) wait() chan bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.cancelChan
+}
+
+func isClosedChan(c <-chan bool) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
@@ -1,8 +1,11 @@
 package socket
 
 import (
+	"fmt"
 	"golang.org/x/sys/unix"
+	"strings"
 	"syscall"
+	"tonysoft.com/comm/pkg/comerr"
 )
 
 func ControlFunc(_, _ string, c syscall.RawConn) error {
@@ -18,3 +21,104 @@ func ControlFunc(_, _ string, c syscall.RawConn) error {
 		}
 	})
 }
+
+// Options Options such as TCP_NODELAY, keep-alive, buffer sizes and IP_TOS
+// applied to TCP, UDP and RFCOMM sockets when dialing and accepting (see
+// Config.SocketOptions), where they apply to the socket type.  The zero value
+// of each option leaves the system (or Go) default in place.
+type Options struct {
+	Nagle                bool   // if true TCP_NODELAY (set by Go) is cleared so that small writes are coalesced
+	KeepAliveIdleSec     int    // TCP_KEEPIDLE, >0 also enables SO_KEEPALIVE
+	KeepAliveIntervalSec int    // TCP_KEEPINTVL
+	KeepAliveCount       int    // TCP_KEEPCNT, probes sent before the connection is dropped
+	UserTimeoutMs        int    // TCP_USER_TIMEOUT, how long written data may remain unacknowledged
+	ReceiveBufferSize    int    // SO_RCVBUF, byte count (doubled by the kernel)
+	SendBufferSize       int    // SO_SNDBUF, byte count (doubled by the kernel)
+	TOS                  int    // IP_TOS, the DSCP value shifted left by 2 (e.g., 46<<2 for EF)
+	TTL                  int    // IP_TTL, hop limit for outgoing unicast packets
+	BindToDevice         string // SO_BINDTODEVICE, interface name
+}
+
+// IsZero Returns true if no option is set
+func (o Options) IsZero() bool {
+	return o == Options{}
+}
+
+// Control Returns a function for use with net.Dialer or net.ListenConfig
+// that applies the options to the socket before it is bound or connected,
+// after applying control if not nil.
+func (o Options) Control(control func(string, string, syscall.RawConn) error) func(string, string, syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		if control != nil {
+			if err := control(network, address, c); err != nil {
+				return err
+			}
+		}
+		return o.Apply(c, network)
+	}
+}
+
+// Apply Applies the options to a connected or accepted socket, where
+// network is "tcp", "udp" or "rfcomm" (optionally suffixed with 4 or 6)
+func (o Options) Apply(c syscall.RawConn, network string) error {
+	if o.IsZero() {
+		return nil
+	}
+
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		sockErr = o.Set(int(fd), network)
+	})
+	if err != nil {
+		return fmt.Errorf("%w : %v", comerr.ErrSetSocketOption, err)
+	}
+	return sockErr
+}
+
+// Set Applies the options to the socket's file descriptor, see Apply
+func (o Options) Set(fd int, network string) error {
+	isTcp := strings.HasPrefix(network, "tcp")
+	isIp := isTcp || strings.HasPrefix(network, "udp")
+
+	if o.BindToDevice != "" && isIp {
+		err := unix.SetsockoptString(fd, unix.SOL_SOCKET, unix.SO_BINDTODEVICE, o.BindToDevice)
+		if err != nil {
+			return optionError("SO_BINDTODEVICE", err)
+		}
+	}
+
+	socketOptions := []struct {
+		name  string
+		apply bool
+		level int
+		opt   int
+		value int
+	}{
+		{"SO_RCVBUF", o.ReceiveBufferSize > 0, unix.SOL_SOCKET, unix.SO_RCVBUF, o.ReceiveBufferSize},
+		{"SO_SNDBUF", o.SendBufferSize > 0, unix.SOL_SOCKET, unix.SO_SNDBUF, o.SendBufferSize},
+		{"IP_TOS", isIp && o.TOS > 0, unix.IPPROTO_IP, unix.IP_TOS, o.TOS},
+		{"IP_TTL", isIp && o.TTL > 0, unix.IPPROTO_IP, unix.IP_TTL, o.TTL},
+		{"TCP_NODELAY", isTcp && o.Nagle, unix.IPPROTO_TCP, unix.TCP_NODELAY, 0},
+		{"SO_KEEPALIVE", isTcp && o.KeepAliveIdleSec > 0, unix.SOL_SOCKET, unix.SO_KEEPALIVE, 1},
+		{"TCP_KEEPIDLE", isTcp && o.KeepAliveIdleSec > 0, unix.IPPROTO_TCP, unix.TCP_KEEPIDLE, o.KeepAliveIdleSec},
+		{"TCP_KEEPINTVL", isTcp && o.KeepAliveIntervalSec > 0, unix.IPPROTO_TCP, unix.TCP_KEEPINTVL, o.KeepAliveIntervalSec},
+		{"TCP_KEEPCNT", isTcp && o.KeepAliveCount > 0, unix.IPPROTO_TCP, unix.TCP_KEEPCNT, o.KeepAliveCount},
+		{"TCP_USER_TIMEOUT", isTcp && o.UserTimeoutMs > 0, unix.IPPROTO_TCP, unix.TCP_USER_TIMEOUT, o.UserTimeoutMs},
+	}
+
+	for _, opt := range socketOptions {
+		if !opt.apply {
+			continue
+		}
+		err := unix.SetsockoptInt(fd, opt.level, opt.opt, opt.value)
+		if err != nil {
+			return optionError(opt.name, err)
+		}
+	}
+
+	return nil
+}
+
+func optionError(name string, err error) error {
+	return fmt.Errorf("%w : %s : %v", comerr.ErrSetSocketOption, name, err)
+}
@@ -11,3 +11,9 @@ type Stream[T any] interface {
 	Error() error
 	io.Closer
 }
+
+// DropCounter Implemented by streams that count the instances
+// of T discarded because the channel's buffer was full.
+type DropCounter interface {
+	Dropped() uint64
+}
@@ -0,0 +1,81 @@
+package stream
+
+// OverflowPolicy Determines what happens to a value sent on a channel whose
+// buffer is full.
+type OverflowPolicy int
+
+const (
+	// DropNewest Discard the value being sent
+	DropNewest OverflowPolicy = iota
+	// DropOldest Discard the oldest buffered value to make room for the value being sent
+	DropOldest
+	// Block Wait until there is room for the value being sent
+	Block
+	// CloseConnection Discard the value being sent and close the connection it came from
+	CloseConnection
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case DropNewest:
+		return "DropNewest"
+	case DropOldest:
+		return "DropOldest"
+	case Block:
+		return "Block"
+	case CloseConnection:
+		return "CloseConnection"
+	}
+	return "Unknown"
+}
+
+// Send Send value on c according to policy, returning true if the value
+// was sent.  Every value discarded along the way (value itself or, for
+// DropOldest, the values removed to make room) is passed to onDrop, which
+// may be nil.  When blocking, Send gives up and drops the value once done
+// is closed.  DropOldest behaves like DropNewest for unbuffered channels.
+func Send[T any, D any](c chan T, value T, policy OverflowPolicy, done <-chan D, onDrop func(T)) bool {
+	select {
+	case c <- value:
+		return true
+	default:
+	}
+
+	drop := func(v T) {
+		if onDrop != nil {
+			onDrop(v)
+		}
+	}
+
+	switch policy {
+	case Block:
+		select {
+		case c <- value:
+			return true
+		case <-done:
+		}
+	case DropOldest:
+		if cap(c) == 0 {
+			break
+		}
+		for {
+			select {
+			case oldest := <-c:
+				drop(oldest)
+			default:
+			}
+
+			select {
+			case c <- value:
+				return true
+			case <-done:
+				drop(value)
+				return false
+			default:
+			}
+		}
+	}
+
+	drop(value)
+	return false
+}
@@ -0,0 +1,9 @@
+package transport
+
+import "strings"
+
+// IsMemoryAddress Returns true if address is a mem:// address, naming an
+// in-process listener (see memnet)
+func IsMemoryAddress(address string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(address)), "mem://")
+}
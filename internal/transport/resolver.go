@@ -0,0 +1,197 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"tonysoft.com/comm/pkg/comerr"
+)
+
+// defaultResolverTtl How long DefaultResolver caches the addresses of a hostname
+const defaultResolverTtl = 30 * time.Second
+
+// Resolver Looks up the IP addresses of hostnames, used when a Config's
+// ResolveHostnames is set
+type Resolver interface {
+	LookupIP(ctx context.Context, host string) ([]net.IP, error)
+}
+
+// DefaultResolver Used when ResolveHostnames is set without a Resolver
+var DefaultResolver Resolver = NewCachingResolver(SystemResolver{}, defaultResolverTtl)
+
+// SystemResolver Looks up hostnames using the system's configuration (DNS,
+// /etc/hosts, etc.)
+type SystemResolver struct{}
+
+func (SystemResolver) LookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	return net.DefaultResolver.LookupIP(ctx, "ip", host)
+}
+
+// StaticResolver Looks up hostnames in a fixed table, e.g., for tests
+type StaticResolver map[string][]net.IP
+
+func (r StaticResolver) LookupIP(_ context.Context, host string) ([]net.IP, error) {
+	ips, ok := r[host]
+	if !ok || len(ips) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return ips, nil
+}
+
+// CachingResolver Caches the addresses looked up by another Resolver until
+// their TTL elapses (or they are forgotten)
+type CachingResolver struct {
+	resolver Resolver
+	ttl      time.Duration
+
+	mutex   sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	ips     []net.IP
+	expires time.Time
+}
+
+func NewCachingResolver(resolver Resolver, ttl time.Duration) *CachingResolver {
+	return &CachingResolver{
+		resolver: resolver,
+		ttl:      ttl,
+		entries:  make(map[string]cacheEntry),
+	}
+}
+
+func (r *CachingResolver) LookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	r.mutex.Lock()
+	entry, ok := r.entries[host]
+	r.mutex.Unlock()
+
+	if ok && time.Now().Before(entry.expires) {
+		return entry.ips, nil
+	}
+
+	ips, err := r.resolver.LookupIP(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mutex.Lock()
+	r.entries[host] = cacheEntry{ips: ips, expires: time.Now().Add(r.ttl)}
+	r.mutex.Unlock()
+
+	return ips, nil
+}
+
+// Forget Removes the host's addresses from the cache, e.g., after failing to
+// connect to any of them
+func (r *CachingResolver) Forget(host string) {
+	r.mutex.Lock()
+	delete(r.entries, host)
+	r.mutex.Unlock()
+}
+
+// IsHostname Returns true if the host part of address (host or host:port)
+// is a hostname rather than an IP or MAC address
+func IsHostname(address string) bool {
+	host := strings.TrimSpace(address)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	if _, err := netip.ParseAddr(host); err == nil {
+		return false
+	}
+	if _, err := net.ParseMAC(host); err == nil {
+		return false
+	}
+
+	host = strings.TrimSuffix(host, ".")
+	if host == "" || len(host) > 253 {
+		return false
+	}
+
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// GetTypeFromHostnameOrAddress Same as GetTypeFromAddress, except hostnames
+// are accepted (as TCP) if allowHostnames is true
+func GetTypeFromHostnameOrAddress(address string, allowHostnames bool) (Type, error) {
+	transportType, err := GetTypeFromAddress(address)
+	if err != nil && allowHostnames && IsHostname(address) {
+		return TCP, nil
+	}
+	return transportType, err
+}
+
+// GetHostAndPortFromHostnameOrAddress Same as GetHostAndPortFromTcpAddress,
+// except hostnames are accepted if allowHostnames is true
+func GetHostAndPortFromHostnameOrAddress(address string, allowHostnames bool) (host string, port uint16, err error) {
+	if !allowHostnames || !IsHostname(address) {
+		return GetHostAndPortFromTcpAddress(address)
+	}
+
+	host, portPart, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", 0, comerr.ErrAddressFormatUnknown
+	}
+
+	p, err := strconv.ParseUint(portPart, 10, 16)
+	if err != nil {
+		return "", 0, comerr.ErrAddressFormatUnknown
+	}
+
+	return host, uint16(p), nil
+}
+
+// ResolveHost Returns the IP addresses of host, which is returned as is if
+// it is not a hostname.  If resolver is nil DefaultResolver is used.
+func ResolveHost(ctx context.Context, resolver Resolver, host string) ([]net.IP, error) {
+	if !IsHostname(host) {
+		ip := net.ParseIP(host)
+		if ip == nil && host != "localhost" {
+			return nil, fmt.Errorf("%w : %s", comerr.ErrAddressFormatUnknown, host)
+		}
+		if ip == nil {
+			ip = net.IPv4(127, 0, 0, 1)
+		}
+		return []net.IP{ip}, nil
+	}
+
+	if resolver == nil {
+		resolver = DefaultResolver
+	}
+
+	ips, err := resolver.LookupIP(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("%w : %s : %v", comerr.ErrResolveHostname, host, err)
+	}
+
+	return ips, nil
+}
+
+// ForgetHost Removes host from the cache of resolver (or DefaultResolver if
+// nil), if it has one, so that it is looked up again
+func ForgetHost(resolver Resolver, host string) {
+	if resolver == nil {
+		resolver = DefaultResolver
+	}
+	if cache, ok := resolver.(*CachingResolver); ok {
+		cache.Forget(host)
+	}
+}
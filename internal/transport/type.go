@@ -16,6 +16,8 @@ const (
 	TCP
 	UDP
 	RFCOMM
+	WebSocket
+	Memory
 )
 
 func GetTypeFromAddress(address string) (Type, error) {
@@ -25,22 +27,30 @@ func GetTypeFromAddress(address string) (Type, error) {
 		return NotSet, comerr.ErrAddressEmpty
 	}
 
+	if IsWebSocketAddress(address) {
+		return WebSocket, nil
+	}
+
+	if IsMemoryAddress(address) {
+		return Memory, nil
+	}
+
 	if address == "localhost" || strings.HasPrefix(address, "localhost:") {
 		return TCP, nil
 	}
 
-	_, err := netip.ParseAddr(address)
+	addr, err := netip.ParseAddr(address)
 	if err == nil {
-		return TCP, nil
+		return ipType(addr), nil
 	}
 
 	if strings.HasPrefix(address, ":") {
 		address = "0.0.0.0" + address
 	}
 
-	_, err = netip.ParseAddrPort(address)
+	addrPort, err := netip.ParseAddrPort(address)
 	if err == nil {
-		return TCP, nil
+		return ipType(addrPort.Addr()), nil
 	}
 
 	_, err = net.ParseMAC(address)
@@ -51,8 +61,16 @@ func GetTypeFromAddress(address string) (Type, error) {
 	return NotSet, comerr.ErrAddressFormatUnknown
 }
 
+// ipType Multicast and broadcast addresses can only be used with UDP
+func ipType(addr netip.Addr) Type {
+	if addr.IsMulticast() || addr == netip.AddrFrom4([4]byte{255, 255, 255, 255}) {
+		return UDP
+	}
+	return TCP
+}
+
 func GetHostAndPortFromTcpAddress(address string) (host string, port uint16, err error) {
-	if addrType, e := GetTypeFromAddress(address); e != nil || addrType != TCP {
+	if addrType, e := GetTypeFromAddress(address); e != nil || (addrType != TCP && addrType != UDP) {
 		return "", 0, comerr.ErrAddressFormatUnknown
 	}
 
@@ -0,0 +1,83 @@
+package transport
+
+import (
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"tonysoft.com/comm/pkg/comerr"
+)
+
+// WebSocketURL The parts of a ws:// or wss:// address
+type WebSocketURL struct {
+	Secure bool   // wss://, i.e., over TLS
+	Host   string // hostname or IP, "0.0.0.0" if empty
+	Port   uint16
+	Path   string // path and query, "/" if empty
+}
+
+// IsWebSocketAddress Returns true if address is a ws:// or wss:// URL
+func IsWebSocketAddress(address string) bool {
+	address = strings.ToLower(strings.TrimSpace(address))
+	return strings.HasPrefix(address, "ws://") || strings.HasPrefix(address, "wss://")
+}
+
+// ParseWebSocketAddress Parses a ws:// or wss:// URL, using defaultPort if
+// it has no port, or else 80 (ws://) or 443 (wss://) if defaultPort is 0
+func ParseWebSocketAddress(address string, defaultPort uint16) (WebSocketURL, error) {
+	if !IsWebSocketAddress(address) {
+		return WebSocketURL{}, comerr.ErrAddressFormatUnknown
+	}
+
+	u, err := url.Parse(strings.TrimSpace(address))
+	if err != nil {
+		return WebSocketURL{}, comerr.ErrAddressFormatUnknown
+	}
+
+	wsURL := WebSocketURL{
+		Secure: strings.EqualFold(u.Scheme, "wss"),
+		Host:   u.Hostname(),
+		Port:   defaultPort,
+		Path:   u.RequestURI(),
+	}
+
+	if wsURL.Host == "" {
+		wsURL.Host = "0.0.0.0"
+	}
+
+	if portPart := u.Port(); portPart != "" {
+		p, parseErr := strconv.ParseUint(portPart, 10, 16)
+		if parseErr != nil {
+			return WebSocketURL{}, comerr.ErrAddressFormatUnknown
+		}
+		wsURL.Port = uint16(p)
+	} else if wsURL.Port == 0 {
+		wsURL.Port = 80
+		if wsURL.Secure {
+			wsURL.Port = 443
+		}
+	}
+
+	return wsURL, nil
+}
+
+// HostPort Returns host:port, e.g., to dial
+func (u WebSocketURL) HostPort() string {
+	return net.JoinHostPort(u.Host, strconv.Itoa(int(u.Port)))
+}
+
+// WithHostPort Returns a copy of the URL with another host and port, e.g.,
+// the address of a peer using the same path
+func (u WebSocketURL) WithHostPort(host string, port uint16) WebSocketURL {
+	u.Host = host
+	u.Port = port
+	return u
+}
+
+func (u WebSocketURL) String() string {
+	scheme := "ws://"
+	if u.Secure {
+		scheme = "wss://"
+	}
+	return scheme + u.HostPort() + u.Path
+}
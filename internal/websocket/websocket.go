@@ -0,0 +1,417 @@
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"tonysoft.com/comm/pkg/comerr"
+)
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+
+	finBit  = 0x80
+	maskBit = 0x80
+
+	maxControlPayload = 125
+	maxHeaderBytes    = 8192 // limit on the handshake's HTTP header
+	acceptGuid        = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	closeNormal        = 1000
+	closeProtocolError = 1002
+)
+
+// Conn A WebSocket (RFC 6455) connection read and written as a stream of
+// bytes: each Write is sent as one binary message, and Read returns the
+// payload of the data messages received (binary or text) in order, so that
+// message boundaries are not preserved, as with TCP.  Control frames are
+// handled while reading.
+type Conn struct {
+	net.Conn
+	reader *bufio.Reader
+	client bool // frames sent by clients are masked, frames sent by servers are not
+
+	// State of the data frame being read, only used by Read
+	remaining uint64
+	mask      [4]byte
+	masked    bool
+	maskIndex int
+
+	closed atomic.Bool // set once a close frame is sent or received
+
+	writeMutex sync.Mutex
+	closeOnce  sync.Once
+}
+
+// Dial Performs the client side of the opening handshake on conn, e.g., a
+// TCP or TLS connection to host (host[:port]), requesting path
+func Dial(conn net.Conn, host string, path string) (*Conn, error) {
+	keyBytes := make([]byte, 16)
+	if _, err := rand.Read(keyBytes); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(keyBytes)
+
+	if path == "" {
+		path = "/"
+	}
+
+	request := "GET " + path + " HTTP/1.1\r\n" +
+		"Host: " + host + "\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + key + "\r\n" +
+		"Sec-WebSocket-Version: 13\r\n\r\n"
+
+	if _, err := io.WriteString(conn, request); err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	statusLine, header, err := readHeader(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := strings.Fields(statusLine)
+	if len(fields) < 2 || fields[1] != "101" {
+		return nil, fmt.Errorf("%w : %s", comerr.ErrWebSocketHandshake, statusLine)
+	}
+
+	if !headerContains(header, "Upgrade", "websocket") || header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		return nil, fmt.Errorf("%w : invalid upgrade response", comerr.ErrWebSocketHandshake)
+	}
+
+	return &Conn{Conn: conn, reader: reader, client: true}, nil
+}
+
+// Accept Performs the server side of the opening handshake on conn,
+// rejecting requests for any path other than path (unless empty)
+func Accept(conn net.Conn, path string) (*Conn, error) {
+	reader := bufio.NewReader(conn)
+	requestLine, header, err := readHeader(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := strings.Fields(requestLine)
+	if len(fields) != 3 || fields[0] != http.MethodGet {
+		return nil, reject(conn, http.StatusMethodNotAllowed, requestLine)
+	}
+
+	requestPath, _, _ := strings.Cut(fields[1], "?")
+	if path != "" && requestPath != path {
+		return nil, reject(conn, http.StatusNotFound, requestLine)
+	}
+
+	key := header.Get("Sec-WebSocket-Key")
+	if !headerContains(header, "Upgrade", "websocket") || !headerContains(header, "Connection", "upgrade") || key == "" {
+		return nil, reject(conn, http.StatusBadRequest, requestLine)
+	}
+
+	if header.Get("Sec-WebSocket-Version") != "13" {
+		_, _ = io.WriteString(conn, "HTTP/1.1 426 Upgrade Required\r\nSec-WebSocket-Version: 13\r\n\r\n")
+		return nil, fmt.Errorf("%w : unsupported version %q", comerr.ErrWebSocketHandshake, header.Get("Sec-WebSocket-Version"))
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+
+	if _, err = io.WriteString(conn, response); err != nil {
+		return nil, err
+	}
+
+	return &Conn{Conn: conn, reader: reader}, nil
+}
+
+func reject(conn net.Conn, status int, requestLine string) error {
+	_, _ = fmt.Fprintf(conn, "HTTP/1.1 %d %s\r\nConnection: close\r\n\r\n", status, http.StatusText(status))
+	return fmt.Errorf("%w : %d for %q", comerr.ErrWebSocketHandshake, status, requestLine)
+}
+
+// readHeader Reads the first line and header fields of an HTTP request or
+// response, leaving anything that follows in reader
+func readHeader(reader *bufio.Reader) (string, http.Header, error) {
+	header := http.Header{}
+	firstLine := ""
+	total := 0
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return "", nil, err
+		}
+
+		total += len(line)
+		if total > maxHeaderBytes {
+			return "", nil, fmt.Errorf("%w : header too long", comerr.ErrWebSocketHandshake)
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+
+		if firstLine == "" {
+			firstLine = line
+			continue
+		}
+
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return "", nil, fmt.Errorf("%w : invalid header %q", comerr.ErrWebSocketHandshake, line)
+		}
+		header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	return firstLine, header, nil
+}
+
+// headerContains Returns true if the comma separated values of the header
+// field include token, ignoring case
+func headerContains(header http.Header, name string, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, v := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func acceptKey(key string) string {
+	hash := sha1.Sum([]byte(key + acceptGuid))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// Read Reads the payload of data messages, replying to pings and to the
+// close handshake as they are received.  Returns io.EOF once the peer has
+// closed the connection.
+func (c *Conn) Read(buffer []byte) (int, error) {
+	if len(buffer) == 0 {
+		return 0, nil
+	}
+
+	for c.remaining == 0 {
+		if c.closed.Load() {
+			return 0, io.EOF
+		}
+
+		err := c.readFrameHeader()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if uint64(len(buffer)) > c.remaining {
+		buffer = buffer[:c.remaining]
+	}
+
+	count, err := c.reader.Read(buffer)
+	c.unmask(buffer[:count])
+	c.remaining -= uint64(count)
+
+	return count, err
+}
+
+// readFrameHeader Reads the next frame's header, handling control frames in
+// full.  The header is only consumed once complete, so that a read deadline
+// expiring part way through it leaves the stream intact.
+func (c *Conn) readFrameHeader() error {
+	start, err := c.reader.Peek(2)
+	if err != nil {
+		return err
+	}
+
+	length := 2
+	switch start[1] &^ maskBit {
+	case 126:
+		length += 2
+	case 127:
+		length += 8
+	}
+	masked := start[1]&maskBit != 0
+	if masked {
+		length += 4
+	}
+
+	header, err := c.reader.Peek(length)
+	if err != nil {
+		return err
+	}
+
+	opcode := header[0] & 0x0F
+	payloadLength := uint64(header[1] &^ maskBit)
+	switch payloadLength {
+	case 126:
+		payloadLength = uint64(binary.BigEndian.Uint16(header[2:]))
+	case 127:
+		payloadLength = binary.BigEndian.Uint64(header[2:])
+	}
+
+	// Clients must mask their frames while servers must not
+	if masked == c.client || header[0]&0x70 != 0 {
+		c.fail(closeProtocolError)
+		return fmt.Errorf("%w : invalid frame", comerr.ErrInvalidWebSocketFrame)
+	}
+
+	var mask [4]byte
+	if masked {
+		copy(mask[:], header[length-4:])
+	}
+
+	if opcode >= opClose {
+		if payloadLength > maxControlPayload || header[0]&finBit == 0 {
+			c.fail(closeProtocolError)
+			return fmt.Errorf("%w : invalid control frame", comerr.ErrInvalidWebSocketFrame)
+		}
+
+		// Control frames are small, so they are only consumed once fully received
+		frame, peekErr := c.reader.Peek(length + int(payloadLength))
+		if peekErr != nil {
+			return peekErr
+		}
+		payload := append([]byte(nil), frame[length:]...)
+		_, _ = c.reader.Discard(len(frame))
+
+		if masked {
+			for i := range payload {
+				payload[i] ^= mask[i%4]
+			}
+		}
+
+		return c.handleControlFrame(opcode, payload)
+	}
+
+	if opcode != opContinuation && opcode != opText && opcode != opBinary {
+		c.fail(closeProtocolError)
+		return fmt.Errorf("%w : unknown opcode %d", comerr.ErrInvalidWebSocketFrame, opcode)
+	}
+
+	_, _ = c.reader.Discard(length)
+	c.remaining = payloadLength
+	c.mask = mask
+	c.masked = masked
+	c.maskIndex = 0
+
+	return nil
+}
+
+func (c *Conn) handleControlFrame(opcode byte, payload []byte) error {
+	switch opcode {
+	case opPing:
+		return c.writeFrame(opPong, payload)
+	case opClose:
+		// Echo the status code, if any, to complete the close handshake
+		if len(payload) > 2 {
+			payload = payload[:2]
+		}
+		if !c.closed.Swap(true) {
+			_ = c.writeFrame(opClose, payload)
+		}
+		return io.EOF
+	}
+	return nil
+}
+
+func (c *Conn) unmask(data []byte) {
+	if !c.masked {
+		return
+	}
+	for i := range data {
+		data[i] ^= c.mask[c.maskIndex]
+		c.maskIndex = (c.maskIndex + 1) % 4
+	}
+}
+
+// Write Sends data as one binary message
+func (c *Conn) Write(data []byte) (int, error) {
+	err := c.writeFrame(opBinary, data)
+	if err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// writeFrame Sends the frame with a single write, so that frames written
+// concurrently (e.g., a pong while a message is being sent) do not interleave
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	frame := make([]byte, 0, 14+len(payload))
+	frame = append(frame, finBit|opcode)
+
+	var maskFlag byte
+	if c.client {
+		maskFlag = maskBit
+	}
+
+	switch length := len(payload); {
+	case length <= 125:
+		frame = append(frame, maskFlag|byte(length))
+	case length <= 0xFFFF:
+		frame = append(frame, maskFlag|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(length))
+	default:
+		frame = append(frame, maskFlag|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(length))
+	}
+
+	if c.client {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		frame = append(frame, mask[:]...)
+		start := len(frame)
+		frame = append(frame, payload...)
+		for i := range frame[start:] {
+			frame[start+i] ^= mask[i%4]
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	_, err := c.Conn.Write(frame)
+	return err
+}
+
+// fail Closes the connection with the status code after a protocol error
+func (c *Conn) fail(code uint16) {
+	if !c.closed.Swap(true) {
+		_ = c.writeFrame(opClose, binary.BigEndian.AppendUint16(nil, code))
+	}
+}
+
+// Close Starts the close handshake, without waiting for the peer's reply,
+// then closes the underlying connection
+func (c *Conn) Close() error {
+	err := net.ErrClosed
+	c.closeOnce.Do(func() {
+		if !c.closed.Swap(true) {
+			_ = c.Conn.SetWriteDeadline(time.Now().Add(100 * time.Millisecond))
+			_ = c.writeFrame(opClose, binary.BigEndian.AppendUint16(nil, closeNormal))
+		}
+		err = c.Conn.Close()
+	})
+	return err
+}
@@ -0,0 +1,11 @@
+package client
+
+// BatchWriter Implemented by UDP clients, use a type assertion to check
+// whether a Client supports writing datagrams in batches:
+//
+//	if bw, ok := c.(client.BatchWriter); ok {
+//		bw.WriteBatch(datagrams)
+//	}
+type BatchWriter interface {
+	WriteBatch([][]byte) (int, error)
+}
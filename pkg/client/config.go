@@ -1,6 +1,15 @@
 package client
 
-import _config "tonysoft.com/comm/internal/config/client"
+import (
+	_config "tonysoft.com/comm/internal/config/client"
+	"tonysoft.com/comm/internal/socket"
+)
+
+// Config The configuration of a Client, see NewConfig
+type Config = _config.Config
+
+// SocketOptions See socket.Options
+type SocketOptions = socket.Options
 
 func NewConfig(remoteAddress string, remotePort uint16, connectionless ...bool) _config.Config {
 	useUdp := false
@@ -1,29 +1,18 @@
 package client
 
 import (
-	"io"
 	_client "tonysoft.com/comm/internal/client"
-	"tonysoft.com/comm/internal/comobj"
-	"tonysoft.com/comm/internal/config"
 	_config "tonysoft.com/comm/internal/config/client"
 	"tonysoft.com/comm/internal/transport"
 )
 
 // Client Public interface for working with instances of Client
 // Thread-safe ✓
-type Client interface {
-	config.Configurable[_config.Config]
-	RemoteAddress() string
-	Start() error
-	Stop() error
-	comobj.Connectable
-	io.Reader
-	io.Writer
-}
+type Client = _client.Client
 
 // New Create a new instance of Client
 func New(cfg _config.Config) (Client, error) {
-	transportType, err := transport.GetTypeFromAddress(cfg.RemoteAddress)
+	transportType, err := transport.GetTypeFromHostnameOrAddress(cfg.RemoteAddress, cfg.ResolveHostnames)
 	if err != nil {
 		return nil, err
 	}
@@ -37,11 +26,35 @@ func New(cfg _config.Config) (Client, error) {
 		} else {
 			c = &_client.TcpClient{}
 		}
+	case transport.UDP:
+		c = &_client.UdpClient{}
 	case transport.RFCOMM:
 		c = &_client.RfcommClient{}
+	case transport.WebSocket:
+		c = &_client.WebSocketClient{}
+	case transport.Memory:
+		c = &_client.MemoryClient{}
 	}
 
 	c.SetConfig(cfg)
 
+	if cfg.AutoReconnect {
+		c = _client.NewReconnectClient(c)
+	}
+
 	return c, nil
 }
+
+// Event Produced on the channel returned by Client.Events()
+type Event = _client.Event
+
+// EventType Export the internal enum used to identify client events
+type EventType = _client.EventType
+
+const (
+	Connected       = _client.Connected
+	Disconnected    = _client.Disconnected
+	Reconnecting    = _client.Reconnecting
+	Reconnected     = _client.Reconnected
+	ReconnectFailed = _client.ReconnectFailed
+)
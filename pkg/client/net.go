@@ -0,0 +1,13 @@
+package client
+
+import (
+	"net"
+	"tonysoft.com/comm/internal/socket"
+)
+
+// NetConn Adapt an instance of Client to net.Conn so that it can be passed
+// wherever one is needed.  The client should be started before the adapter
+// is used; closing the adapter stops the client.
+func NetConn(c Client) net.Conn {
+	return socket.NewNetConn(c, c.Stop)
+}
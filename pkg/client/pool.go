@@ -0,0 +1,32 @@
+package client
+
+import (
+	"context"
+	_client "tonysoft.com/comm/internal/client"
+	"tonysoft.com/comm/internal/config"
+	_config "tonysoft.com/comm/internal/config/client"
+	_pool "tonysoft.com/comm/internal/config/pool"
+)
+
+// Pool Public interface for working with instances of Pool.  Clients
+// checked out via Get() are returned to the pool by calling Stop().
+// Thread-safe ✓
+type Pool interface {
+	config.Configurable[_pool.Config]
+	Get(_config.Config) (Client, error)
+	GetContext(context.Context, _config.Config) (Client, error)
+	IdleCount() int
+	ConnectionCount() int
+	Close() error
+}
+
+// NewPool Create a new instance of Pool
+func NewPool(cfg _pool.Config) Pool {
+	return _client.NewPool(cfg, func(clientCfg _config.Config) (_client.Client, error) {
+		return New(clientCfg)
+	})
+}
+
+func NewPoolConfig() _pool.Config {
+	return _pool.NewConfig()
+}
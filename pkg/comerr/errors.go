@@ -3,39 +3,89 @@ package comerr
 import "errors"
 
 const (
-	NotImplemented         = "function/feature not implemented"
-	SetReadTimeout         = "failed to set read timeout"
-	SetLingerTimeout       = "failed to set linger timeout"
-	SetNonBlockingMode     = "failed to set socket in non-blocking mode"
-	ConnectAborted         = "connection attempt aborted"
-	ConnectTimeout         = "could not connect within timeout period"
-	DisconnectTimeout      = "could not disconnect within timeout period"
-	ParseMacAddress        = "could not parse MAC address"
-	AddressEmpty           = "address is empty"
-	AddressFormatUnknown   = "address does not match a known format"
-	ClientAlreadyConnected = "client is already connected"
-	ServerAlreadyRunning   = "server is already running"
-	NodeAlreadyRunning     = "node is already running"
-	ConnectionLimitReached = "connection limit reached"
-	InvalidMessageFormat   = "message could not be instantiated from bytes"
-	InvalidMessagePayload  = "message payload is missing or corrupt"
+	NotImplemented           = "function/feature not implemented"
+	SetReadTimeout           = "failed to set read timeout"
+	SetLingerTimeout         = "failed to set linger timeout"
+	SetNonBlockingMode       = "failed to set socket in non-blocking mode"
+	ConnectAborted           = "connection attempt aborted"
+	ConnectTimeout           = "could not connect within timeout period"
+	DisconnectTimeout        = "could not disconnect within timeout period"
+	ParseMacAddress          = "could not parse MAC address"
+	AddressEmpty             = "address is empty"
+	AddressFormatUnknown     = "address does not match a known format"
+	ClientAlreadyConnected   = "client is already connected"
+	ServerAlreadyRunning     = "server is already running"
+	NodeAlreadyRunning       = "node is already running"
+	NodeStopping             = "node is shutting down"
+	ConnectionLimitReached   = "connection limit reached"
+	InvalidMessageFormat     = "message could not be instantiated from bytes"
+	InvalidMessagePayload    = "message payload is missing or corrupt"
+	ClientReconnecting       = "client is reconnecting"
+	ReconnectFailed          = "could not reconnect within attempt limit"
+	ReconnectBufferFull      = "reconnect write buffer is full"
+	ChannelOverflow          = "channel buffer is full"
+	ConnectionDenied         = "connection denied by access list"
+	HostConnectionLimit      = "per-host connection limit reached"
+	RateLimited              = "rate limit exceeded"
+	InvalidProxyHeader       = "invalid PROXY protocol header"
+	UntrustedProxy           = "connection is not from a trusted proxy"
+	ServerNotRunning         = "server is not running"
+	HandoffFailed            = "listener handoff failed"
+	SetSocketOption          = "failed to set socket option"
+	InterfaceAddress         = "network interface has no IPv4 address"
+	ResolveHostname          = "could not resolve hostname"
+	DialProxy                = "could not connect through proxy"
+	WebSocketHandshake       = "websocket handshake failed"
+	InvalidWebSocketFrame    = "invalid websocket frame"
+	TLSConfigRequired        = "a TLSConfig is required for wss:// addresses"
+	InjectedDisconnect       = "connection closed by fault injection"
+	InvalidBatchSize         = "batch and read buffer sizes must be at least 1"
+	TrustedProxiesRequired   = "TrustedProxies are required for ProxyProtocol"
+	NodeTransportUnsupported = "the Node API does not support this transport"
+	SocketNotPassed          = "no matching socket was passed via socket activation"
+	PreOpenedSocketType      = "pre-opened socket is not of the expected type"
 )
 
 var (
-	ErrNotImplemented         = errors.New(NotImplemented)
-	ErrSetReadTimeout         = errors.New(SetReadTimeout)
-	ErrSetLingerTimeout       = errors.New(SetLingerTimeout)
-	ErrSetNonBlockingMode     = errors.New(SetNonBlockingMode)
-	ErrConnectAborted         = errors.New(ConnectAborted)
-	ErrConnectTimeout         = errors.New(ConnectTimeout)
-	ErrDisconnectTimeout      = errors.New(DisconnectTimeout)
-	ErrParseMacAddress        = errors.New(ParseMacAddress)
-	ErrAddressEmpty           = errors.New(AddressEmpty)
-	ErrAddressFormatUnknown   = errors.New(AddressFormatUnknown)
-	ErrClientAlreadyConnected = errors.New(ClientAlreadyConnected)
-	ErrServerAlreadyRunning   = errors.New(ServerAlreadyRunning)
-	ErrNodeAlreadyRunning     = errors.New(NodeAlreadyRunning)
-	ErrConnectionLimitReached = errors.New(ConnectionLimitReached)
-	ErrInvalidMessageFormat   = errors.New(InvalidMessageFormat)
-	ErrInvalidMessagePayload  = errors.New(InvalidMessagePayload)
+	ErrNotImplemented           = errors.New(NotImplemented)
+	ErrSetReadTimeout           = errors.New(SetReadTimeout)
+	ErrSetLingerTimeout         = errors.New(SetLingerTimeout)
+	ErrSetNonBlockingMode       = errors.New(SetNonBlockingMode)
+	ErrConnectAborted           = errors.New(ConnectAborted)
+	ErrConnectTimeout           = errors.New(ConnectTimeout)
+	ErrDisconnectTimeout        = errors.New(DisconnectTimeout)
+	ErrParseMacAddress          = errors.New(ParseMacAddress)
+	ErrAddressEmpty             = errors.New(AddressEmpty)
+	ErrAddressFormatUnknown     = errors.New(AddressFormatUnknown)
+	ErrClientAlreadyConnected   = errors.New(ClientAlreadyConnected)
+	ErrServerAlreadyRunning     = errors.New(ServerAlreadyRunning)
+	ErrNodeAlreadyRunning       = errors.New(NodeAlreadyRunning)
+	ErrNodeStopping             = errors.New(NodeStopping)
+	ErrConnectionLimitReached   = errors.New(ConnectionLimitReached)
+	ErrInvalidMessageFormat     = errors.New(InvalidMessageFormat)
+	ErrInvalidMessagePayload    = errors.New(InvalidMessagePayload)
+	ErrClientReconnecting       = errors.New(ClientReconnecting)
+	ErrReconnectFailed          = errors.New(ReconnectFailed)
+	ErrReconnectBufferFull      = errors.New(ReconnectBufferFull)
+	ErrChannelOverflow          = errors.New(ChannelOverflow)
+	ErrConnectionDenied         = errors.New(ConnectionDenied)
+	ErrHostConnectionLimit      = errors.New(HostConnectionLimit)
+	ErrRateLimited              = errors.New(RateLimited)
+	ErrInvalidProxyHeader       = errors.New(InvalidProxyHeader)
+	ErrUntrustedProxy           = errors.New(UntrustedProxy)
+	ErrServerNotRunning         = errors.New(ServerNotRunning)
+	ErrHandoffFailed            = errors.New(HandoffFailed)
+	ErrSetSocketOption          = errors.New(SetSocketOption)
+	ErrInterfaceAddress         = errors.New(InterfaceAddress)
+	ErrResolveHostname          = errors.New(ResolveHostname)
+	ErrDialProxy                = errors.New(DialProxy)
+	ErrWebSocketHandshake       = errors.New(WebSocketHandshake)
+	ErrInvalidWebSocketFrame    = errors.New(InvalidWebSocketFrame)
+	ErrTLSConfigRequired        = errors.New(TLSConfigRequired)
+	ErrInjectedDisconnect       = errors.New(InjectedDisconnect)
+	ErrInvalidBatchSize         = errors.New(InvalidBatchSize)
+	ErrTrustedProxiesRequired   = errors.New(TrustedProxiesRequired)
+	ErrNodeTransportUnsupported = errors.New(NodeTransportUnsupported)
+	ErrSocketNotPassed          = errors.New(SocketNotPassed)
+	ErrPreOpenedSocketType      = errors.New(PreOpenedSocketType)
 )
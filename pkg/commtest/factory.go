@@ -0,0 +1,136 @@
+package commtest
+
+import (
+	"fmt"
+	"net"
+	"sync/atomic"
+	"tonysoft.com/comm/pkg/client"
+	"tonysoft.com/comm/pkg/server"
+)
+
+const (
+	udpSessionQueueSize = 1024 // datagram count, more than any subtest writes at once
+)
+
+var (
+	nextMemoryAddress atomic.Uint64
+)
+
+// Factory Creates the servers and clients of the transport under test.  The
+// suite prepares each config (e.g., ReadTimeoutUs, ClientConnectionLimit)
+// and the factory sets whatever addresses the transport, creating but not
+// starting the server or client.
+type Factory struct {
+	// NewServer Creates a server, to be started by the suite, from cfg
+	NewServer func(cfg server.Config) (server.Server, error)
+
+	// NewClient Creates a client, to be started by the suite, from cfg that
+	// connects to s, which is running
+	NewClient func(cfg client.Config, s server.Server) (client.Client, error)
+
+	// Connectionless Set for transports without connections (UDP): the
+	// server learns of a client from its first datagram, which the suite
+	// sends when connecting, and as neither end can tell that the other
+	// closed, stopped or refused it the subtests checking so are skipped
+	Connectionless bool
+}
+
+// TCP Tests the TCP transport on the loopback interface, listening on a
+// port chosen by the system
+func TCP() Factory {
+	return Factory{
+		NewServer: func(cfg server.Config) (server.Server, error) {
+			cfg.Address = "127.0.0.1"
+			cfg.Port = 0
+			return server.New(cfg)
+		},
+		NewClient: func(cfg client.Config, s server.Server) (client.Client, error) {
+			port, err := listenPort(s)
+			if err != nil {
+				return nil, err
+			}
+			cfg.RemoteAddress = "127.0.0.1"
+			cfg.RemotePort = port
+			return client.New(cfg)
+		},
+	}
+}
+
+// WebSocket Tests the WebSocket transport on the loopback interface,
+// listening on a port chosen by the system
+func WebSocket() Factory {
+	return Factory{
+		NewServer: func(cfg server.Config) (server.Server, error) {
+			cfg.Address = "ws://127.0.0.1:0/commtest"
+			cfg.Port = 0
+			return server.New(cfg)
+		},
+		NewClient: func(cfg client.Config, s server.Server) (client.Client, error) {
+			port, err := listenPort(s)
+			if err != nil {
+				return nil, err
+			}
+			cfg.RemoteAddress = fmt.Sprintf("ws://127.0.0.1:%d/commtest", port)
+			cfg.RemotePort = 0
+			return client.New(cfg)
+		},
+	}
+}
+
+// Memory Tests the in-memory transport, each server listening on an
+// address of its own
+func Memory() Factory {
+	return Factory{
+		NewServer: func(cfg server.Config) (server.Server, error) {
+			cfg.Address = fmt.Sprintf("mem://commtest-%d", nextMemoryAddress.Add(1))
+			cfg.Port = 0
+			return server.New(cfg)
+		},
+		NewClient: func(cfg client.Config, s server.Server) (client.Client, error) {
+			cfg.RemoteAddress = s.Config().Address
+			cfg.RemotePort = 0
+			return client.New(cfg)
+		},
+	}
+}
+
+// UDP Tests the UDP transport on the loopback interface, listening on a
+// port chosen by the system.  The server groups datagrams into sessions (see
+// UdpSessions), so that each client is a single connection, queuing enough
+// of them that the suite's bursts are not dropped.
+func UDP() Factory {
+	return Factory{
+		NewServer: func(cfg server.Config) (server.Server, error) {
+			cfg.Address = "127.0.0.1"
+			cfg.Port = 0
+			cfg.Connectionless = true
+			cfg.UdpSessions = true
+			cfg.UdpSessionQueueSize = udpSessionQueueSize
+			return server.New(cfg)
+		},
+		NewClient: func(cfg client.Config, s server.Server) (client.Client, error) {
+			port, err := listenPort(s)
+			if err != nil {
+				return nil, err
+			}
+			cfg.RemoteAddress = "127.0.0.1"
+			cfg.RemotePort = port
+			cfg.Connectionless = true
+			return client.New(cfg)
+		},
+		Connectionless: true,
+	}
+}
+
+// listenPort Returns the port a running TCP (or WebSocket) or UDP server is
+// bound to
+func listenPort(s server.Server) (uint16, error) {
+	switch addr := s.Addr().(type) {
+	case *net.TCPAddr:
+		return uint16(addr.Port), nil
+	case *net.UDPAddr:
+		return uint16(addr.Port), nil
+	default:
+		return 0, fmt.Errorf("expected a TCP or UDP address, have %v", s.Addr())
+	}
+}
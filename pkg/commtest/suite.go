@@ -0,0 +1,448 @@
+// Package commtest Checks that a transport behaves the way the built-in ones
+// do, so that applications can switch between them (or a third party one)
+// without surprises, e.g.:
+//
+//	func TestMyTransport(t *testing.T) {
+//		commtest.RunClientServerSuite(t, commtest.Factory{NewServer: ..., NewClient: ...})
+//	}
+package commtest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+	"tonysoft.com/comm/pkg/client"
+	"tonysoft.com/comm/pkg/comerr"
+	"tonysoft.com/comm/pkg/server"
+)
+
+const (
+	readTimeoutUs = 50000 // short, so that timed out reads are quick
+	waitTimeout   = 5 * time.Second
+)
+
+var (
+	connectDatagram = []byte("connect") // sent by connectionless clients to be accepted
+)
+
+// RunClientServerSuite Runs the conformance tests against the transport
+// created by f, each as a subtest with a server and clients of its own:
+//
+//   - data written either way is read back unaltered, also while reading and writing concurrently
+//   - a read that times out returns (0, nil) and leaves the connection usable
+//   - a read is aborted when its context is done, returning the context's error
+//   - a closed connection fails reads at the other end, after which the client
+//     is disconnected and its reads and writes return (-1, net.ErrClosed)
+//   - connections beyond ClientConnectionLimit are refused, producing
+//     ErrConnectionLimitReached on the server's Errors()
+//   - starting twice fails, and a stopped server closes Accept() and refuses clients
+//
+// The checks that one end notices the other closing, stopping or refusing
+// it are skipped for Connectionless factories.
+func RunClientServerSuite(t *testing.T, f Factory) {
+	t.Run("Echo", func(t *testing.T) { testEcho(t, f) })
+	t.Run("ReadTimeout", func(t *testing.T) { testReadTimeout(t, f) })
+	t.Run("ReadContext", func(t *testing.T) { testReadContext(t, f) })
+	t.Run("ServerClose", func(t *testing.T) { testServerClose(t, f) })
+	t.Run("ClientStop", func(t *testing.T) { testClientStop(t, f) })
+	t.Run("ConcurrentReadWrite", func(t *testing.T) { testConcurrentReadWrite(t, f) })
+	t.Run("ConnectionLimit", func(t *testing.T) { testConnectionLimit(t, f) })
+	t.Run("StartStop", func(t *testing.T) { testStartStop(t, f) })
+}
+
+func testEcho(t *testing.T, f Factory) {
+	s := startServer(t, f, nil)
+	c, conn := connect(t, f, s, nil)
+
+	write(t, c, []byte("hello"))
+	expectRead(t, conn, []byte("hello"))
+
+	write(t, conn, []byte("hello back"))
+	expectRead(t, c, []byte("hello back"))
+}
+
+func testReadTimeout(t *testing.T, f Factory) {
+	s := startServer(t, f, nil)
+	c, conn := connect(t, f, s, nil)
+
+	buffer := make([]byte, 16)
+	for name, r := range map[string]reader{"client": c, "server connection": conn} {
+		start := time.Now()
+		count, err := r.Read(buffer)
+		if count != 0 || err != nil {
+			t.Errorf("expected the %s read to time out with (0, nil), have (%d, %v)", name, count, err)
+		}
+		if elapsed := time.Since(start); elapsed > waitTimeout {
+			t.Errorf("expected the %s read to time out after ReadTimeoutUs, took %v", name, elapsed)
+		}
+	}
+
+	if !c.IsConnected() || !conn.IsConnected() {
+		t.Fatal("expected a timed out read to leave the connection open")
+	}
+
+	write(t, c, []byte("still open"))
+	expectRead(t, conn, []byte("still open"))
+}
+
+func testReadContext(t *testing.T, f Factory) {
+	longTimeout := func(cfg *client.Config) { cfg.ReadTimeoutUs = int(waitTimeout / time.Microsecond) }
+	s := startServer(t, f, func(cfg *server.Config) { cfg.ReadTimeoutUs = int(waitTimeout / time.Microsecond) })
+	c, conn := connect(t, f, s, longTimeout)
+
+	for name, r := range map[string]reader{"client": c, "server connection": conn} {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		start := time.Now()
+		_, err := r.ReadContext(ctx, make([]byte, 16))
+		cancel()
+
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected the %s read to fail with the context's error, have %v", name, err)
+		}
+		if elapsed := time.Since(start); elapsed >= waitTimeout {
+			t.Errorf("expected the %s read to be aborted before ReadTimeoutUs, took %v", name, elapsed)
+		}
+	}
+
+	_, err := c.ReadContext(canceledContext(), make([]byte, 16))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a read with a canceled context to fail with context.Canceled, have %v", err)
+	}
+}
+
+func testServerClose(t *testing.T, f Factory) {
+	skipConnectionless(t, f, "the client cannot tell that the server closed its connection")
+
+	s := startServer(t, f, nil)
+	c, conn := connect(t, f, s, nil)
+
+	err := conn.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	readUntilError(t, "client", c)
+
+	if c.IsConnected() {
+		t.Error("expected the client to be disconnected once its read failed")
+	}
+
+	count, err := c.Read(make([]byte, 16))
+	if count != -1 || !errors.Is(err, net.ErrClosed) {
+		t.Errorf("expected the client read to return (-1, net.ErrClosed), have (%d, %v)", count, err)
+	}
+
+	count, err = c.Write([]byte("closed"))
+	if count != -1 || !errors.Is(err, net.ErrClosed) {
+		t.Errorf("expected the client write to return (-1, net.ErrClosed), have (%d, %v)", count, err)
+	}
+}
+
+func testClientStop(t *testing.T, f Factory) {
+	skipConnectionless(t, f, "the server cannot tell that the client stopped")
+
+	s := startServer(t, f, nil)
+	c, conn := connect(t, f, s, nil)
+
+	err := c.Stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = c.Stop(); err != nil {
+		t.Errorf("expected stopping a stopped client to succeed, have %v", err)
+	}
+
+	if c.IsConnected() {
+		t.Error("expected the client to be disconnected once stopped")
+	}
+
+	readUntilError(t, "server connection", conn)
+
+	deadline := time.Now().Add(waitTimeout)
+	for s.ClientCount() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if count := s.ClientCount(); count != 0 {
+		t.Errorf("expected the server to have no clients, have %d", count)
+	}
+
+	count, err := c.Write([]byte("stopped"))
+	if count != -1 || !errors.Is(err, net.ErrClosed) {
+		t.Errorf("expected the client write to return (-1, net.ErrClosed), have (%d, %v)", count, err)
+	}
+}
+
+func testConcurrentReadWrite(t *testing.T, f Factory) {
+	const messageCount = 200
+
+	s := startServer(t, f, nil)
+	c, conn := connect(t, f, s, nil)
+
+	go echo(conn)
+
+	expected := make([]byte, 0, messageCount*64)
+	for i := 0; i < messageCount; i++ {
+		expected = append(expected, bytes.Repeat([]byte{byte('a' + i%26)}, 64)...)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < messageCount; i++ {
+			if _, err := c.Write(expected[i*64 : (i+1)*64]); err != nil {
+				t.Errorf("write %d failed: %v", i, err)
+				return
+			}
+		}
+	}()
+
+	expectRead(t, c, expected)
+	wg.Wait()
+}
+
+func testConnectionLimit(t *testing.T, f Factory) {
+	skipConnectionless(t, f, "the client cannot tell that the server refused it")
+
+	s := startServer(t, f, func(cfg *server.Config) { cfg.ClientConnectionLimit = 1 })
+	connect(t, f, s, nil)
+
+	c, err := f.NewClient(clientConfig(nil), s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = c.Stop()
+	}()
+
+	// Depending on the transport the refusal fails Start or the first read
+	if err = c.Start(); err == nil {
+		readUntilError(t, "refused client", c)
+	}
+
+	select {
+	case err = <-s.Errors():
+		if !errors.Is(err, comerr.ErrConnectionLimitReached) {
+			t.Errorf("expected ErrConnectionLimitReached, have %v", err)
+		}
+	case <-time.After(waitTimeout):
+		t.Error("expected ErrConnectionLimitReached on the server's Errors()")
+	}
+
+	if count := s.ClientCount(); count != 1 {
+		t.Errorf("expected the server to have 1 client, have %d", count)
+	}
+}
+
+func testStartStop(t *testing.T, f Factory) {
+	s := startServer(t, f, nil)
+	c, _ := connect(t, f, s, nil)
+
+	if err := s.Start(); !errors.Is(err, comerr.ErrServerAlreadyRunning) {
+		t.Errorf("expected ErrServerAlreadyRunning, have %v", err)
+	}
+
+	if err := c.Start(); !errors.Is(err, comerr.ErrClientAlreadyConnected) {
+		t.Errorf("expected ErrClientAlreadyConnected, have %v", err)
+	}
+
+	// Created while the server is running, so that it knows where to connect
+	late, err := f.NewClient(clientConfig(nil), s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accept := s.Accept()
+	stopAndWait(s)
+
+	select {
+	case _, ok := <-accept:
+		if ok {
+			t.Error("expected no connection to be accepted once stopped")
+		}
+	case <-time.After(waitTimeout):
+		t.Error("expected Accept() to be closed once stopped")
+	}
+
+	// Without a connection to make, the client cannot tell that the server stopped
+	if f.Connectionless {
+		return
+	}
+
+	if err = late.Start(); err == nil {
+		_ = late.Stop()
+		t.Error("expected a client to fail connecting to a stopped server")
+	}
+}
+
+// reader Implemented by both clients and the connections accepted by servers
+type reader interface {
+	Read([]byte) (int, error)
+	ReadContext(context.Context, []byte) (int, error)
+}
+
+func serverConfig(configure func(*server.Config)) server.Config {
+	cfg := server.NewConfig("", 0)
+	cfg.ReadTimeoutUs = readTimeoutUs
+	if configure != nil {
+		configure(&cfg)
+	}
+	return cfg
+}
+
+func clientConfig(configure func(*client.Config)) client.Config {
+	cfg := client.NewConfig("", 0)
+	cfg.ReadTimeoutUs = readTimeoutUs
+	cfg.ConnectTimeoutSec = int(waitTimeout / time.Second)
+	if configure != nil {
+		configure(&cfg)
+	}
+	return cfg
+}
+
+// startServer Starts a server, stopped when the test is done
+func startServer(t *testing.T, f Factory, configure func(*server.Config)) server.Server {
+	t.Helper()
+
+	s, err := f.NewServer(serverConfig(configure))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		stopAndWait(s)
+	})
+
+	return s
+}
+
+// connect Starts a client, stopped when the test is done, returning it along
+// with the connection accepted by s
+func connect(t *testing.T, f Factory, s server.Server, configure func(*client.Config)) (client.Client, server.Connection) {
+	t.Helper()
+
+	c, err := f.NewClient(clientConfig(configure), s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = c.Stop()
+	})
+
+	// The server learns of a connectionless client from its first datagram
+	if f.Connectionless {
+		write(t, c, connectDatagram)
+	}
+
+	select {
+	case conn := <-s.Accept():
+		if f.Connectionless {
+			expectRead(t, conn, connectDatagram)
+		}
+		return c, conn
+	case <-time.After(waitTimeout):
+		t.Fatal("expected the server to accept the client")
+		return nil, nil
+	}
+}
+
+// skipConnectionless Skips the test for Connectionless factories, giving why
+func skipConnectionless(t *testing.T, f Factory, reason string) {
+	t.Helper()
+
+	if f.Connectionless {
+		t.Skipf("connectionless: %s", reason)
+	}
+}
+
+func stopAndWait(s server.Server) {
+	s.Stop()
+	deadline := time.Now().Add(waitTimeout)
+	for s.IsRunning() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func canceledContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
+
+func write(t *testing.T, w interface{ Write([]byte) (int, error) }, data []byte) {
+	t.Helper()
+
+	count, err := w.Write(data)
+	if err != nil || count != len(data) {
+		t.Fatalf("expected to write %d bytes, have (%d, %v)", len(data), count, err)
+	}
+}
+
+// expectRead Reads until len(expected) bytes arrive, timed out reads
+// carrying on until waitTimeout
+func expectRead(t *testing.T, r reader, expected []byte) {
+	t.Helper()
+
+	received := make([]byte, 0, len(expected))
+	buffer := make([]byte, 1500)
+	deadline := time.Now().Add(waitTimeout)
+
+	for len(received) < len(expected) && time.Now().Before(deadline) {
+		count, err := r.Read(buffer)
+		if err != nil {
+			t.Fatalf("read failed after %d of %d bytes: %v", len(received), len(expected), err)
+		}
+		if count > 0 {
+			received = append(received, buffer[:count]...)
+		}
+	}
+
+	if !bytes.Equal(received, expected) {
+		t.Fatalf("expected %d bytes '%.32s', have %d bytes '%.32s'", len(expected), expected, len(received), received)
+	}
+}
+
+// readUntilError Reads, discarding timed out reads, until one fails
+func readUntilError(t *testing.T, name string, r reader) {
+	t.Helper()
+
+	buffer := make([]byte, 1500)
+	deadline := time.Now().Add(waitTimeout)
+
+	for time.Now().Before(deadline) {
+		if _, err := r.Read(buffer); err != nil {
+			return
+		}
+	}
+	t.Errorf("expected the %s read to fail once the other end closed", name)
+}
+
+// echo Writes back what conn reads until it fails
+func echo(conn server.Connection) {
+	buffer := make([]byte, 1500)
+	for {
+		count, err := conn.Read(buffer)
+		if err != nil {
+			return
+		}
+		if count > 0 {
+			if _, err = conn.Write(buffer[:count]); err != nil {
+				return
+			}
+		}
+	}
+}
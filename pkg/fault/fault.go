@@ -0,0 +1,32 @@
+package fault
+
+import (
+	"net"
+	_fault "tonysoft.com/comm/internal/fault"
+	"tonysoft.com/comm/pkg/client"
+	"tonysoft.com/comm/pkg/server"
+)
+
+// Config The faults to inject (latency, jitter, bandwidth caps, corruption,
+// truncated, dropped and reordered writes, and disconnects), chosen by a
+// random source seeded with Seed so that failures are reproducible
+type Config = _fault.Config
+
+// Client Wrap c so that faults are injected into its reads and writes.  If
+// c reconnects automatically (see AutoReconnect), faults are injected beneath
+// it so that injected disconnects trigger reconnection.  c must not be started yet.
+func Client(c client.Client, cfg Config) client.Client {
+	return _fault.NewClient(c, cfg)
+}
+
+// Connection Wrap a connection accepted by a server so that faults are
+// injected into its reads and writes
+func Connection(conn server.Connection, cfg Config) server.Connection {
+	return _fault.NewConnection(conn, cfg)
+}
+
+// Conn Wrap any net.Conn (e.g., see client.NetConn) so that faults are
+// injected into its reads and writes
+func Conn(conn net.Conn, cfg Config) net.Conn {
+	return _fault.NewConn(conn, cfg)
+}
@@ -0,0 +1,66 @@
+package handoff
+
+import (
+	"context"
+	"fmt"
+	"os"
+	_handoff "tonysoft.com/comm/internal/handoff"
+	"tonysoft.com/comm/pkg/comerr"
+	"tonysoft.com/comm/pkg/server"
+)
+
+// Handoff The listening sockets handed off by the previous process, use
+// File to configure each server's ListenerFile, then call Ready once the
+// servers are started so that the previous process shuts down, and Close
+// once the servers will no longer be restarted
+type Handoff = _handoff.Handoff
+
+// EnvPath The environment variable through which Restart passes the path
+// of the handoff socket to the new process
+const EnvPath = _handoff.EnvPath
+
+// Inherited Receives the listening sockets handed off by Restart in the
+// previous process, nil if this process was not started by Restart
+func Inherited() (*Handoff, error) {
+	return _handoff.Inherited()
+}
+
+// Receive Receives the listening sockets offered on the Unix socket at path
+// by a running process (see Offer)
+func Receive(path string) (*Handoff, error) {
+	return _handoff.Receive(path)
+}
+
+// Offer Hands the listening sockets of the TCP and UDP servers (keyed by
+// name, see Handoff.File) to the first process to connect to a Unix socket
+// at path, then gracefully shuts the servers down once it is Ready
+func Offer(ctx context.Context, path string, servers map[string]server.Server) error {
+	sources, err := toSources(servers)
+	if err != nil {
+		return err
+	}
+	return _handoff.Offer(ctx, path, sources)
+}
+
+// Restart Starts a new instance of the executable with the same arguments
+// and hands it the listening sockets of the TCP and UDP servers (see
+// Offer), killing it if the handoff fails or ctx is done first
+func Restart(ctx context.Context, path string, servers map[string]server.Server) (*os.Process, error) {
+	sources, err := toSources(servers)
+	if err != nil {
+		return nil, err
+	}
+	return _handoff.Restart(ctx, path, sources)
+}
+
+func toSources(servers map[string]server.Server) (map[string]_handoff.Source, error) {
+	sources := make(map[string]_handoff.Source, len(servers))
+	for name, s := range servers {
+		source, ok := s.(_handoff.Source)
+		if !ok {
+			return nil, fmt.Errorf("%w : %s", comerr.ErrNotImplemented, name)
+		}
+		sources[name] = source
+	}
+	return sources, nil
+}
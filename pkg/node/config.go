@@ -1,6 +1,12 @@
 package node
 
-import _config "tonysoft.com/comm/internal/config/node"
+import (
+	_config "tonysoft.com/comm/internal/config/node"
+	"tonysoft.com/comm/internal/socket"
+)
+
+// SocketOptions See socket.Options
+type SocketOptions = socket.Options
 
 func NewConfig(nodeAddress string) _config.Config {
 	return _config.NewConfig(nodeAddress)
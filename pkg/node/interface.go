@@ -1,12 +1,16 @@
 package node
 
 import (
+	"context"
+	"fmt"
 	"tonysoft.com/comm/internal/comerr"
 	"tonysoft.com/comm/internal/comobj"
 	"tonysoft.com/comm/internal/config"
 	_config "tonysoft.com/comm/internal/config/node"
 	_node "tonysoft.com/comm/internal/node"
+	"tonysoft.com/comm/internal/ratelimit"
 	"tonysoft.com/comm/internal/transport"
+	_comerr "tonysoft.com/comm/pkg/comerr"
 )
 
 // Node Public interface for working with instances of Node[T]
@@ -15,18 +19,22 @@ type Node[T any] interface {
 	config.Configurable[_config.Config]
 	Start() error
 	Stop()
+	Shutdown(context.Context) error
 	comobj.Runnable
 	ConnectionCount() int
 	ConnectedNodes() []string
 	Send(string, *T) (*_node.Message[T], error)
 	Recv() <-chan *_node.Message[T]
 	Status() <-chan *_node.Message[T]
+	DroppedMessages() uint64
+	DroppedReceipts() uint64
+	SetPeerSendLimit(string, ratelimit.Limit)
 	comerr.Producer
 }
 
 // New Create a new instance of Node[T]
 func New[T any](cfg _config.Config) (Node[T], error) {
-	transportType, err := transport.GetTypeFromAddress(cfg.Address)
+	transportType, err := transport.GetTypeFromHostnameOrAddress(cfg.Address, cfg.ResolveHostnames)
 	if err != nil {
 		return nil, err
 	}
@@ -36,8 +44,11 @@ func New[T any](cfg _config.Config) (Node[T], error) {
 	switch transportType {
 	case transport.TCP:
 		n = &_node.TcpNode[T]{}
-	case transport.RFCOMM:
-		panic("the Node API does not support RFCOMM")
+	case transport.UDP, transport.RFCOMM:
+		// The Node API runs over TCP, WebSocket and in-memory connections only
+		return nil, fmt.Errorf("%w : %s", _comerr.ErrNodeTransportUnsupported, cfg.Address)
+	case transport.WebSocket, transport.Memory:
+		n = &_node.TcpNode[T]{}
 	}
 
 	n.SetConfig(cfg)
@@ -0,0 +1,16 @@
+package ratelimit
+
+import _ratelimit "tonysoft.com/comm/internal/ratelimit"
+
+// Limit Token bucket limits on the bytes and messages per second applied
+// to server connections (see ConnectionReadLimit, ServerWriteLimit, etc)
+// and node peers (see PeerSendLimit), where 0 means no limit.
+type Limit = _ratelimit.Limit
+
+// Mode Determines what happens to an operation that exceeds a Limit (see the constants below)
+type Mode = _ratelimit.Mode
+
+const (
+	Block  = _ratelimit.Block
+	Reject = _ratelimit.Reject
+)
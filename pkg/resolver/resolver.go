@@ -0,0 +1,26 @@
+package resolver
+
+import (
+	"time"
+	"tonysoft.com/comm/internal/transport"
+)
+
+// Resolver Looks up the IP addresses of hostnames for clients, servers and
+// nodes whose ResolveHostnames is set (see their Resolver)
+type Resolver = transport.Resolver
+
+// Static Looks up hostnames in a fixed table, e.g., for tests
+type Static = transport.StaticResolver
+
+// System Looks up hostnames using the system's configuration (DNS,
+// /etc/hosts, etc.)
+type System = transport.SystemResolver
+
+// Caching Caches the addresses looked up by another Resolver until their
+// TTL elapses, or until a client fails to connect to any of them
+type Caching = transport.CachingResolver
+
+// NewCaching Create a Resolver caching the addresses looked up by resolver for ttl
+func NewCaching(resolver Resolver, ttl time.Duration) *Caching {
+	return transport.NewCachingResolver(resolver, ttl)
+}
@@ -0,0 +1,16 @@
+package server
+
+import "tonysoft.com/comm/internal/socket"
+
+// Datagram A UDP datagram and the address it is sent to
+type Datagram = socket.Datagram
+
+// BatchWriter Implemented by UDP servers, use a type assertion to check
+// whether a Server supports writing datagrams in batches:
+//
+//	if bw, ok := s.(server.BatchWriter); ok {
+//		bw.WriteBatch(datagrams)
+//	}
+type BatchWriter interface {
+	WriteBatch([]Datagram) (int, error)
+}
@@ -1,6 +1,15 @@
 package server
 
-import _config "tonysoft.com/comm/internal/config/server"
+import (
+	_config "tonysoft.com/comm/internal/config/server"
+	"tonysoft.com/comm/internal/socket"
+)
+
+// Config The configuration of a Server, see NewConfig
+type Config = _config.Config
+
+// SocketOptions See socket.Options
+type SocketOptions = socket.Options
 
 func NewConfig(address string, port uint16, connectionless ...bool) _config.Config {
 	useUdp := false
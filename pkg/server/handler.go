@@ -0,0 +1,48 @@
+package server
+
+import (
+	"context"
+	"log"
+	_server "tonysoft.com/comm/internal/server"
+)
+
+// Handler Serves a single connection accepted by Server.Serve().  The
+// connection is closed once ServeConn returns and the context is cancelled
+// when the server stops.
+type Handler = _server.Handler
+
+// HandlerFunc Allows ordinary functions to be used as instances of Handler
+type HandlerFunc = _server.HandlerFunc
+
+// Middleware Wraps a Handler to add behavior before and/or after it serves
+// the connection (logging, authentication, etc).
+type Middleware = _server.Middleware
+
+// Metrics Counters updated by the middleware returned from Metrics.Middleware()
+type Metrics = _server.Metrics
+
+// Chain Wrap the handler with the given middleware, with the first
+// middleware being the outermost (i.e., the first to see the connection).
+func Chain(handler Handler, middleware ...Middleware) Handler {
+	return _server.Chain(handler, middleware...)
+}
+
+// Logging Log when each connection is opened and closed (nil uses the standard logger)
+func Logging(logger *log.Logger) Middleware {
+	return _server.Logging(logger)
+}
+
+// Recover Recover from panics raised by the handler, passing the value to onPanic
+func Recover(onPanic func(Connection, any)) Middleware {
+	return _server.Recover(onPanic)
+}
+
+// RateLimit Limit how many new connections are served per second, closing the rest
+func RateLimit(connectionsPerSec float64, burst int) Middleware {
+	return _server.RateLimit(connectionsPerSec, burst)
+}
+
+// Auth Serve only the connections for which authenticate returns nil
+func Auth(authenticate func(context.Context, Connection) error) Middleware {
+	return _server.Auth(authenticate)
+}
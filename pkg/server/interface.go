@@ -1,7 +1,8 @@
 package server
 
 import (
-	"io"
+	"context"
+	"net"
 	"tonysoft.com/comm/internal/comerr"
 	"tonysoft.com/comm/internal/comobj"
 	"tonysoft.com/comm/internal/config"
@@ -17,16 +18,21 @@ type Server interface {
 	config.Configurable[_config.Config]
 	Start() error
 	Stop()
+	Shutdown(context.Context) error
 	comobj.Runnable
+	Addr() net.Addr
 	ClientCount() int
 	CloseClient(socket.ConnectionID) error
 	Accept() <-chan socket.Connection
+	DroppedConnections() uint64
+	DroppedDatagrams() uint64
+	Serve(Handler) error
 	comerr.Producer
 }
 
 // New Create a new instance of Server
 func New(cfg _config.Config) (Server, error) {
-	transportType, err := transport.GetTypeFromAddress(cfg.Address)
+	transportType, err := transport.GetTypeFromHostnameOrAddress(cfg.Address, cfg.ResolveHostnames)
 	if err != nil {
 		return nil, err
 	}
@@ -40,8 +46,14 @@ func New(cfg _config.Config) (Server, error) {
 		} else {
 			s = &_server.TcpServer{}
 		}
+	case transport.UDP:
+		s = &_server.UdpServer{}
 	case transport.RFCOMM:
 		s = &_server.RfcommServer{}
+	case transport.WebSocket:
+		s = &_server.TcpServer{}
+	case transport.Memory:
+		s = &_server.MemoryServer{}
 	}
 
 	s.SetConfig(cfg)
@@ -49,12 +61,7 @@ func New(cfg _config.Config) (Server, error) {
 	return s, nil
 }
 
-type Connection interface {
-	ID() socket.ConnectionID
-	RemoteAddress() string
-	comobj.Connectable
-	comobj.Idleable
-	io.Reader
-	io.Writer
-	io.Closer
-}
+// Connection Public interface for working with the client connections
+// accepted by instances of Server
+// Thread-safe ✓
+type Connection = socket.Connection
@@ -0,0 +1,21 @@
+package server
+
+import (
+	"net"
+	"tonysoft.com/comm/internal/socket"
+)
+
+// NetListener Adapt an instance of Server to net.Listener so that it can back
+// http.Serve(), grpc.Server.Serve(), etc.  The server should be started before
+// the listener is used; closing the listener stops the server.
+func NetListener(s Server) net.Listener {
+	return socket.NewNetListener(s.Accept, s.Addr, func() error {
+		s.Stop()
+		return nil
+	})
+}
+
+// NetConn Adapt a client connection accepted by Server to net.Conn
+func NetConn(conn Connection) net.Conn {
+	return socket.NewNetConn(conn, conn.Close)
+}
@@ -0,0 +1,20 @@
+package server
+
+import "tonysoft.com/comm/internal/proxyproto"
+
+// ProxyHeader A PROXY protocol header received from a trusted proxy (see
+// ProxyProtocol), conveying the real client address and any TLVs
+type ProxyHeader = proxyproto.Header
+
+// ProxyTLV A type-length-value vector from a version 2 PROXY protocol header
+type ProxyTLV = proxyproto.TLV
+
+// Proxied Implemented by the connections accepted by TCP servers, use a
+// type assertion to get the PROXY protocol header of a connection:
+//
+//	if p, ok := conn.(server.Proxied); ok && p.ProxyHeader() != nil {
+//		authority, _ := p.ProxyHeader().TLV(0x02)
+//	}
+type Proxied interface {
+	ProxyHeader() *ProxyHeader
+}
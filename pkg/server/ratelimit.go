@@ -0,0 +1,15 @@
+package server
+
+import "tonysoft.com/comm/internal/ratelimit"
+
+// RateLimiter Implemented by the connections accepted by every Server, use
+// a type assertion to override ConnectionReadLimit/ConnectionWriteLimit for
+// a single connection:
+//
+//	if rl, ok := conn.(server.RateLimiter); ok {
+//		rl.SetWriteLimit(ratelimit.Limit{BytesPerSec: 1 << 20})
+//	}
+type RateLimiter interface {
+	SetReadLimit(ratelimit.Limit)
+	SetWriteLimit(ratelimit.Limit)
+}
@@ -0,0 +1,18 @@
+package stream
+
+import _stream "tonysoft.com/comm/internal/stream"
+
+// OverflowPolicy Determines what happens to a value sent on a channel whose
+// buffer is full (see the constants below).
+type OverflowPolicy = _stream.OverflowPolicy
+
+const (
+	DropNewest      = _stream.DropNewest
+	DropOldest      = _stream.DropOldest
+	Block           = _stream.Block
+	CloseConnection = _stream.CloseConnection
+)
+
+// DropCounter Implemented by streams that count what they drop, e.g.
+// StringStream, for callers to type-assert.
+type DropCounter = _stream.DropCounter
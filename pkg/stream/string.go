@@ -4,6 +4,8 @@ import (
 	"io"
 	"strings"
 	"sync/atomic"
+	_stream "tonysoft.com/comm/internal/stream"
+	"tonysoft.com/comm/pkg/comerr"
 )
 
 const (
@@ -12,12 +14,18 @@ const (
 )
 
 // StringStream Produces instances of string from a byte stream containing
-// C strings (null-terminated/delimited strings).
+// C strings (null-terminated/delimited strings).  OverflowPolicy determines
+// what happens to strings produced while the channel's buffer is full, with
+// CloseConnection ending the stream with ErrChannelOverflow (and closing the
+// reader if it implements io.Closer).
 type StringStream struct {
+	OverflowPolicy OverflowPolicy
+
 	reader      io.Reader
 	streamChan  chan string
 	processChan chan bool
 	err         atomic.Value
+	dropped     atomic.Uint64
 }
 
 func (s *StringStream) Stream(reader io.Reader, bufferSize ...int) <-chan string {
@@ -43,6 +51,10 @@ func (s *StringStream) Error() error {
 	return nil
 }
 
+func (s *StringStream) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
 func (s *StringStream) Close() error {
 	if s.processChan != nil {
 		select {
@@ -57,6 +69,7 @@ func (s *StringStream) Close() error {
 
 func (s *StringStream) process() {
 	s.processChan = make(chan bool)
+	processChan := s.processChan
 	buffer := make([]byte, readBufferSize)
 	sb := strings.Builder{}
 
@@ -86,10 +99,8 @@ func (s *StringStream) process() {
 				sb.WriteByte(buffer[i])
 			} else {
 				if sb.Len() > 0 {
-					select {
-					case s.streamChan <- sb.String():
-						break
-					default:
+					if !s.send(sb.String(), processChan) {
+						return
 					}
 					sb.Reset()
 				}
@@ -99,6 +110,26 @@ func (s *StringStream) process() {
 	}
 }
 
+// send Returns false if the stream was closed per OverflowPolicy
+func (s *StringStream) send(str string, done <-chan bool) bool {
+	countDrop := func(string) {
+		s.dropped.Add(1)
+	}
+
+	if _stream.Send(s.streamChan, str, s.OverflowPolicy, done, countDrop) ||
+		s.OverflowPolicy != CloseConnection {
+		return true
+	}
+
+	s.err.Store(comerr.ErrChannelOverflow)
+	if closer, ok := s.reader.(io.Closer); ok {
+		_ = closer.Close()
+	}
+
+	close(s.streamChan)
+	return false
+}
+
 func String(reader io.Reader, bufferSize ...int) <-chan string {
 	s := &StringStream{}
 	return s.Stream(reader, bufferSize...)
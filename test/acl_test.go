@@ -0,0 +1,149 @@
+package test
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+	"tonysoft.com/comm/pkg/client"
+	"tonysoft.com/comm/pkg/comerr"
+	"tonysoft.com/comm/pkg/node"
+	"tonysoft.com/comm/pkg/server"
+)
+
+func TestServerAccessControl(t *testing.T) {
+	tests := []struct {
+		name            string
+		hostLimit       int
+		allow           []string
+		deny            []string
+		clientCount     int
+		expectedClients int
+		expectedErr     error
+	}{
+		{"HostConnectionLimit", 2, nil, nil, 3, 2, comerr.ErrHostConnectionLimit},
+		{"DenyAddress", 0, nil, []string{"127.0.0.1"}, 1, 0, comerr.ErrConnectionDenied},
+		{"DenyTakesPrecedence", 0, []string{"127.0.0.0/8"}, []string{"127.0.0.0/24"}, 1, 0, comerr.ErrConnectionDenied},
+		{"NotAllowed", 0, []string{"10.0.0.0/8", "AA:BB:CC"}, nil, 1, 0, comerr.ErrConnectionDenied},
+		{"Allowed", 0, []string{"10.0.0.0/8", "127.0.0.0/8"}, []string{"192.168.0.0/16"}, 2, 2, nil},
+	}
+
+	for _, test := range tests {
+		func() {
+			serverCfg := server.NewConfig(net.IPv4zero.String(), 8392)
+			serverCfg.HostConnectionLimit = test.hostLimit
+			serverCfg.AllowList = test.allow
+			serverCfg.DenyList = test.deny
+			s, err := server.New(serverCfg)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			err = s.Start()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer func() {
+				// Wait for the server to stop before it is restarted with the next test
+				s.Stop()
+				for s.IsRunning() {
+					time.Sleep(10 * time.Millisecond)
+				}
+			}()
+
+			for i := 0; i < test.clientCount; i++ {
+				c, e := client.New(client.NewConfig(net.IPv4zero.String(), 8392))
+				if e != nil {
+					t.Error(e)
+					return
+				}
+				// Rejected clients may see their connection reset while starting
+				_ = c.Start()
+				defer func() {
+					_ = c.Stop()
+				}()
+				time.Sleep(50 * time.Millisecond)
+			}
+
+			if s.ClientCount() != test.expectedClients {
+				t.Errorf("%s: expected %d connected clients, have %d", test.name, test.expectedClients, s.ClientCount())
+				return
+			}
+
+			select {
+			case e := <-s.Errors():
+				if test.expectedErr == nil || !errors.Is(e, test.expectedErr) {
+					t.Errorf("%s: expected %v, have %v", test.name, test.expectedErr, e)
+				}
+			default:
+				if test.expectedErr != nil {
+					t.Errorf("%s: expected %v", test.name, test.expectedErr)
+				}
+			}
+		}()
+	}
+
+	serverCfg := server.NewConfig(net.IPv4zero.String(), 8392)
+	serverCfg.DenyList = []string{"not-an-address"}
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if err = s.Start(); err == nil {
+		s.Stop()
+		t.Error("expected an invalid DenyList entry to fail Start()")
+	}
+}
+
+func TestNodeAccessControl(t *testing.T) {
+	cfg1 := node.NewConfig(":9005")
+	cfg2 := node.NewConfig(":9006")
+	cfg2.DenyList = []string{"127.0.0.0/8"}
+
+	n1, err := node.New[string](cfg1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	n2, err := node.New[string](cfg2)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = n1.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer n1.Stop()
+
+	err = n2.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer n2.Stop()
+
+	data := "hello"
+	_, _ = n1.Send(n2.Config().Address, &data)
+
+	select {
+	case e := <-n2.Errors():
+		if !errors.Is(e, comerr.ErrConnectionDenied) {
+			t.Errorf("expected ErrConnectionDenied, have %v", e)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected the denied connection to be reported")
+	}
+
+	select {
+	case <-n2.Recv():
+		t.Error("expected the message from a denied node to be rejected")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
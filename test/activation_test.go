@@ -0,0 +1,206 @@
+package test
+
+import (
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+	"tonysoft.com/comm/pkg/comerr"
+	"tonysoft.com/comm/pkg/server"
+)
+
+const activationChildEnv = "COMM_ACTIVATION_CHILD"
+
+func TestServerInheritedListener(t *testing.T) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:8397")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// Address and Port are ignored in favour of the listener
+	serverCfg := server.NewConfig(net.IPv4zero.String(), 1)
+	serverCfg.Listener = listener
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer s.Stop()
+
+	go echoOnce(s)
+
+	if reply := dialAndEcho(t, "127.0.0.1:8397", "hello"); reply != "hello" {
+		t.Errorf("expected 'hello', have '%s'", reply)
+	}
+}
+
+func TestServerSocketActivation(t *testing.T) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:8396")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = listener.Close()
+	}()
+
+	f, err := listener.(*net.TCPListener).File()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	// LISTEN_PID must be the pid of the process using the socket, as set by systemd
+	cmd := exec.Command("/bin/sh", "-c", `LISTEN_PID=$$ exec "$0" "$@"`,
+		os.Args[0], "-test.run=^TestSocketActivatedChild$")
+	cmd.Env = append(os.Environ(), activationChildEnv+"=1", "LISTEN_FDS=1", "LISTEN_FDNAMES=comm")
+	cmd.ExtraFiles = []*os.File{f}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err = cmd.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if reply := dialAndEcho(t, "127.0.0.1:8396", "activated"); reply != "activated" {
+		t.Errorf("expected 'activated', have '%s'", reply)
+	}
+
+	err = cmd.Wait()
+	if err != nil {
+		t.Errorf("child process failed: %v", err)
+	}
+}
+
+// TestSocketActivatedChild Runs in the child process started by
+// TestServerSocketActivation, serving a single connection on the socket
+// passed to it
+func TestSocketActivatedChild(t *testing.T) {
+	if os.Getenv(activationChildEnv) != "1" {
+		t.Skip("only runs as a child of TestServerSocketActivation")
+	}
+
+	serverCfg := server.NewConfig(net.IPv4zero.String(), 1)
+	serverCfg.SocketActivation = true
+	serverCfg.SocketActivationName = "comm"
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	if !echoOnce(s) {
+		t.Fatal("expected to echo a connection")
+	}
+}
+
+func TestServerSocketNotPassed(t *testing.T) {
+	serverCfg := server.NewConfig(net.IPv4zero.String(), 8415)
+	serverCfg.SocketActivation = true
+	serverCfg.SocketActivationName = "missing"
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err == nil {
+		stopAndWait(s)
+	}
+	if !errors.Is(err, comerr.ErrSocketNotPassed) {
+		t.Errorf("expected ErrSocketNotPassed, have %v", err)
+	}
+}
+
+func TestServerPreOpenedSocketType(t *testing.T) {
+	packetConn, err := net.ListenPacket("udp4", "127.0.0.1:8416")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = packetConn.Close()
+	}()
+
+	// Wrapped, so that it is not a *net.UDPConn
+	serverCfg := server.NewConfig(net.IPv4zero.String(), 1, useConnectionless)
+	serverCfg.PacketConn = struct{ net.PacketConn }{packetConn}
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err == nil {
+		stopAndWait(s)
+	}
+	if !errors.Is(err, comerr.ErrPreOpenedSocketType) {
+		t.Errorf("expected ErrPreOpenedSocketType, have %v", err)
+	}
+}
+
+func echoOnce(s server.Server) bool {
+	select {
+	case conn, ok := <-s.Accept():
+		if !ok {
+			return false
+		}
+		buffer := make([]byte, 64)
+		count, err := conn.Read(buffer)
+		if err != nil {
+			return false
+		}
+		_, err = conn.Write(buffer[:count])
+		return err == nil
+	case <-time.After(5 * time.Second):
+		return false
+	}
+}
+
+func dialAndEcho(t *testing.T, address string, message string) string {
+	conn, err := net.DialTimeout("tcp4", address, time.Second)
+	if err != nil {
+		t.Error(err)
+		return ""
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	_, err = conn.Write([]byte(message))
+	if err != nil {
+		t.Error(err)
+		return ""
+	}
+
+	buffer := make([]byte, 64)
+	count, err := conn.Read(buffer)
+	if err != nil {
+		t.Error(err)
+		return ""
+	}
+
+	return string(buffer[:count])
+}
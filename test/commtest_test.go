@@ -0,0 +1,22 @@
+package test
+
+import (
+	"testing"
+	"tonysoft.com/comm/pkg/commtest"
+)
+
+func TestConformanceTCP(t *testing.T) {
+	commtest.RunClientServerSuite(t, commtest.TCP())
+}
+
+func TestConformanceWebSocket(t *testing.T) {
+	commtest.RunClientServerSuite(t, commtest.WebSocket())
+}
+
+func TestConformanceMemory(t *testing.T) {
+	commtest.RunClientServerSuite(t, commtest.Memory())
+}
+
+func TestConformanceUDP(t *testing.T) {
+	commtest.RunClientServerSuite(t, commtest.UDP())
+}
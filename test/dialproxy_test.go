@@ -0,0 +1,349 @@
+package test
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"tonysoft.com/comm/pkg/client"
+	"tonysoft.com/comm/pkg/comerr"
+	"tonysoft.com/comm/pkg/node"
+	"tonysoft.com/comm/pkg/server"
+)
+
+// standInProxy A minimal SOCKS5 or HTTP CONNECT proxy that records the
+// targets it connects to
+type standInProxy struct {
+	listener  net.Listener
+	handshake func(conn net.Conn, reader *bufio.Reader) (string, bool)
+
+	mutex   sync.Mutex
+	targets []string
+}
+
+func startProxy(t *testing.T, handshake func(conn net.Conn, reader *bufio.Reader) (string, bool)) *standInProxy {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &standInProxy{listener: listener, handshake: handshake}
+	go p.serve()
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+
+	return p
+}
+
+func (p *standInProxy) Addr() string {
+	return p.listener.Addr().String()
+}
+
+func (p *standInProxy) Targets() []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]string(nil), p.targets...)
+}
+
+func (p *standInProxy) serve() {
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+		go p.relay(conn)
+	}
+}
+
+func (p *standInProxy) relay(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	reader := bufio.NewReader(conn)
+	target, ok := p.handshake(conn, reader)
+	if !ok {
+		return
+	}
+
+	p.mutex.Lock()
+	p.targets = append(p.targets, target)
+	p.mutex.Unlock()
+
+	upstream, err := net.Dial("tcp4", target)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = upstream.Close()
+	}()
+
+	go func() {
+		_, _ = io.Copy(upstream, reader)
+		_ = upstream.Close()
+	}()
+	_, _ = io.Copy(conn, upstream)
+}
+
+// socks5Handshake Accepts only username/password authentication as
+// comm/secret, and IPv4 or domain name targets
+func socks5Handshake(conn net.Conn, reader *bufio.Reader) (string, bool) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(reader, header); err != nil || header[0] != 5 {
+		return "", false
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(reader, methods); err != nil || !strings.Contains(string(methods), "\x02") {
+		_, _ = conn.Write([]byte{5, 0xFF})
+		return "", false
+	}
+	_, _ = conn.Write([]byte{5, 2})
+
+	readString := func() string {
+		length, _ := reader.ReadByte()
+		value := make([]byte, length)
+		_, _ = io.ReadFull(reader, value)
+		return string(value)
+	}
+	_, _ = reader.ReadByte()
+	if readString() != "comm" || readString() != "secret" {
+		_, _ = conn.Write([]byte{1, 1})
+		return "", false
+	}
+	_, _ = conn.Write([]byte{1, 0})
+
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(reader, request); err != nil || request[1] != 1 {
+		return "", false
+	}
+
+	var host string
+	switch request[3] {
+	case 1:
+		ip := make([]byte, 4)
+		_, _ = io.ReadFull(reader, ip)
+		host = net.IP(ip).String()
+	case 3:
+		host = readString()
+	default:
+		return "", false
+	}
+	port := make([]byte, 2)
+	_, _ = io.ReadFull(reader, port)
+
+	_, _ = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), true
+}
+
+func httpConnectHandshake(conn net.Conn, reader *bufio.Reader) (string, bool) {
+	requestLine, err := reader.ReadString('\n')
+	if err != nil {
+		return "", false
+	}
+	for {
+		line, readErr := reader.ReadString('\n')
+		if readErr != nil {
+			return "", false
+		}
+		if line == "\r\n" {
+			break
+		}
+	}
+
+	fields := strings.Fields(requestLine)
+	if len(fields) != 3 || fields[0] != "CONNECT" {
+		_, _ = conn.Write([]byte("HTTP/1.1 405 Method Not Allowed\r\n\r\n"))
+		return "", false
+	}
+
+	_, _ = conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+	return fields[1], true
+}
+
+func TestClientSocks5Proxy(t *testing.T) {
+	proxy := startProxy(t, socks5Handshake)
+
+	s, err := server.New(server.NewConfig("127.0.0.1", 8407))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer stopAndWait(s)
+
+	go echoOnce(s)
+
+	clientCfg := client.NewConfig("127.0.0.1", 8407)
+	clientCfg.DialProxy = "socks5://comm:secret@" + proxy.Addr()
+	c, err := client.New(clientCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = c.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = c.Stop()
+	}()
+
+	_, err = c.Write([]byte("hello"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	buffer := make([]byte, 16)
+	count, err := c.Read(buffer)
+	if err != nil || string(buffer[:count]) != "hello" {
+		t.Errorf("expected 'hello', have '%s' (%v)", string(buffer[:count]), err)
+	}
+
+	if targets := proxy.Targets(); len(targets) != 1 || targets[0] != "127.0.0.1:8407" {
+		t.Errorf("expected the proxy to connect to 127.0.0.1:8407, have %v", targets)
+	}
+
+	// The proxy rejects other credentials
+	clientCfg.DialProxy = "socks5://comm:wrong@" + proxy.Addr()
+	badClient, err := client.New(clientCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = badClient.Start()
+	if !errors.Is(err, comerr.ErrDialProxy) {
+		t.Errorf("expected ErrDialProxy, have %v", err)
+		_ = badClient.Stop()
+	}
+}
+
+func TestClientHttpConnectProxyFromEnvironment(t *testing.T) {
+	proxy := startProxy(t, httpConnectHandshake)
+	t.Setenv("ALL_PROXY", "")
+	t.Setenv("all_proxy", "")
+	t.Setenv("HTTPS_PROXY", "http://"+proxy.Addr())
+	t.Setenv("NO_PROXY", "")
+	t.Setenv("no_proxy", "")
+
+	s, err := server.New(server.NewConfig("127.0.0.1", 8407))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer stopAndWait(s)
+
+	clientCfg := client.NewConfig("127.0.0.1", 8407)
+	clientCfg.DialProxyFromEnvironment = true
+	c, err := client.New(clientCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, noProxy := range []string{"", "localhost,127.0.0.0/8"} {
+		t.Setenv("NO_PROXY", noProxy)
+
+		go echoOnce(s)
+
+		err = c.Start()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		_, err = c.Write([]byte("hello"))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		buffer := make([]byte, 16)
+		count, readErr := c.Read(buffer)
+		if readErr != nil || string(buffer[:count]) != "hello" {
+			t.Errorf("expected 'hello', have '%s' (%v)", string(buffer[:count]), readErr)
+		}
+		_ = c.Stop()
+	}
+
+	// Only the first connection went through the proxy
+	if targets := proxy.Targets(); len(targets) != 1 || targets[0] != "127.0.0.1:8407" {
+		t.Errorf("expected the proxy to connect to 127.0.0.1:8407 once, have %v", targets)
+	}
+}
+
+func TestNodeSocks5Proxy(t *testing.T) {
+	proxy := startProxy(t, socks5Handshake)
+
+	cfg1 := node.NewConfig("127.0.0.1:9011")
+	cfg1.DialProxy = "socks5://comm:secret@" + proxy.Addr()
+	cfg2 := node.NewConfig("127.0.0.1:9012")
+
+	n1, err := node.New[string](cfg1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	n2, err := node.New[string](cfg2)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = n1.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer n1.Stop()
+
+	err = n2.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer n2.Stop()
+
+	payload := "hello"
+	msg, err := n1.Send("127.0.0.1:9012", &payload)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	select {
+	case msgCopy := <-n2.Recv():
+		if msgCopy.ID() != msg.ID() {
+			t.Errorf("msgCopy.ID (%d) != msg.ID (%d)", msgCopy.ID(), msg.ID())
+		}
+	case <-time.After(time.Second):
+		t.Error("expected a message")
+		return
+	}
+
+	if targets := proxy.Targets(); len(targets) != 1 || targets[0] != "127.0.0.1:9012" {
+		t.Errorf("expected the proxy to connect to 127.0.0.1:9012, have %v", targets)
+	}
+}
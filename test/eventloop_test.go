@@ -0,0 +1,127 @@
+package test
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+	"tonysoft.com/comm/pkg/client"
+	"tonysoft.com/comm/pkg/server"
+)
+
+func TestTcpServerEventLoop(t *testing.T) {
+	const clientCount = 50
+	const requestCount = 5
+	const idleTimeoutMs = 300
+
+	echo := server.HandlerFunc(func(ctx context.Context, c server.Connection) {
+		buffer := make([]byte, 1024)
+		for {
+			count, e := c.ReadContext(ctx, buffer)
+			if e != nil || count < 1 {
+				// Closed, or nothing left to read until the connection is readable again
+				return
+			}
+			_, _ = c.Write(buffer[:count])
+		}
+	})
+
+	serverCfg := server.NewConfig(net.IPv4zero.String(), 8391)
+	serverCfg.EventLoop = true
+	serverCfg.EventLoopWorkers = 4
+	serverCfg.IdleConnectionTimeoutMs = idleTimeoutMs
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	serveErrChan := make(chan error, 1)
+	go func() {
+		serveErrChan <- s.Serve(echo)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	var wg sync.WaitGroup
+	wg.Add(clientCount)
+	for i := 0; i < clientCount; i++ {
+		go func() {
+			defer wg.Done()
+
+			clientCfg := client.NewConfig(net.IPv4zero.String(), 8391)
+			clientCfg.ReadTimeoutUs = 500000
+			c, e := client.New(clientCfg)
+			if e != nil {
+				t.Error(e)
+				return
+			}
+			e = c.Start()
+			if e != nil {
+				t.Error(e)
+				return
+			}
+			defer func() {
+				_ = c.Stop()
+			}()
+
+			buffer := make([]byte, 64)
+			for r := 0; r < requestCount; r++ {
+				request := GetRandomString(32)
+				_, e = c.Write(request)
+				if e != nil {
+					t.Error(e)
+					return
+				}
+
+				count, e := c.Read(buffer)
+				if e != nil {
+					t.Error(e)
+					return
+				}
+				if string(buffer[:count]) != string(request) {
+					t.Errorf("expected to receive '%s', received '%s' instead", string(request), string(buffer[:count]))
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Connections left open by a client that went quiet are pruned for being idle
+	idleClient, err := client.New(client.NewConfig(net.IPv4zero.String(), 8391))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	err = idleClient.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = idleClient.Stop()
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	if s.ClientCount() != 1 {
+		t.Errorf("expected 1 connected client, have %d", s.ClientCount())
+	}
+
+	time.Sleep(3 * idleTimeoutMs * time.Millisecond)
+	if s.ClientCount() != 0 {
+		t.Errorf("expected the idle client to be disconnected, have %d connected clients", s.ClientCount())
+	}
+
+	s.Stop()
+
+	select {
+	case err = <-serveErrChan:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("expected Serve() to return after the server was stopped")
+	}
+}
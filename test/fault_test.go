@@ -0,0 +1,248 @@
+package test
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+	"tonysoft.com/comm/pkg/client"
+	"tonysoft.com/comm/pkg/comerr"
+	"tonysoft.com/comm/pkg/fault"
+	"tonysoft.com/comm/pkg/server"
+)
+
+// recordingConn Records what is written to it
+type recordingConn struct {
+	net.Conn
+	writes []string
+	closed bool
+}
+
+func (c *recordingConn) Write(data []byte) (int, error) {
+	c.writes = append(c.writes, string(data))
+	return len(data), nil
+}
+
+func (c *recordingConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+func faultyWrites(cfg fault.Config, count int) []string {
+	rc := &recordingConn{}
+	conn := fault.Conn(rc, cfg)
+	for i := 0; i < count; i++ {
+		_, _ = conn.Write([]byte("message number " + strconv.Itoa(i)))
+	}
+	return rc.writes
+}
+
+func TestFaultReproducible(t *testing.T) {
+	cfg := fault.Config{
+		Seed:                42,
+		CorruptProbability:  0.2,
+		TruncateProbability: 0.2,
+		DropProbability:     0.2,
+		ReorderProbability:  0.2,
+	}
+
+	first := faultyWrites(cfg, 100)
+	if !reflect.DeepEqual(first, faultyWrites(cfg, 100)) {
+		t.Error("expected the same seed to inject the same faults")
+	}
+
+	if len(first) >= 100 {
+		t.Errorf("expected some writes to be dropped, have %d of 100", len(first))
+	}
+
+	cfg.Seed = 43
+	if reflect.DeepEqual(first, faultyWrites(cfg, 100)) {
+		t.Error("expected another seed to inject other faults")
+	}
+}
+
+func TestFaultReorder(t *testing.T) {
+	writes := faultyWrites(fault.Config{ReorderProbability: 1}, 4)
+	expected := []string{"message number 1", "message number 0", "message number 3", "message number 2"}
+	if !reflect.DeepEqual(writes, expected) {
+		t.Errorf("expected %v, have %v", expected, writes)
+	}
+}
+
+func TestFaultDisconnectAfterBytes(t *testing.T) {
+	s, err := server.New(server.NewConfig("mem://fault-disconnect", 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer stopAndWait(s)
+
+	c, err := client.New(client.NewConfig("mem://fault-disconnect", 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	c = fault.Client(c, fault.Config{DisconnectAfterBytes: 10})
+	err = c.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err = c.Write([]byte("12345678")); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	_, err = c.Write([]byte("12345678"))
+	if !errors.Is(err, comerr.ErrInjectedDisconnect) || c.IsConnected() {
+		t.Errorf("expected ErrInjectedDisconnect and the client to stop, have %v", err)
+	}
+}
+
+func TestFaultReconnect(t *testing.T) {
+	s, err := server.New(server.NewConfig("mem://fault-reconnect", 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer stopAndWait(s)
+
+	cfg := client.NewConfig("mem://fault-reconnect", 0)
+	cfg.AutoReconnect = true
+	cfg.ReconnectMinBackoffMs = 10
+	cfg.ReconnectBufferWrites = true
+	c, err := client.New(cfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	c = fault.Client(c, fault.Config{DisconnectAfterBytes: 5})
+	err = c.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = c.Stop()
+	}()
+
+	// The second write is buffered and sent once reconnected
+	for _, message := range []string{"hello", "again"} {
+		if _, err = c.Write([]byte(message)); err != nil {
+			t.Error(err)
+			return
+		}
+
+		select {
+		case conn := <-s.Accept():
+			buffer := make([]byte, 16)
+			count, readErr := conn.Read(buffer)
+			if readErr != nil || string(buffer[:count]) != message {
+				t.Errorf("expected '%s', have '%s' (%v)", message, string(buffer[:count]), readErr)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("expected a connection for '%s'", message)
+			return
+		}
+	}
+}
+
+func TestFaultLatencyAndBandwidth(t *testing.T) {
+	rc := &recordingConn{}
+	conn := fault.Conn(rc, fault.Config{LatencyMs: 20, JitterMs: 10, BytesPerSec: 1000})
+
+	// The first second's worth of bytes is sent right away, the rest as the cap allows
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		_, _ = conn.Write(make([]byte, 500))
+	}
+
+	if elapsed := time.Since(start); elapsed < 500*time.Millisecond {
+		t.Errorf("expected at least 500ms, have %v", elapsed)
+	}
+}
+
+// TestFaultReconnectStalledFlush Flushes more than the connection buffers
+// to a server that does not read, which must not block the client's other calls
+func TestFaultReconnectStalledFlush(t *testing.T) {
+	s, err := server.New(server.NewConfig("mem://fault-stalled-flush", 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer stopAndWait(s)
+
+	cfg := client.NewConfig("mem://fault-stalled-flush", 0)
+	cfg.AutoReconnect = true
+	cfg.ReconnectMinBackoffMs = 10
+	cfg.ReconnectBufferWrites = true
+	c, err := client.New(cfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	c = fault.Client(c, fault.Config{DisconnectAfterBytes: 5})
+	err = c.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// The second write is buffered, then flushed once reconnected
+	for _, size := range []int{5, 512 * 1024} {
+		if _, err = c.Write(make([]byte, size)); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-s.Accept():
+		case <-time.After(time.Second):
+			t.Error("expected the client to reconnect")
+			return
+		}
+	}
+
+	done := make(chan error)
+	go func() {
+		_, _ = c.Write([]byte("more"))
+		done <- c.Stop()
+	}()
+
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected the stalled flush not to block writing and stopping")
+	}
+}
@@ -0,0 +1,150 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+	"tonysoft.com/comm/pkg/client"
+	"tonysoft.com/comm/pkg/server"
+)
+
+func TestServerServeWithMiddleware(t *testing.T) {
+	var panicCount atomic.Int32
+	metrics := &server.Metrics{}
+
+	authenticate := func(_ context.Context, c server.Connection) error {
+		token := make([]byte, 4)
+		count, e := c.Read(token)
+		if e != nil {
+			return e
+		}
+		if string(token[:count]) != "open" {
+			return errors.New("invalid token")
+		}
+		return nil
+	}
+
+	echo := server.HandlerFunc(func(ctx context.Context, c server.Connection) {
+		buffer := make([]byte, 1024)
+		for {
+			count, e := c.ReadContext(ctx, buffer)
+			if e != nil {
+				return
+			}
+			if count < 1 {
+				continue
+			}
+			if string(buffer[:count]) == "boom" {
+				panic("boom")
+			}
+			_, _ = c.Write(buffer[:count])
+		}
+	})
+
+	handler := server.Chain(echo,
+		server.Recover(func(server.Connection, any) { panicCount.Add(1) }),
+		metrics.Middleware(),
+		server.Auth(authenticate))
+
+	serverCfg := server.NewConfig(net.IPv4zero.String(), 8382)
+	serverCfg.ReadTimeoutUs = 100000
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	serveErrChan := make(chan error, 1)
+	go func() {
+		serveErrChan <- s.Serve(handler)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	newClient := func() client.Client {
+		clientCfg := client.NewConfig(net.IPv4zero.String(), 8382)
+		clientCfg.ReadTimeoutUs = 500000
+		c, e := client.New(clientCfg)
+		if e != nil {
+			t.Error(e)
+			return nil
+		}
+		e = c.Start()
+		if e != nil {
+			t.Error(e)
+			return nil
+		}
+		return c
+	}
+
+	// Authenticated client gets its data echoed back
+	c1 := newClient()
+	if c1 == nil {
+		return
+	}
+	defer func() {
+		_ = c1.Stop()
+	}()
+
+	_, _ = c1.Write([]byte("open"))
+	time.Sleep(50 * time.Millisecond)
+	_, _ = c1.Write([]byte("ping"))
+
+	buffer := make([]byte, 4)
+	count, err := c1.Read(buffer)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if string(buffer[:count]) != "ping" {
+		t.Errorf("expected to receive 'ping', received '%s' instead", string(buffer[:count]))
+		return
+	}
+
+	// Unauthenticated client is disconnected without an echo
+	c2 := newClient()
+	if c2 == nil {
+		return
+	}
+	defer func() {
+		_ = c2.Stop()
+	}()
+
+	_, _ = c2.Write([]byte("shut"))
+	time.Sleep(50 * time.Millisecond)
+	_, _ = c2.Write([]byte("ping"))
+
+	count, _ = c2.Read(buffer)
+	if count > 0 {
+		t.Errorf("expected the unauthenticated client to receive nothing, received '%s'", string(buffer[:count]))
+		return
+	}
+
+	// A panicking handler is recovered and its connection closed
+	_, _ = c1.Write([]byte("boom"))
+	time.Sleep(100 * time.Millisecond)
+
+	if panicCount.Load() != 1 {
+		t.Errorf("expected 1 recovered panic, have %d", panicCount.Load())
+		return
+	}
+
+	if metrics.Total() != 2 || metrics.Active() != 0 {
+		t.Errorf("expected 2 total and 0 active connections, have %d and %d", metrics.Total(), metrics.Active())
+		return
+	}
+
+	s.Stop()
+
+	select {
+	case err = <-serveErrChan:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("expected Serve() to return after the server was stopped")
+	}
+}
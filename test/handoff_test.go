@@ -0,0 +1,172 @@
+package test
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"tonysoft.com/comm/pkg/handoff"
+	"tonysoft.com/comm/pkg/server"
+)
+
+func TestServerHandoff(t *testing.T) {
+	oldServer, err := server.New(server.NewConfig(net.IPv4zero.String(), 8398))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = oldServer.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer oldServer.Stop()
+
+	path := filepath.Join(t.TempDir(), "handoff.sock")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	offerErr := make(chan error, 1)
+	go func() {
+		offerErr <- handoff.Offer(ctx, path, map[string]server.Server{"tcp": oldServer})
+	}()
+
+	var h *handoff.Handoff
+	for h == nil && ctx.Err() == nil {
+		h, err = handoff.Receive(path)
+		if err != nil {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	defer h.Close()
+
+	if h.File("tcp") == nil {
+		t.Error("expected the 'tcp' socket to be handed off")
+		return
+	}
+
+	// The port is in use, so the new server must use the handed off socket
+	newCfg := server.NewConfig(net.IPv4zero.String(), 8398)
+	newCfg.ListenerFile = h.File("tcp")
+	newServer, err := server.New(newCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = newServer.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		newServer.Stop()
+		for newServer.IsRunning() {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	err = h.Ready()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = <-offerErr
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if oldServer.IsRunning() {
+		t.Error("expected the old server to be shut down after the handoff")
+	}
+
+	go echoOnce(newServer)
+
+	if reply := dialAndEcho(t, "127.0.0.1:8398", "handed off"); reply != "handed off" {
+		t.Errorf("expected 'handed off', have '%s'", reply)
+	}
+}
+
+func TestServerRestart(t *testing.T) {
+	s, err := server.New(server.NewConfig(net.IPv4zero.String(), 8399))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer s.Stop()
+
+	// Restart runs the executable with the same arguments, so only run the child test
+	args := os.Args
+	os.Args = []string{args[0], "-test.run=^TestRestartedChild$"}
+	defer func() {
+		os.Args = args
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	process, err := handoff.Restart(ctx, filepath.Join(t.TempDir(), "handoff.sock"), map[string]server.Server{"echo": s})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if s.IsRunning() {
+		t.Error("expected the server to be shut down after the restart")
+	}
+
+	if reply := dialAndEcho(t, "127.0.0.1:8399", "restarted"); reply != "restarted" {
+		t.Errorf("expected 'restarted', have '%s'", reply)
+	}
+
+	state, err := process.Wait()
+	if err != nil || !state.Success() {
+		t.Errorf("child process failed: %v %v", state, err)
+	}
+}
+
+// TestRestartedChild Runs in the process started by TestServerRestart,
+// serving a single connection on the socket handed off to it
+func TestRestartedChild(t *testing.T) {
+	if os.Getenv(handoff.EnvPath) == "" {
+		t.Skip("only runs as a child of TestServerRestart")
+	}
+
+	h, err := handoff.Inherited()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := server.NewConfig(net.IPv4zero.String(), 8399)
+	cfg.ListenerFile = h.File("echo")
+	s, err := server.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	err = h.Ready()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !echoOnce(s) {
+		t.Fatal("expected to echo a connection")
+	}
+}
@@ -0,0 +1,302 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"syscall"
+	"testing"
+	"time"
+	"tonysoft.com/comm/pkg/client"
+	"tonysoft.com/comm/pkg/node"
+	"tonysoft.com/comm/pkg/server"
+)
+
+func TestMemoryClientServer(t *testing.T) {
+	s, err := server.New(server.NewConfig("mem://echo", 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer stopAndWait(s)
+
+	if s.Addr().String() != "mem://echo" {
+		t.Errorf("expected mem://echo, have %s", s.Addr())
+	}
+
+	// The address is in use until the server stops
+	other, _ := server.New(server.NewConfig("mem://echo", 0))
+	if err = other.Start(); !errors.Is(err, syscall.EADDRINUSE) {
+		t.Errorf("expected EADDRINUSE, have %v", err)
+		other.Stop()
+	}
+
+	go echoOnce(s)
+
+	cfg := client.NewConfig("mem://echo", 0)
+	cfg.LocalAddress = "mem://caller"
+	c, err := client.New(cfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = c.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = c.Stop()
+	}()
+
+	_, err = c.Write([]byte("hello"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	buffer := make([]byte, 16)
+	count, err := c.Read(buffer)
+	if err != nil || string(buffer[:count]) != "hello" {
+		t.Errorf("expected 'hello', have '%s' (%v)", string(buffer[:count]), err)
+	}
+
+	if c.LocalAddr().String() != "mem://caller" || c.RemoteAddr().String() != "mem://echo" {
+		t.Errorf("unexpected addresses %s -> %s", c.LocalAddr(), c.RemoteAddr())
+	}
+
+	// Nothing listens on other names
+	nobody, _ := client.New(client.NewConfig("mem://nobody", 0))
+	if err = nobody.Start(); !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Errorf("expected ECONNREFUSED, have %v", err)
+		_ = nobody.Stop()
+	}
+}
+
+func TestMemoryTimeoutAndClose(t *testing.T) {
+	s, err := server.New(server.NewConfig("mem://close", 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer stopAndWait(s)
+
+	cfg := client.NewConfig("mem://close", 0)
+	cfg.ReadTimeoutUs = 10000
+	c, err := client.New(cfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = c.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	conn := <-s.Accept()
+
+	// As with sockets, timeouts are not errors
+	buffer := make([]byte, 16)
+	count, err := c.Read(buffer)
+	if count != 0 || err != nil {
+		t.Errorf("expected a timeout, have %d bytes (%v)", count, err)
+	}
+
+	// Data written before closing is read before EOF
+	_, _ = conn.Write([]byte("bye"))
+	_ = conn.Close()
+
+	count, err = c.Read(buffer)
+	if err != nil || string(buffer[:count]) != "bye" {
+		t.Errorf("expected 'bye', have '%s' (%v)", string(buffer[:count]), err)
+	}
+
+	_, err = c.Read(buffer)
+	if !errors.Is(err, io.EOF) || c.IsConnected() {
+		t.Errorf("expected EOF and the client to stop, have %v", err)
+	}
+
+	if s.ClientCount() != 0 {
+		t.Errorf("expected no clients, have %d", s.ClientCount())
+	}
+}
+
+func TestMemoryLargeWrite(t *testing.T) {
+	s, err := server.New(server.NewConfig("mem://large", 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer stopAndWait(s)
+
+	c, err := client.New(client.NewConfig("mem://large", 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = c.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = c.Stop()
+	}()
+
+	conn := <-s.Accept()
+
+	// Larger than what is buffered, so the write completes as it is read
+	data := bytes.Repeat([]byte("0123456789abcdef"), 64*1024)
+	writeErrChan := make(chan error, 1)
+	go func() {
+		_, writeErr := c.Write(data)
+		writeErrChan <- writeErr
+	}()
+
+	received := make([]byte, 0, len(data))
+	buffer := make([]byte, 32*1024)
+	for len(received) < len(data) {
+		count, readErr := conn.Read(buffer)
+		if readErr != nil {
+			t.Error(readErr)
+			return
+		}
+		received = append(received, buffer[:count]...)
+	}
+
+	if err = <-writeErrChan; err != nil || !bytes.Equal(received, data) {
+		t.Errorf("expected %d bytes, have %d (%v)", len(data), len(received), err)
+	}
+}
+
+func TestMemoryLatency(t *testing.T) {
+	serverCfg := server.NewConfig("mem://slow", 0)
+	serverCfg.MemoryLatencyMs = 50
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer stopAndWait(s)
+
+	go echoOnce(s)
+
+	c, err := client.New(client.NewConfig("mem://slow", 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = c.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = c.Stop()
+	}()
+
+	start := time.Now()
+	_, err = c.Write([]byte("hello"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	buffer := make([]byte, 16)
+	count, err := c.Read(buffer)
+	if err != nil || string(buffer[:count]) != "hello" {
+		t.Errorf("expected 'hello', have '%s' (%v)", string(buffer[:count]), err)
+	}
+
+	// Delayed both ways
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("expected a round trip of at least 100ms, have %v", elapsed)
+	}
+}
+
+func TestMemoryNodes(t *testing.T) {
+	n1, err := node.New[string](node.NewConfig("mem://n1"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	n2, err := node.New[string](node.NewConfig("mem://n2"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = n1.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer n1.Stop()
+
+	err = n2.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer n2.Stop()
+
+	payload := "hello"
+	msg, err := n1.Send("mem://n2", &payload)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	select {
+	case msgCopy := <-n2.Recv():
+		if msgCopy.ID() != msg.ID() || msgCopy.FromNode() != "mem://n1" || *msgCopy.Data != "hello" {
+			t.Errorf("unexpected message %d from %s", msgCopy.ID(), msgCopy.FromNode())
+		}
+	case <-time.After(time.Second):
+		t.Error("expected a message")
+		return
+	}
+
+	select {
+	case rcpt := <-n1.Status():
+		if rcpt.ID() != msg.ID() || rcpt.FromNode() != "mem://n2" {
+			t.Errorf("unexpected receipt %d from %s", rcpt.ID(), rcpt.FromNode())
+		}
+	case <-time.After(time.Second):
+		t.Error("expected a receipt")
+	}
+
+	if nodes := n2.ConnectedNodes(); len(nodes) != 1 || nodes[0] != "mem://n1" {
+		t.Errorf("expected n2 to be connected to mem://n1, have %v", nodes)
+	}
+}
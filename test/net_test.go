@@ -0,0 +1,208 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+	"tonysoft.com/comm/internal/socket"
+	"tonysoft.com/comm/pkg/client"
+	"tonysoft.com/comm/pkg/server"
+)
+
+func TestNetListenerHttpServe(t *testing.T) {
+	const body = "hello from comm"
+
+	serverCfg := server.NewConfig(net.IPv4zero.String(), 8380)
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	listener := server.NetListener(s)
+	defer func() {
+		_ = listener.Close()
+	}()
+
+	if listener.Addr() == nil || listener.Addr().String() != "0.0.0.0:8380" {
+		t.Errorf("unexpected listener address %v", listener.Addr())
+		return
+	}
+
+	go func() {
+		_ = http.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			_, _ = w.Write([]byte(body))
+		}))
+	}()
+
+	httpClient := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, address string) (net.Conn, error) {
+				host, port, e := net.SplitHostPort(address)
+				if e != nil {
+					return nil, e
+				}
+				p, e := strconv.ParseUint(port, 10, 16)
+				if e != nil {
+					return nil, e
+				}
+
+				c, e := client.New(client.NewConfig(host, uint16(p)))
+				if e != nil {
+					return nil, e
+				}
+
+				e = c.StartContext(ctx)
+				if e != nil {
+					return nil, e
+				}
+
+				return client.NetConn(c), nil
+			},
+		},
+		Timeout: 5 * time.Second,
+	}
+	defer httpClient.CloseIdleConnections()
+
+	for i := 0; i < 3; i++ {
+		resp, e := httpClient.Get("http://127.0.0.1:8380/")
+		if e != nil {
+			t.Error(e)
+			return
+		}
+
+		respBody, e := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if e != nil {
+			t.Error(e)
+			return
+		}
+
+		if string(respBody) != body {
+			t.Errorf("expected to receive '%s', received '%s' instead", body, string(respBody))
+			return
+		}
+	}
+}
+
+func TestNetConnDeadline(t *testing.T) {
+	serverCfg := server.NewConfig(net.IPv4zero.String(), 8381)
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer s.Stop()
+
+	clientCfg := client.NewConfig(net.IPv4zero.String(), 8381)
+	clientCfg.ReadTimeoutUs = 10000000
+	c, err := client.New(clientCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = c.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	conn := client.NetConn(c)
+
+	if conn.RemoteAddr().String() != "127.0.0.1:8381" && conn.RemoteAddr().String() != "0.0.0.0:8381" {
+		t.Errorf("unexpected remote address %v", conn.RemoteAddr())
+	}
+
+	err = conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	startTime := time.Now()
+	_, err = conn.Read(make([]byte, 4))
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("expected os.ErrDeadlineExceeded, have %v", err)
+		return
+	}
+	if elapsed := time.Since(startTime); elapsed > time.Second {
+		t.Errorf("expected Read() to return within 1s, took %v", elapsed)
+		return
+	}
+
+	err = conn.Close()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if c.IsConnected() {
+		t.Error("expected closing the adapter to stop the client")
+		return
+	}
+
+	_, err = conn.Read(make([]byte, 4))
+	if !errors.Is(err, net.ErrClosed) {
+		t.Errorf("expected net.ErrClosed, have %v", err)
+	}
+}
+
+// nonBlockingReader Returns right away without data, like a non-blocking
+// RFCOMM socket does
+type nonBlockingReader struct {
+	reads atomic.Int32
+}
+
+func (r *nonBlockingReader) ReadContext(_ context.Context, _ []byte) (int, error) {
+	r.reads.Add(1)
+	return -1, nil
+}
+
+func (r *nonBlockingReader) WriteContext(_ context.Context, data []byte) (int, error) {
+	return len(data), nil
+}
+
+func (r *nonBlockingReader) LocalAddr() net.Addr {
+	return nil
+}
+
+func (r *nonBlockingReader) RemoteAddr() net.Addr {
+	return nil
+}
+
+func TestNetConnNonBlockingRead(t *testing.T) {
+	r := &nonBlockingReader{}
+	conn := socket.NewNetConn(r, func() error { return nil })
+
+	_ = conn.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+	_, err := conn.Read(make([]byte, 4))
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("expected os.ErrDeadlineExceeded, have %v", err)
+	}
+
+	// Backing off up to 50ms between reads rather than spinning
+	if reads := r.reads.Load(); reads > 50 {
+		t.Errorf("expected reads to be backed off, have %d", reads)
+	}
+}
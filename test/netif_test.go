@@ -0,0 +1,162 @@
+package test
+
+import (
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+	"tonysoft.com/comm/pkg/client"
+	"tonysoft.com/comm/pkg/comerr"
+	"tonysoft.com/comm/pkg/server"
+)
+
+func TestServerInterfaceBinding(t *testing.T) {
+	serverCfg := server.NewConfig(net.IPv4zero.String(), 8404)
+	serverCfg.Interface = "lo"
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer stopAndWait(s)
+
+	if s.Addr().String() != "127.0.0.1:8404" {
+		t.Errorf("expected to bind to 127.0.0.1:8404, have %s", s.Addr())
+	}
+
+	serverCfg.Interface = "nonexistent0"
+	badServer, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = badServer.Start()
+	if !errors.Is(err, comerr.ErrInterfaceAddress) {
+		t.Errorf("expected ErrInterfaceAddress, have %v", err)
+		badServer.Stop()
+	}
+}
+
+const netnsChildEnv = "COMM_NETNS_CHILD"
+
+// TestInterfaceAddressChange Runs TestInterfaceAddressChangeChild in a
+// network namespace of its own, so that the host's addresses are left alone
+// even if the test dies before cleaning up
+func TestInterfaceAddressChange(t *testing.T) {
+	if err := exec.Command("unshare", "-n", "true").Run(); err != nil {
+		t.Skipf("cannot create a network namespace: %v", err)
+	}
+
+	cmd := exec.Command("unshare", "-n", "/bin/sh", "-c", `ip link set lo up && exec "$0" "$@"`,
+		os.Args[0], "-test.run=^TestInterfaceAddressChangeChild$", "-test.v")
+	cmd.Env = append(os.Environ(), netnsChildEnv+"=1")
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Errorf("child process failed: %v\n%s", err, output)
+	} else if strings.Contains(string(output), "--- SKIP") {
+		t.Skipf("child process skipped:\n%s", output)
+	}
+}
+
+// TestInterfaceAddressChangeChild Runs in the network namespace created by
+// TestInterfaceAddressChange
+func TestInterfaceAddressChangeChild(t *testing.T) {
+	if os.Getenv(netnsChildEnv) != "1" {
+		t.Skip("only runs as a child of TestInterfaceAddressChange")
+	}
+
+	// An address label stands in for an interface whose address is renewed
+	const label = "lo:comm"
+	addIp(t, "127.0.0.88/8", label)
+
+	serverCfg := server.NewConfig(net.IPv4zero.String(), 8405)
+	serverCfg.Interface = label
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer stopAndWait(s)
+
+	if s.Addr().String() != "127.0.0.88:8405" {
+		t.Errorf("expected to bind to 127.0.0.88:8405, have %s", s.Addr())
+		return
+	}
+
+	// The client's source address is that of the label too
+	clientCfg := client.NewConfig("127.0.0.88", 8405)
+	clientCfg.LocalAddress = label
+	c, err := client.New(clientCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	err = c.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_ = c.Stop()
+
+	select {
+	case conn := <-s.Accept():
+		if !strings.HasPrefix(conn.RemoteAddress(), "127.0.0.88:") {
+			t.Errorf("expected a connection from 127.0.0.88, have %s", conn.RemoteAddress())
+		}
+	case <-time.After(time.Second):
+		t.Error("expected a connection")
+		return
+	}
+
+	// The server follows the label to its new address
+	delIp("127.0.0.88/8")
+	addIp(t, "127.0.0.89/8", label)
+
+	deadline := time.Now().Add(3 * time.Second)
+	for s.Addr().String() != "127.0.0.89:8405" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if s.Addr().String() != "127.0.0.89:8405" {
+		t.Errorf("expected to rebind to 127.0.0.89:8405, have %s", s.Addr())
+		return
+	}
+
+	conn, err := net.Dial("tcp4", "127.0.0.89:8405")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_ = conn.Close()
+}
+
+// addIp Adds an address to lo, deleted when the test is done
+func addIp(t *testing.T, cidr string, label string) {
+	output, err := exec.Command("ip", "addr", "add", cidr, "dev", "lo", "label", label).CombinedOutput()
+	if err != nil {
+		t.Skipf("cannot add %s to lo: %v %s", cidr, err, output)
+	}
+	t.Cleanup(func() {
+		delIp(cidr)
+	})
+}
+
+func delIp(cidr string) {
+	_ = exec.Command("ip", "addr", "del", cidr, "dev", "lo").Run()
+}
@@ -1,6 +1,7 @@
 package test
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
 	"runtime"
@@ -10,6 +11,7 @@ import (
 	"sync/atomic"
 	"testing"
 	"time"
+	"tonysoft.com/comm/pkg/comerr"
 	"tonysoft.com/comm/pkg/node"
 )
 
@@ -451,3 +453,12 @@ func TestMultiNodeComm(t *testing.T) {
 		return
 	}
 }
+
+func TestNodeUnsupportedTransport(t *testing.T) {
+	for _, address := range []string{"239.0.0.1:9000", "255.255.255.255:9000", "00:11:22:33:44:55"} {
+		_, err := node.New[string](node.NewConfig(address))
+		if !errors.Is(err, comerr.ErrNodeTransportUnsupported) {
+			t.Errorf("%s: expected ErrNodeTransportUnsupported, have %v", address, err)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package test
+
+import (
+	"errors"
+	"golang.org/x/sys/unix"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+	"tonysoft.com/comm/internal/socket"
+	"tonysoft.com/comm/pkg/client"
+	"tonysoft.com/comm/pkg/comerr"
+	"tonysoft.com/comm/pkg/server"
+)
+
+func TestSocketOptionsApply(t *testing.T) {
+	options := socket.Options{
+		Nagle:                true,
+		KeepAliveIdleSec:     30,
+		KeepAliveIntervalSec: 5,
+		KeepAliveCount:       3,
+		UserTimeoutMs:        10000,
+		ReceiveBufferSize:    65536,
+		TOS:                  46 << 2,
+		TTL:                  32,
+	}
+
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = listener.Close()
+	}()
+
+	conn, err := net.Dial("tcp4", listener.Addr().String())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	rawConn, err := conn.(*net.TCPConn).SyscallConn()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = options.Apply(rawConn, "tcp")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	expected := []struct {
+		name  string
+		level int
+		opt   int
+		value int
+	}{
+		{"TCP_NODELAY", unix.IPPROTO_TCP, unix.TCP_NODELAY, 0},
+		{"SO_KEEPALIVE", unix.SOL_SOCKET, unix.SO_KEEPALIVE, 1},
+		{"TCP_KEEPIDLE", unix.IPPROTO_TCP, unix.TCP_KEEPIDLE, options.KeepAliveIdleSec},
+		{"TCP_KEEPINTVL", unix.IPPROTO_TCP, unix.TCP_KEEPINTVL, options.KeepAliveIntervalSec},
+		{"TCP_KEEPCNT", unix.IPPROTO_TCP, unix.TCP_KEEPCNT, options.KeepAliveCount},
+		{"TCP_USER_TIMEOUT", unix.IPPROTO_TCP, unix.TCP_USER_TIMEOUT, options.UserTimeoutMs},
+		{"SO_RCVBUF", unix.SOL_SOCKET, unix.SO_RCVBUF, 2 * options.ReceiveBufferSize}, // doubled by the kernel
+		{"IP_TOS", unix.IPPROTO_IP, unix.IP_TOS, options.TOS},
+		{"IP_TTL", unix.IPPROTO_IP, unix.IP_TTL, options.TTL},
+	}
+
+	_ = rawConn.Control(func(fd uintptr) {
+		for _, e := range expected {
+			value, getErr := unix.GetsockoptInt(int(fd), e.level, e.opt)
+			if getErr != nil || value != e.value {
+				t.Errorf("expected %s to be %d, have %d (%v)", e.name, e.value, value, getErr)
+			}
+		}
+	})
+}
+
+func TestClientServerSocketOptions(t *testing.T) {
+	options := server.SocketOptions{
+		KeepAliveIdleSec: 30,
+		UserTimeoutMs:    10000,
+		TOS:              46 << 2,
+	}
+
+	serverCfg := server.NewConfig(net.IPv4zero.String(), 8403)
+	serverCfg.SocketOptions = options
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer s.Stop()
+
+	go echoOnce(s)
+
+	clientCfg := client.NewConfig("127.0.0.1", 8403)
+	clientCfg.SocketOptions = options
+	c, err := client.New(clientCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = c.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = c.Stop()
+	}()
+
+	_, err = c.Write([]byte("hello"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	buffer := make([]byte, 16)
+	count, err := c.Read(buffer)
+	if err != nil || string(buffer[:count]) != "hello" {
+		t.Errorf("expected 'hello', have '%s' (%v)", string(buffer[:count]), err)
+	}
+
+	// Options that cannot be set fail the dial
+	clientCfg.SocketOptions = client.SocketOptions{BindToDevice: "nonexistent0"}
+	clientCfg.ConnectTimeoutSec = 1
+	badClient, err := client.New(clientCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = badClient.Start()
+	if !errors.Is(err, comerr.ErrSetSocketOption) {
+		t.Errorf("expected ErrSetSocketOption, have %v", err)
+		_ = badClient.Stop()
+	}
+}
+
+// TestServerSocketOptionsFailure Accepts a connection whose SocketOptions
+// cannot be set, which must be closed rather than leaked
+func TestServerSocketOptionsFailure(t *testing.T) {
+	// Pre-opened, so that the options are only set on accepted connections
+	listener, err := net.Listen("tcp4", "127.0.0.1:8417")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	serverCfg := server.NewConfig(net.IPv4zero.String(), 1)
+	serverCfg.Listener = listener
+	serverCfg.SocketOptions.TTL = 256 // out of range
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer stopAndWait(s)
+
+	// The connection may already be reset by the time it is established
+	conn, err := net.Dial("tcp4", "127.0.0.1:8417")
+	if err == nil {
+		defer conn.Close()
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, err = conn.Read(make([]byte, 16))
+	}
+	if !errors.Is(err, io.EOF) && !errors.Is(err, syscall.ECONNRESET) {
+		t.Errorf("expected the connection to be closed, have %v", err)
+	}
+
+	select {
+	case e := <-s.Errors():
+		if !errors.Is(e, comerr.ErrSetSocketOption) {
+			t.Errorf("expected ErrSetSocketOption, have %v", e)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected setting the options to fail")
+	}
+}
@@ -0,0 +1,156 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+	"tonysoft.com/comm/pkg/client"
+	"tonysoft.com/comm/pkg/comerr"
+	"tonysoft.com/comm/pkg/server"
+	"tonysoft.com/comm/pkg/stream"
+)
+
+func TestStringStreamOverflowPolicy(t *testing.T) {
+	const wordCount = 10
+	const bufferSize = 2
+
+	var words []string
+	var wordsStream []byte
+	for i := 0; i < wordCount; i++ {
+		word := GetRandomCString(10)
+		words = append(words, string(word[:len(word)-1]))
+		wordsStream = append(wordsStream, word...)
+	}
+
+	tests := []struct {
+		policy   stream.OverflowPolicy
+		expected []string
+		dropped  uint64
+		err      error
+	}{
+		{stream.DropNewest, words[:bufferSize], wordCount - bufferSize, nil},
+		{stream.DropOldest, words[wordCount-bufferSize:], wordCount - bufferSize, nil},
+		{stream.Block, words, 0, nil},
+		{stream.CloseConnection, words[:bufferSize], 1, comerr.ErrChannelOverflow},
+	}
+
+	for _, test := range tests {
+		s := &stream.StringStream{OverflowPolicy: test.policy}
+		streamChan := s.Stream(stream.NewDataReader(wordsStream), bufferSize)
+
+		// Let the stream overflow before reading from it
+		time.Sleep(100 * time.Millisecond)
+
+		var wordsCopy []string
+		for word := range streamChan {
+			wordsCopy = append(wordsCopy, word)
+		}
+
+		if len(wordsCopy) != len(test.expected) {
+			t.Errorf("%v: expected %d words, have %d", test.policy, len(test.expected), len(wordsCopy))
+			continue
+		}
+		for i := range test.expected {
+			if wordsCopy[i] != test.expected[i] {
+				t.Errorf("%v: unexpected word at index %d (expected %s, have %s)", test.policy, i, test.expected[i], wordsCopy[i])
+				break
+			}
+		}
+
+		var counter any = s
+		if dropped := counter.(stream.DropCounter).Dropped(); dropped != test.dropped {
+			t.Errorf("%v: expected %d dropped, have %d", test.policy, test.dropped, dropped)
+		}
+
+		if test.err != nil && !errors.Is(s.Error(), test.err) {
+			t.Errorf("%v: expected %v, have %v", test.policy, test.err, s.Error())
+		}
+	}
+}
+
+func TestServerAcceptOverflowPolicy(t *testing.T) {
+	const datagramCount = 5
+	const connectionLimit = 2
+
+	for _, policy := range []stream.OverflowPolicy{stream.DropNewest, stream.DropOldest} {
+		func() {
+			serverCfg := server.NewConfig(net.IPv4zero.String(), 8385, useConnectionless)
+			serverCfg.ClientConnectionLimit = connectionLimit
+			serverCfg.AcceptOverflowPolicy = policy
+			s, err := server.New(serverCfg)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			err = s.Start()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer func() {
+				// Wait for the server to stop before it is restarted with the next policy
+				_ = s.Shutdown(context.Background())
+			}()
+
+			c, err := client.New(client.NewConfig(net.IPv4zero.String(), 8385, useConnectionless))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			err = c.Start()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer func() {
+				_ = c.Stop()
+			}()
+
+			// Each datagram is surfaced as a connection, none of which are accepted yet
+			for i := 0; i < datagramCount; i++ {
+				_, _ = c.Write([]byte{byte('0' + i)})
+				time.Sleep(10 * time.Millisecond)
+			}
+			time.Sleep(100 * time.Millisecond)
+
+			if dropped := s.DroppedConnections(); dropped != datagramCount-connectionLimit {
+				t.Errorf("%v: expected %d dropped connections, have %d", policy, datagramCount-connectionLimit, dropped)
+				return
+			}
+
+			var errCount int
+			for len(s.Errors()) > 0 {
+				if e := <-s.Errors(); errors.Is(e, comerr.ErrChannelOverflow) {
+					errCount++
+				}
+			}
+			if errCount != datagramCount-connectionLimit {
+				t.Errorf("%v: expected %d overflow errors, have %d", policy, datagramCount-connectionLimit, errCount)
+				return
+			}
+
+			first := byte('0')
+			if policy == stream.DropOldest {
+				first = byte('0' + datagramCount - connectionLimit)
+			}
+
+			for i := 0; i < connectionLimit; i++ {
+				conn := <-s.Accept()
+				buffer := make([]byte, 1)
+				_, err = conn.Read(buffer)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if buffer[0] != first+byte(i) {
+					t.Errorf("%v: expected datagram '%c', have '%c'", policy, first+byte(i), buffer[0])
+					return
+				}
+			}
+		}()
+	}
+}
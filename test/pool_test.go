@@ -0,0 +1,258 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+	"tonysoft.com/comm/pkg/client"
+	"tonysoft.com/comm/pkg/comerr"
+	"tonysoft.com/comm/pkg/server"
+)
+
+func TestClientPool(t *testing.T) {
+	var acceptCount atomic.Int32
+
+	serverCfg := server.NewConfig(net.IPv4zero.String(), 8378)
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer s.Stop()
+
+	go func() {
+		for conn := range s.Accept() {
+			acceptCount.Add(1)
+			go func(c server.Connection) {
+				buffer := make([]byte, serverCfg.ReadBufferSize)
+				for {
+					count, e := c.Read(buffer)
+					if e != nil {
+						return
+					}
+					if count > 0 {
+						_, _ = c.Write(buffer[:count])
+					}
+				}
+			}(conn)
+		}
+	}()
+
+	poolCfg := client.NewPoolConfig()
+	poolCfg.MaxConnectionsPerHost = 1
+	poolCfg.IdleConnectionTimeoutMs = 200
+	pool := client.NewPool(poolCfg)
+	defer func() {
+		e := pool.Close()
+		if e != nil {
+			t.Error(e)
+		}
+	}()
+
+	clientCfg := client.NewConfig(net.IPv4zero.String(), 8378)
+	clientCfg.ReadTimeoutUs = 500000
+
+	echo := func(c client.Client) {
+		_, e := c.Write([]byte("ping"))
+		if e != nil {
+			t.Error(e)
+			return
+		}
+
+		buffer := make([]byte, 4)
+		count, e := c.Read(buffer)
+		if e != nil {
+			t.Error(e)
+			return
+		}
+		if string(buffer[:count]) != "ping" {
+			t.Errorf("expected to receive 'ping', received '%s' instead", string(buffer[:count]))
+		}
+	}
+
+	c1, err := pool.Get(clientCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	echo(c1)
+
+	_, err = pool.Get(clientCfg)
+	if !errors.Is(err, comerr.ErrConnectionLimitReached) {
+		t.Errorf("expected ErrConnectionLimitReached, have %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = pool.GetContext(ctx, clientCfg)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, have %v", err)
+		return
+	}
+
+	// Returning the client keeps the connection warm for the next checkout
+	err = c1.Stop()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if pool.IdleCount() != 1 {
+		t.Errorf("expected 1 idle connection, have %d", pool.IdleCount())
+		return
+	}
+
+	c2, err := pool.Get(clientCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	echo(c2)
+
+	if count := acceptCount.Load(); count != 1 {
+		t.Errorf("expected the server to accept 1 connection, accepted %d", count)
+		return
+	}
+
+	err = c2.Stop()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// Idle connections are evicted after IdleConnectionTimeoutMs
+	time.Sleep(time.Second)
+
+	if pool.IdleCount() != 0 || pool.ConnectionCount() != 0 {
+		t.Errorf("expected idle connections to be evicted, have %d idle", pool.IdleCount())
+		return
+	}
+
+	c3, err := pool.Get(clientCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	echo(c3)
+	_ = c3.Stop()
+
+	if count := acceptCount.Load(); count != 2 {
+		t.Errorf("expected the server to accept 2 connections, accepted %d", count)
+	}
+}
+
+func TestClientPoolHealthCheck(t *testing.T) {
+	serverCfg := server.NewConfig(net.IPv4zero.String(), 8379)
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer s.Stop()
+
+	serverConns := make(chan server.Connection, 10)
+	go func() {
+		for conn := range s.Accept() {
+			serverConns <- conn
+		}
+	}()
+
+	pool := client.NewPool(client.NewPoolConfig())
+	defer func() {
+		_ = pool.Close()
+	}()
+
+	clientCfg := client.NewConfig(net.IPv4zero.String(), 8379)
+
+	c1, err := pool.Get(clientCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_ = c1.Stop()
+
+	// Close the pooled connection from the server side while it sits idle
+	conn := <-serverConns
+	_ = conn.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	c2, err := pool.Get(clientCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = c2.Stop()
+	}()
+
+	select {
+	case <-serverConns:
+		break
+	case <-time.After(time.Second):
+		t.Error("expected the pool to replace the dead connection with a new one")
+	}
+}
+
+// TestClientPoolConfigs Checks out clients for the same remote address with
+// different LocalAddress settings, which must not share connections
+func TestClientPoolConfigs(t *testing.T) {
+	serverCfg := server.NewConfig("127.0.0.1", 8414)
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer stopAndWait(s)
+
+	pool := client.NewPool(client.NewPoolConfig())
+	defer func() {
+		_ = pool.Close()
+	}()
+
+	clientCfg := client.NewConfig("127.0.0.1", 8414)
+	c1, err := pool.Get(clientCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_ = c1.Stop()
+
+	clientCfg.LocalAddress = "127.0.0.2"
+	c2, err := pool.Get(clientCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = c2.Stop()
+	}()
+
+	if pool.IdleCount() != 1 || pool.ConnectionCount() != 2 {
+		t.Errorf("expected a new connection for the other LocalAddress, have %d idle of %d", pool.IdleCount(), pool.ConnectionCount())
+	}
+	if host, _, _ := net.SplitHostPort(c2.LocalAddr().String()); host != "127.0.0.2" {
+		t.Errorf("expected the client to connect from 127.0.0.2, have %s", c2.LocalAddr())
+	}
+}
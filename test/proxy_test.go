@@ -0,0 +1,215 @@
+package test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+	"tonysoft.com/comm/internal/proxyproto"
+	"tonysoft.com/comm/pkg/client"
+	"tonysoft.com/comm/pkg/comerr"
+	"tonysoft.com/comm/pkg/server"
+)
+
+func TestTcpServerProxyProtocol(t *testing.T) {
+	serverCfg := server.NewConfig(net.IPv4zero.String(), 8394)
+	serverCfg.ProxyProtocol = true
+	serverCfg.ProxyHeaderTimeoutMs = 200
+	serverCfg.TrustedProxies = []string{"127.0.0.0/8"}
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer s.Stop()
+
+	// Both header versions emitted by the client surface the real client address
+	for _, version := range []int{1, 2} {
+		clientCfg := client.NewConfig(net.IPv4zero.String(), 8394)
+		clientCfg.ProxyProtocolVersion = version
+		clientCfg.ProxySourceAddress = "203.0.113.7:4242"
+		c, e := client.New(clientCfg)
+		if e != nil {
+			t.Error(e)
+			return
+		}
+		e = c.Start()
+		if e != nil {
+			t.Error(e)
+			return
+		}
+		_, _ = c.Write([]byte("hello"))
+
+		var conn server.Connection
+		select {
+		case conn = <-s.Accept():
+		case <-time.After(time.Second):
+			t.Errorf("v%d: expected a connection", version)
+			_ = c.Stop()
+			return
+		}
+
+		if conn.RemoteAddress() != clientCfg.ProxySourceAddress {
+			t.Errorf("v%d: expected remote address %s, have %s", version, clientCfg.ProxySourceAddress, conn.RemoteAddress())
+		}
+
+		buffer := make([]byte, 16)
+		count, e := conn.Read(buffer)
+		if e != nil || string(buffer[:count]) != "hello" {
+			t.Errorf("v%d: expected to read 'hello' after the header, read '%s' (%v)", version, string(buffer[:count]), e)
+		}
+
+		_ = c.Stop()
+	}
+
+	// TLVs are surfaced along with the header
+	header := &proxyproto.Header{
+		Version:     2,
+		Command:     proxyproto.Proxy,
+		Source:      &net.TCPAddr{IP: net.ParseIP("198.51.100.1"), Port: 1000},
+		Destination: &net.TCPAddr{IP: net.ParseIP("198.51.100.2"), Port: 443},
+		TLVs:        []proxyproto.TLV{{Type: 0x02, Value: []byte("example.com")}},
+	}
+	headerBytes, err := header.Bytes()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	rawConn, err := net.Dial("tcp4", "127.0.0.1:8394")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = rawConn.Close()
+	}()
+	_, _ = rawConn.Write(headerBytes)
+
+	select {
+	case conn := <-s.Accept():
+		proxyHeader := conn.(server.Proxied).ProxyHeader()
+		if authority, ok := proxyHeader.TLV(0x02); !ok || string(authority) != "example.com" {
+			t.Errorf("expected the authority TLV 'example.com', have '%s'", string(authority))
+		}
+		if conn.LocalAddr().String() != "198.51.100.2:443" {
+			t.Errorf("expected local address 198.51.100.2:443, have %s", conn.LocalAddr())
+		}
+	case <-time.After(time.Second):
+		t.Error("expected a connection")
+		return
+	}
+
+	// Connections without a header are rejected once the header times out
+	plainClient, err := client.New(client.NewConfig(net.IPv4zero.String(), 8394))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_ = plainClient.Start()
+	defer func() {
+		_ = plainClient.Stop()
+	}()
+
+	select {
+	case e := <-s.Errors():
+		if !errors.Is(e, comerr.ErrInvalidProxyHeader) {
+			t.Errorf("expected ErrInvalidProxyHeader, have %v", e)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected the connection without a header to be rejected")
+	}
+}
+
+func TestTcpServerUntrustedProxy(t *testing.T) {
+	serverCfg := server.NewConfig(net.IPv4zero.String(), 8395)
+	serverCfg.ProxyProtocol = true
+	serverCfg.TrustedProxies = []string{"10.0.0.1"}
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer s.Stop()
+
+	clientCfg := client.NewConfig(net.IPv4zero.String(), 8395)
+	clientCfg.ProxyProtocolVersion = 2
+	c, err := client.New(clientCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_ = c.Start()
+	defer func() {
+		_ = c.Stop()
+	}()
+
+	select {
+	case e := <-s.Errors():
+		if !errors.Is(e, comerr.ErrUntrustedProxy) {
+			t.Errorf("expected ErrUntrustedProxy, have %v", e)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected the connection from an untrusted proxy to be rejected")
+	}
+
+	if s.ClientCount() != 0 {
+		t.Errorf("expected no connected clients, have %d", s.ClientCount())
+	}
+}
+
+func TestTcpServerProxyProtocolRequiresTrustedProxies(t *testing.T) {
+	serverCfg := server.NewConfig(net.IPv4zero.String(), 8395)
+	serverCfg.ProxyProtocol = true
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if !errors.Is(err, comerr.ErrTrustedProxiesRequired) {
+		t.Errorf("expected ErrTrustedProxiesRequired, have %v", err)
+		stopAndWait(s)
+	}
+}
+
+// TestProxyHeaderFamilies Reads version 2 headers of the UDP and UNIX
+// families, whose addresses are skipped rather than read as TLVs
+func TestProxyHeaderFamilies(t *testing.T) {
+	signature := []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+	authority := []byte{0x02, 0x00, 0x0B, 'e', 'x', 'a', 'm', 'p', 'l', 'e', '.', 'c', 'o', 'm'}
+
+	for family, addrLength := range map[byte]int{0x12: 12, 0x22: 36, 0x31: 216, 0x32: 216} {
+		header := append(append([]byte{}, signature...), 0x21, family)
+		header = binary.BigEndian.AppendUint16(header, uint16(addrLength+len(authority)))
+		header = append(header, bytes.Repeat([]byte{0xFF}, addrLength)...)
+		header = append(header, authority...)
+
+		h, err := proxyproto.Read(bytes.NewReader(header))
+		if err != nil {
+			t.Errorf("family 0x%02X: %v", family, err)
+			continue
+		}
+		if h.Source != nil || h.Destination != nil {
+			t.Errorf("family 0x%02X: expected no TCP addresses, have %v and %v", family, h.Source, h.Destination)
+		}
+		if value, ok := h.TLV(0x02); !ok || string(value) != "example.com" || len(h.TLVs) != 1 {
+			t.Errorf("family 0x%02X: expected the authority TLV 'example.com' alone, have %v", family, h.TLVs)
+		}
+	}
+}
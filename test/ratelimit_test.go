@@ -0,0 +1,337 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+	_ratelimit "tonysoft.com/comm/internal/ratelimit"
+	"tonysoft.com/comm/pkg/client"
+	"tonysoft.com/comm/pkg/comerr"
+	"tonysoft.com/comm/pkg/node"
+	"tonysoft.com/comm/pkg/ratelimit"
+	"tonysoft.com/comm/pkg/server"
+)
+
+func TestServerConnectionRateLimit(t *testing.T) {
+	const chunkSize = 500
+	const chunkCount = 8
+
+	limit := ratelimit.Limit{BytesPerSec: 10000, BytesBurst: 1000}
+
+	serverCfg := server.NewConfig(net.IPv4zero.String(), 8393)
+	serverCfg.ConnectionWriteLimit = limit
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer s.Stop()
+
+	c, err := client.New(client.NewConfig(net.IPv4zero.String(), 8393))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	err = c.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = c.Stop()
+	}()
+
+	conn := <-s.Accept()
+	chunk := GetRandomString(chunkSize)
+
+	// Blocking: the writes beyond the burst are spread out as per the rate
+	startTime := time.Now()
+	for i := 0; i < chunkCount; i++ {
+		_, err = conn.Write(chunk)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	minElapsed := time.Duration(float64(chunkSize*chunkCount-limit.BytesBurst) / limit.BytesPerSec * 0.9 * float64(time.Second))
+	if elapsed := time.Since(startTime); elapsed < minElapsed {
+		t.Errorf("expected writing to take at least %v, took %v", minElapsed, elapsed)
+		return
+	}
+
+	// Rejecting: the writes beyond the burst fail
+	limit.Mode = ratelimit.Reject
+	conn.(server.RateLimiter).SetWriteLimit(limit)
+
+	var rejected int
+	for i := 0; i < chunkCount; i++ {
+		_, err = conn.Write(chunk)
+		if errors.Is(err, comerr.ErrRateLimited) {
+			rejected++
+		} else if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	if expected := chunkCount - limit.BytesBurst/chunkSize; rejected != expected {
+		t.Errorf("expected %d rejected writes, have %d", expected, rejected)
+	}
+
+	// The connection is no longer limited
+	conn.(server.RateLimiter).SetWriteLimit(ratelimit.Limit{})
+	_, err = conn.Write(chunk)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNodePeerSendLimit(t *testing.T) {
+	const burst = 2
+
+	cfg1 := node.NewConfig(":9007")
+	cfg1.PeerSendLimit = ratelimit.Limit{MessagesPerSec: 1, MessagesBurst: burst, Mode: ratelimit.Reject}
+	cfg2 := node.NewConfig(":9008")
+
+	n1, err := node.New[string](cfg1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	n2, err := node.New[string](cfg2)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = n1.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer n1.Stop()
+
+	err = n2.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer n2.Stop()
+
+	data := "hello"
+	for i := 0; i < burst; i++ {
+		_, err = n1.Send(n2.Config().Address, &data)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	_, err = n1.Send(n2.Config().Address, &data)
+	if !errors.Is(err, comerr.ErrRateLimited) {
+		t.Errorf("expected ErrRateLimited, have %v", err)
+		return
+	}
+
+	n1.SetPeerSendLimit(n2.Config().Address, ratelimit.Limit{})
+	_, err = n1.Send(n2.Config().Address, &data)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// TestLimiterCancelledAcquire Cancels a send waiting for bytes, which must
+// give back the message it took
+func TestLimiterCancelledAcquire(t *testing.T) {
+	limiter := _ratelimit.NewLimiter(ratelimit.Limit{BytesPerSec: 100, BytesBurst: 100, MessagesPerSec: 1, MessagesBurst: 1})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := limiter.Acquire(ctx, 1000); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, have %v", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := limiter.Acquire(ctx, 1); err != nil {
+		t.Errorf("expected the message to be available again, have %v", err)
+	}
+}
+
+func TestLimiterRefilled(t *testing.T) {
+	limiter := _ratelimit.NewLimiter(ratelimit.Limit{MessagesPerSec: 20, MessagesBurst: 1})
+
+	if err := limiter.Acquire(context.Background(), 1); err != nil {
+		t.Error(err)
+		return
+	}
+	if limiter.Refilled() {
+		t.Error("expected the limiter not to be refilled right after Acquire")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if !limiter.Refilled() {
+		t.Error("expected the limiter to be refilled once idle for longer than its refill interval")
+	}
+}
+
+// TestLimitersRejected Rejects a write on the second of two limiters, which
+// must give back what the first one took
+func TestLimitersRejected(t *testing.T) {
+	connection := _ratelimit.NewLimiter(ratelimit.Limit{MessagesPerSec: 0.1, MessagesBurst: 2, Mode: ratelimit.Reject})
+	server := _ratelimit.NewLimiter(ratelimit.Limit{MessagesPerSec: 0.1, MessagesBurst: 1, Mode: ratelimit.Reject})
+	limiters := _ratelimit.Limiters{connection, server}
+
+	if err := limiters.Acquire(context.Background(), 1); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := limiters.Acquire(context.Background(), 1); !errors.Is(err, comerr.ErrRateLimited) {
+		t.Errorf("expected ErrRateLimited, have %v", err)
+		return
+	}
+
+	if err := connection.Acquire(context.Background(), 1); err != nil {
+		t.Errorf("expected the connection's message to be available again, have %v", err)
+	}
+}
+
+// TestNodePeerSendLimitStop Stops a node while a send waits for the peer's
+// limit, which must release the send
+func TestNodePeerSendLimitStop(t *testing.T) {
+	cfg1 := node.NewConfig(":9015")
+	cfg1.PeerSendLimit = ratelimit.Limit{MessagesPerSec: 0.1, MessagesBurst: 1, Mode: ratelimit.Block}
+	cfg2 := node.NewConfig(":9016")
+
+	n1, err := node.New[string](cfg1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	n2, err := node.New[string](cfg2)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = n1.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer n1.Stop()
+
+	err = n2.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer n2.Stop()
+
+	data := "hello"
+	_, err = n1.Send(n2.Config().Address, &data)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// The second send waits 10s for the limit
+	sendErrChan := make(chan error, 1)
+	go func() {
+		_, e := n1.Send(n2.Config().Address, &data)
+		sendErrChan <- e
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	n1.Stop()
+
+	select {
+	case err = <-sendErrChan:
+		if err == nil {
+			t.Error("expected the blocked send to fail")
+		}
+	case <-time.After(time.Second):
+		t.Error("expected stopping the node to release the blocked send")
+	}
+}
+
+// TestNodePeerSendLimitReconnect Disconnects and reconnects a peer, which
+// must not reset its limit, while overrides set by SetPeerSendLimit are kept
+func TestNodePeerSendLimitReconnect(t *testing.T) {
+	// Connections to stopped peers are closed once idle
+	cfg1 := node.NewConfig(":9017")
+	cfg1.PeerSendLimit = ratelimit.Limit{MessagesPerSec: 0.1, MessagesBurst: 1, Mode: ratelimit.Reject}
+	cfg1.IdleConnectionTimeoutMs = 200
+	cfg2 := node.NewConfig(":9018")
+
+	n1, err := node.New[string](cfg1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	n2, err := node.New[string](cfg2)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = n1.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer n1.Stop()
+
+	// Sends messageCount messages to n2, then waits for n1 to disconnect from it
+	data := "hello"
+	sendThenDisconnect := func(messageCount int) error {
+		if e := n2.Start(); e != nil {
+			return e
+		}
+		defer func() {
+			n2.Stop()
+			for i := 0; i < 100 && n1.ConnectionCount() > 0; i++ {
+				time.Sleep(20 * time.Millisecond)
+			}
+		}()
+
+		for i := 0; i < messageCount; i++ {
+			if _, e := n1.Send(n2.Config().Address, &data); e != nil {
+				return e
+			}
+		}
+		return nil
+	}
+
+	if err = sendThenDisconnect(1); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// The limiter outlives the connection until it refills
+	if err = sendThenDisconnect(1); !errors.Is(err, comerr.ErrRateLimited) {
+		t.Errorf("expected ErrRateLimited once reconnected, have %v", err)
+		return
+	}
+
+	n1.SetPeerSendLimit(n2.Config().Address, ratelimit.Limit{})
+	if err = sendThenDisconnect(2); err != nil {
+		t.Error(err)
+		return
+	}
+	if err = sendThenDisconnect(2); err != nil {
+		t.Errorf("expected the override to be kept, have %v", err)
+	}
+}
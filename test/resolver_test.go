@@ -0,0 +1,217 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+	"tonysoft.com/comm/pkg/client"
+	"tonysoft.com/comm/pkg/comerr"
+	"tonysoft.com/comm/pkg/node"
+	"tonysoft.com/comm/pkg/resolver"
+	"tonysoft.com/comm/pkg/server"
+)
+
+// countingResolver Counts the lookups that reach the wrapped resolver
+type countingResolver struct {
+	resolver.Resolver
+	count atomic.Int32
+}
+
+func (r *countingResolver) LookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	r.count.Add(1)
+	return r.Resolver.LookupIP(ctx, host)
+}
+
+func TestClientHostnameFailover(t *testing.T) {
+	serverCfg := server.NewConfig("127.0.0.1", 8406)
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer stopAndWait(s)
+
+	go echoOnce(s)
+
+	// Nothing listens on the first address, so the client fails over to the second
+	counter := &countingResolver{Resolver: resolver.Static{
+		"comm.test": {net.ParseIP("127.0.0.2"), net.ParseIP("127.0.0.1")},
+	}}
+
+	clientCfg := client.NewConfig("comm.test", 8406)
+	clientCfg.ResolveHostnames = true
+	clientCfg.Resolver = resolver.NewCaching(counter, time.Minute)
+	c, err := client.New(clientCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = c.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if c.RemoteAddr().String() != "127.0.0.1:8406" {
+		t.Errorf("expected to connect to 127.0.0.1:8406, have %s", c.RemoteAddr())
+	}
+
+	_, err = c.Write([]byte("hello"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	buffer := make([]byte, 16)
+	count, err := c.Read(buffer)
+	if err != nil || string(buffer[:count]) != "hello" {
+		t.Errorf("expected 'hello', have '%s' (%v)", string(buffer[:count]), err)
+	}
+	_ = c.Stop()
+
+	// The addresses are cached until none of them answers
+	go func() {
+		<-s.Accept()
+	}()
+	err = c.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_ = c.Stop()
+
+	if counter.count.Load() != 1 {
+		t.Errorf("expected 1 lookup, have %d", counter.count.Load())
+	}
+
+	stopAndWait(s)
+
+	err = c.Start()
+	if err == nil {
+		t.Error("expected the connection to be refused")
+		_ = c.Stop()
+	}
+
+	// Having failed with the cached addresses, the client looks them up again
+	err = c.Start()
+	if err == nil {
+		_ = c.Stop()
+	}
+
+	if counter.count.Load() != 2 {
+		t.Errorf("expected 2 lookups, have %d", counter.count.Load())
+	}
+}
+
+func TestHostnameRequiresOptIn(t *testing.T) {
+	_, err := client.New(client.NewConfig("comm.test", 8406))
+	if !errors.Is(err, comerr.ErrAddressFormatUnknown) {
+		t.Errorf("expected ErrAddressFormatUnknown, have %v", err)
+	}
+
+	// Unknown hostnames fail when starting
+	clientCfg := client.NewConfig("unknown.test", 8406)
+	clientCfg.ResolveHostnames = true
+	clientCfg.Resolver = resolver.Static{}
+	c, err := client.New(clientCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = c.Start()
+	if !errors.Is(err, comerr.ErrResolveHostname) {
+		t.Errorf("expected ErrResolveHostname, have %v", err)
+		_ = c.Stop()
+	}
+}
+
+func TestServerHostnameBinding(t *testing.T) {
+	serverCfg := server.NewConfig("comm.test", 8406)
+	serverCfg.ResolveHostnames = true
+	serverCfg.Resolver = resolver.Static{
+		"comm.test": {net.ParseIP("::1"), net.ParseIP("127.0.0.1")},
+	}
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer stopAndWait(s)
+
+	if s.Addr().String() != "127.0.0.1:8406" {
+		t.Errorf("expected to bind to 127.0.0.1:8406, have %s", s.Addr())
+	}
+}
+
+func TestNodeHostnames(t *testing.T) {
+	hosts := resolver.Static{
+		"node1.test": {net.ParseIP("127.0.0.1")},
+		"node2.test": {net.ParseIP("127.0.0.1")},
+	}
+
+	cfg1 := node.NewConfig("node1.test:9009")
+	cfg1.ResolveHostnames = true
+	cfg1.Resolver = hosts
+	cfg2 := node.NewConfig("node2.test:9010")
+	cfg2.ResolveHostnames = true
+	cfg2.Resolver = hosts
+
+	n1, err := node.New[string](cfg1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	n2, err := node.New[string](cfg2)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = n1.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer n1.Stop()
+
+	err = n2.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer n2.Stop()
+
+	payload := "hello"
+	msg, err := n1.Send("node2.test:9010", &payload)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	select {
+	case msgCopy := <-n2.Recv():
+		if msgCopy.ID() != msg.ID() {
+			t.Errorf("msgCopy.ID (%d) != msg.ID (%d)", msgCopy.ID(), msg.ID())
+		}
+	case <-time.After(time.Second):
+		t.Error("expected a message")
+	}
+}
@@ -0,0 +1,151 @@
+package test
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	"tonysoft.com/comm/pkg/server"
+)
+
+func TestTcpServerListenerShards(t *testing.T) {
+	const shardCount = 4
+	const clientCount = 40
+
+	serverCfg := server.NewConfig(net.IPv4zero.String(), 8400)
+	serverCfg.ListenerShards = shardCount
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if count := boundSocketCount(t, "/proc/net/tcp", 8400, "0A"); count != shardCount {
+		t.Errorf("expected %d listeners, have %d", shardCount, count)
+	}
+
+	for i := 0; i < clientCount; i++ {
+		conn, e := net.Dial("tcp4", "127.0.0.1:8400")
+		if e != nil {
+			t.Error(e)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+	}
+
+	// Every shard feeds the same Accept() channel
+	for i := 0; i < clientCount; i++ {
+		select {
+		case <-s.Accept():
+		case <-time.After(time.Second):
+			t.Errorf("expected %d connections, have %d", clientCount, i)
+			s.Stop()
+			return
+		}
+	}
+
+	stopAndWait(s)
+
+	// Binding without SO_REUSEPORT fails unless every shard is closed
+	listener, err := net.Listen("tcp4", ":8400")
+	if err != nil {
+		t.Errorf("expected every shard to be closed: %v", err)
+		return
+	}
+	_ = listener.Close()
+}
+
+func TestUdpServerListenerShards(t *testing.T) {
+	const shardCount = 4
+	const clientCount = 40
+
+	serverCfg := server.NewConfig(net.IPv4zero.String(), 8401, useConnectionless)
+	serverCfg.ListenerShards = shardCount
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if count := boundSocketCount(t, "/proc/net/udp", 8401, "07"); count != shardCount {
+		t.Errorf("expected %d sockets, have %d", shardCount, count)
+	}
+
+	for i := 0; i < clientCount; i++ {
+		conn, e := net.Dial("udp4", "127.0.0.1:8401")
+		if e != nil {
+			t.Error(e)
+			return
+		}
+		_, _ = conn.Write([]byte("hello"))
+		_ = conn.Close()
+	}
+
+	for i := 0; i < clientCount; i++ {
+		select {
+		case <-s.Accept():
+		case <-time.After(time.Second):
+			t.Errorf("expected %d datagrams, have %d", clientCount, i)
+			s.Stop()
+			return
+		}
+	}
+
+	stopAndWait(s)
+
+	conn, err := net.ListenPacket("udp4", ":8401")
+	if err != nil {
+		t.Errorf("expected every shard to be closed: %v", err)
+		return
+	}
+	_ = conn.Close()
+}
+
+func stopAndWait(s server.Server) {
+	s.Stop()
+	for s.IsRunning() {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// boundSocketCount Counts the sockets bound to the port in the given state,
+// as listed in /proc/net/tcp or /proc/net/udp
+func boundSocketCount(t *testing.T, path string, port uint16, state string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	suffix := fmt.Sprintf(":%04X", port)
+	count := 0
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 3 && strings.HasSuffix(fields[1], suffix) && fields[3] == state {
+			count++
+		}
+	}
+
+	return count
+}
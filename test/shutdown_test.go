@@ -0,0 +1,257 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+	"tonysoft.com/comm/pkg/client"
+	"tonysoft.com/comm/pkg/comerr"
+	"tonysoft.com/comm/pkg/node"
+	"tonysoft.com/comm/pkg/server"
+)
+
+func TestServerShutdown(t *testing.T) {
+	// Responds after a delay, simulating a request in progress during shutdown
+	slowEcho := server.HandlerFunc(func(ctx context.Context, c server.Connection) {
+		buffer := make([]byte, 1024)
+		for {
+			count, e := c.ReadContext(ctx, buffer)
+			if e != nil {
+				return
+			}
+			if count < 1 {
+				continue
+			}
+			time.Sleep(300 * time.Millisecond)
+			_, _ = c.Write(buffer[:count])
+			return
+		}
+	})
+
+	serverCfg := server.NewConfig(net.IPv4zero.String(), 8383)
+	serverCfg.ReadTimeoutUs = 100000
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	go func() {
+		_ = s.Serve(slowEcho)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	clientCfg := client.NewConfig(net.IPv4zero.String(), 8383)
+	clientCfg.ReadTimeoutUs = 2000000
+	c, err := client.New(clientCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = c.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = c.Stop()
+	}()
+
+	_, err = c.Write([]byte("ping"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	shutdownErrChan := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		shutdownErrChan <- s.Shutdown(ctx)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	if !s.IsRunning() || !s.IsStopping() {
+		t.Error("expected the server to be running and stopping during shutdown")
+		return
+	}
+
+	// New connections are refused once shutdown begins
+	c2, err := client.New(clientCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if err = c2.Start(); err == nil {
+		_ = c2.Stop()
+		t.Error("expected the connection attempt to fail during shutdown")
+		return
+	}
+
+	// The in-flight request completes
+	buffer := make([]byte, 4)
+	count, err := c.Read(buffer)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if string(buffer[:count]) != "ping" {
+		t.Errorf("expected to receive 'ping', received '%s' instead", string(buffer[:count]))
+		return
+	}
+
+	select {
+	case err = <-shutdownErrChan:
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	case <-time.After(3 * time.Second):
+		t.Error("expected Shutdown() to return")
+		return
+	}
+
+	if s.IsRunning() || s.IsStopping() {
+		t.Error("expected the server to be stopped")
+	}
+}
+
+func TestServerShutdownDeadline(t *testing.T) {
+	serverCfg := server.NewConfig(net.IPv4zero.String(), 8384)
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	c, err := client.New(client.NewConfig(net.IPv4zero.String(), 8384))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = c.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = c.Stop()
+	}()
+
+	// The accepted connection is never closed, so it must be forcibly closed
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	startTime := time.Now()
+	err = s.Shutdown(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, have %v", err)
+		return
+	}
+	if elapsed := time.Since(startTime); elapsed > time.Second {
+		t.Errorf("expected Shutdown() to return within 1s, took %v", elapsed)
+		return
+	}
+
+	if s.IsRunning() || s.ClientCount() != 0 {
+		t.Errorf("expected the server to be stopped with 0 clients, have %d", s.ClientCount())
+	}
+}
+
+func TestNodeShutdown(t *testing.T) {
+	cfg1 := node.NewConfig(":9003")
+	cfg2 := node.NewConfig(":9004")
+	cfg2.RecvChanBufferSize = 0
+
+	n1, err := node.New[string](cfg1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	n2, err := node.New[string](cfg2)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = n1.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer n1.Stop()
+
+	err = n2.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer n2.Stop()
+
+	data := "hello"
+	msg, err := n1.Send(n2.Config().Address, &data)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	// The message is held in delivery until it is read from Recv()
+	shutdownErrChan := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		shutdownErrChan <- n2.Shutdown(ctx)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	if !n2.IsStopping() {
+		t.Error("expected the node to be stopping")
+		return
+	}
+
+	_, err = n2.Send(n1.Config().Address, &data)
+	if !errors.Is(err, comerr.ErrNodeStopping) {
+		t.Errorf("expected ErrNodeStopping, have %v", err)
+		return
+	}
+
+	msgCopy := <-n2.Recv()
+	if msgCopy == nil || msgCopy.ID() != msg.ID() {
+		t.Error("expected the in-flight message to be delivered")
+		return
+	}
+
+	select {
+	case err = <-shutdownErrChan:
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	case <-time.After(3 * time.Second):
+		t.Error("expected Shutdown() to return")
+		return
+	}
+
+	if n2.IsRunning() {
+		t.Error("expected the node to be stopped")
+	}
+}
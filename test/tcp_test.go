@@ -1,8 +1,10 @@
 package test
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"runtime"
 	"sync"
@@ -175,6 +177,193 @@ func TestTcpServerReadWrite(t *testing.T) {
 	}
 }
 
+func TestTcpClientContext(t *testing.T) {
+	serverCfg := server.NewConfig(net.IPv4zero.String(), 8376)
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer s.Stop()
+
+	clientCfg := client.NewConfig(net.IPv4zero.String(), 8376)
+	clientCfg.ReadTimeoutUs = 10000000
+	c, err := client.New(clientCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = c.StartContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected StartContext() to return context.Canceled, have %v", err)
+		return
+	}
+
+	err = c.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		e := c.Stop()
+		if e != nil {
+			t.Error(e)
+		}
+	}()
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	startTime := time.Now()
+	buffer := make([]byte, 4)
+	_, err = c.ReadContext(ctx, buffer)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected ReadContext() to return context.DeadlineExceeded, have %v", err)
+		return
+	}
+	if elapsed := time.Since(startTime); elapsed > time.Second {
+		t.Errorf("expected ReadContext() to abort within 1s, took %v", elapsed)
+		return
+	}
+
+	if !c.IsConnected() {
+		t.Error("expected the client to remain connected after the read was aborted")
+		return
+	}
+
+	count, err := c.WriteContext(context.Background(), []byte("ping"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if count != 4 {
+		t.Errorf("expected to write %d bytes, wrote %d instead", 4, count)
+	}
+}
+
+func TestTcpClientReconnect(t *testing.T) {
+	serverCfg := server.NewConfig(net.IPv4zero.String(), 8377)
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	go func() {
+		connCount := 0
+		for conn := range s.Accept() {
+			connCount++
+			go func(c server.Connection, dropConnection bool) {
+				buffer := make([]byte, serverCfg.ReadBufferSize)
+				for {
+					count, e := c.Read(buffer)
+					if e != nil {
+						return
+					}
+					if count < 1 {
+						continue
+					}
+					if dropConnection {
+						_ = c.Close()
+						return
+					}
+					_, _ = c.Write(buffer[:count])
+				}
+			}(conn, connCount == 1)
+		}
+	}()
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer s.Stop()
+
+	clientCfg := client.NewConfig(net.IPv4zero.String(), 8377)
+	clientCfg.ReadTimeoutUs = 100000
+	clientCfg.AutoReconnect = true
+	clientCfg.ReconnectMinBackoffMs = 50
+	clientCfg.ReconnectBufferWrites = true
+	c, err := client.New(clientCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = c.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		e := c.Stop()
+		if e != nil {
+			t.Error(e)
+		}
+	}()
+
+	events := c.Events()
+	if evt := <-events; evt.Type != client.Connected {
+		t.Errorf("expected the first event to be Connected, have %v", evt.Type)
+		return
+	}
+
+	// The server drops the first connection as soon as it receives data
+	_, err = c.Write([]byte("ping"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	reconnected := false
+	timeout := time.After(5 * time.Second)
+	buffer := make([]byte, 4)
+	for !reconnected {
+		_, err = c.Read(buffer)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		select {
+		case evt := <-events:
+			reconnected = evt.Type == client.Reconnected
+		case <-timeout:
+			t.Error("timed out waiting for the client to reconnect")
+			return
+		default:
+		}
+	}
+
+	_, err = c.Write([]byte("pong"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	count, err := c.Read(buffer)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if string(buffer[:count]) != "pong" {
+		t.Errorf("expected to receive 'pong', received '%s' instead", string(buffer[:count]))
+	}
+}
+
 func TestTcpServerMultiClient(t *testing.T) {
 	const testCount = 5
 	const clientCount = 50
@@ -459,3 +648,43 @@ func TestTcpServerMultiClientUsingStringStream(t *testing.T) {
 		t.Errorf("unexpected thread count (expected <=%d, have %d)", startingRoutineCount, finishingRoutineCount)
 	}
 }
+
+// TestTcpServerConnectionLimitCloses Connects beyond ClientConnectionLimit,
+// which must close the refused connection rather than leave it open
+func TestTcpServerConnectionLimitCloses(t *testing.T) {
+	serverCfg := server.NewConfig("127.0.0.1", 8413)
+	serverCfg.ClientConnectionLimit = 1
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer stopAndWait(s)
+
+	accepted, err := net.Dial("tcp", "127.0.0.1:8413")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer accepted.Close()
+	<-s.Accept()
+
+	refused, err := net.Dial("tcp", "127.0.0.1:8413")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer refused.Close()
+
+	_ = refused.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err = refused.Read(make([]byte, 16))
+	if !errors.Is(err, io.EOF) && !errors.Is(err, syscall.ECONNRESET) {
+		t.Errorf("expected the refused connection to be closed, have %v", err)
+	}
+}
@@ -34,4 +34,19 @@ func TestGetTransportTypeFromAddress(t *testing.T) {
 	if tt, err := transport.GetTypeFromAddress(address); tt != transport.RFCOMM || err != nil {
 		failTest(5)
 	}
+
+	address = "239.255.0.1"
+	if tt, err := transport.GetTypeFromAddress(address); tt != transport.UDP || err != nil {
+		failTest(6)
+	}
+
+	address = "224.0.0.251:5353"
+	if tt, err := transport.GetTypeFromAddress(address); tt != transport.UDP || err != nil {
+		failTest(7)
+	}
+
+	address = "255.255.255.255:8387"
+	if tt, err := transport.GetTypeFromAddress(address); tt != transport.UDP || err != nil {
+		failTest(8)
+	}
 }
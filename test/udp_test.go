@@ -1,6 +1,7 @@
 package test
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -10,7 +11,9 @@ import (
 	"sync/atomic"
 	"testing"
 	"time"
+	"tonysoft.com/comm/internal/socket"
 	"tonysoft.com/comm/pkg/client"
+	"tonysoft.com/comm/pkg/comerr"
 	"tonysoft.com/comm/pkg/server"
 )
 
@@ -252,3 +255,493 @@ func TestUdpServerMultiClient(t *testing.T) {
 		t.Errorf("unexpected thread count (expected <=%d, have %d)", startingRoutineCount, finishingRoutineCount)
 	}
 }
+
+func TestUdpServerSessions(t *testing.T) {
+	serverCfg := server.NewConfig(net.IPv4zero.String(), 8386, useConnectionless)
+	serverCfg.UdpSessions = true
+	serverCfg.ClientConnectionLimit = 2
+	serverCfg.IdleConnectionTimeoutMs = 300
+	serverCfg.ReadTimeoutUs = 100000
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer s.Stop()
+
+	newClient := func() client.Client {
+		c, e := client.New(client.NewConfig(net.IPv4zero.String(), 8386, useConnectionless))
+		if e != nil {
+			t.Error(e)
+			return nil
+		}
+		e = c.Start()
+		if e != nil {
+			t.Error(e)
+			return nil
+		}
+		return c
+	}
+
+	clients := make([]client.Client, 3)
+	for i := range clients {
+		if clients[i] = newClient(); clients[i] == nil {
+			return
+		}
+		defer func(c client.Client) {
+			_ = c.Stop()
+		}(clients[i])
+	}
+
+	// Datagrams from the first two clients are grouped into one session each
+	for _, word := range []string{"a", "b", "c"} {
+		for _, c := range clients[:2] {
+			_, _ = c.Write([]byte(word))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The third client exceeds ClientConnectionLimit
+	_, _ = clients[2].Write([]byte("a"))
+	time.Sleep(100 * time.Millisecond)
+
+	if s.ClientCount() != 2 {
+		t.Errorf("expected 2 sessions, have %d", s.ClientCount())
+		return
+	}
+
+	select {
+	case e := <-s.Errors():
+		if !errors.Is(e, comerr.ErrConnectionLimitReached) {
+			t.Errorf("expected ErrConnectionLimitReached, have %v", e)
+			return
+		}
+	default:
+		t.Error("expected ErrConnectionLimitReached")
+		return
+	}
+
+	sessions := make([]server.Connection, 0)
+	for len(s.Accept()) > 0 {
+		sessions = append(sessions, <-s.Accept())
+	}
+	if len(sessions) != 2 {
+		t.Errorf("expected 2 accepted sessions, have %d", len(sessions))
+		return
+	}
+
+	for _, conn := range sessions {
+		var received []byte
+		buffer := make([]byte, 2)
+		for len(received) < 3 {
+			count, e := conn.Read(buffer)
+			if e != nil {
+				t.Error(e)
+				return
+			}
+			if count == 0 {
+				break
+			}
+			received = append(received, buffer[:count]...)
+		}
+
+		if string(received) != "abc" {
+			t.Errorf("expected to receive 'abc', received '%s' instead", string(received))
+			return
+		}
+
+		_, e := conn.Write([]byte("pong"))
+		if e != nil {
+			t.Error(e)
+			return
+		}
+	}
+
+	buffer := make([]byte, 4)
+	count, err := clients[0].Read(buffer)
+	if err != nil || string(buffer[:count]) != "pong" {
+		t.Errorf("expected to receive 'pong', received '%s' (%v)", string(buffer[:count]), err)
+		return
+	}
+
+	// Idle sessions are closed
+	time.Sleep(time.Second)
+
+	if s.ClientCount() != 0 {
+		t.Errorf("expected idle sessions to be closed, have %d", s.ClientCount())
+		return
+	}
+
+	_, err = sessions[0].Read(buffer)
+	if !errors.Is(err, net.ErrClosed) {
+		t.Errorf("expected net.ErrClosed, have %v", err)
+	}
+}
+
+func TestUdpServerSessionDrops(t *testing.T) {
+	serverCfg := server.NewConfig(net.IPv4zero.String(), 8410, useConnectionless)
+	serverCfg.UdpSessions = true
+	serverCfg.UdpSessionQueueSize = 2
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer stopAndWait(s)
+
+	c, err := client.New(client.NewConfig(net.IPv4zero.String(), 8410, useConnectionless))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = c.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = c.Stop()
+	}()
+
+	// The session is not read, so datagrams beyond the queue size are dropped
+	for i := 0; i < 5; i++ {
+		_, _ = c.Write([]byte("a"))
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if dropped := s.DroppedDatagrams(); dropped != 3 {
+		t.Errorf("expected 3 dropped datagrams, have %d", dropped)
+	}
+}
+
+// TestUdpServerSessionRestart Restarts the server right away, which must
+// not leave the idle session pruning of the previous run behind
+func TestUdpServerSessionRestart(t *testing.T) {
+	serverCfg := server.NewConfig(net.IPv4zero.String(), 8411, useConnectionless)
+	serverCfg.UdpSessions = true
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	time.Sleep(50 * time.Millisecond)
+	routineCount := runtime.NumGoroutine()
+
+	stopAndWait(s)
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer stopAndWait(s)
+	time.Sleep(50 * time.Millisecond)
+
+	if restartedCount := runtime.NumGoroutine(); restartedCount > routineCount {
+		t.Errorf("unexpected thread count (expected <=%d, have %d)", routineCount, restartedCount)
+	}
+}
+
+func TestUdpMulticast(t *testing.T) {
+	const group = "239.255.0.1"
+	announcement := []byte("device:1")
+
+	// Multicast addresses imply UDP, connectionless doesn't need to be specified
+	serverCfg := server.NewConfig(group, 8387)
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Skipf("multicast is not available: %v", err)
+		return
+	}
+	defer s.Stop()
+
+	clientCfg := client.NewConfig(group, 8387)
+	clientCfg.MulticastTTL = 1
+	clientCfg.MulticastLoopback = true
+	c, err := client.New(clientCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = c.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = c.Stop()
+	}()
+
+	_, err = c.Write(announcement)
+	if err != nil {
+		t.Skipf("multicast is not routable: %v", err)
+		return
+	}
+
+	select {
+	case conn := <-s.Accept():
+		buffer := make([]byte, 64)
+		count, e := conn.Read(buffer)
+		if e != nil {
+			t.Error(e)
+			return
+		}
+		if string(buffer[:count]) != string(announcement) {
+			t.Errorf("expected to receive '%s', received '%s' instead", string(announcement), string(buffer[:count]))
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("expected the server to receive the multicast datagram")
+	}
+}
+
+func TestUdpBatchReadWrite(t *testing.T) {
+	const datagramCount = 10
+
+	serverCfg := server.NewConfig(net.IPv4zero.String(), 8388, useConnectionless)
+	serverCfg.UdpBatchSize = 4
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer s.Stop()
+
+	c, err := client.New(client.NewConfig(net.IPv4zero.String(), 8388, useConnectionless))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = c.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = c.Stop()
+	}()
+
+	requests := make([][]byte, datagramCount)
+	for i := range requests {
+		requests[i] = []byte(fmt.Sprintf("request #%d", i))
+	}
+
+	count, err := c.(client.BatchWriter).WriteBatch(requests)
+	if err != nil || count != datagramCount {
+		t.Errorf("expected to write %d datagrams, wrote %d (%v)", datagramCount, count, err)
+		return
+	}
+
+	responses := make([]server.Datagram, 0, datagramCount)
+	for i := 0; i < datagramCount; i++ {
+		select {
+		case conn := <-s.Accept():
+			buffer := make([]byte, 64)
+			n, e := conn.Read(buffer)
+			if e != nil {
+				t.Error(e)
+				return
+			}
+			if string(buffer[:n]) != string(requests[i]) {
+				t.Errorf("expected to receive '%s', received '%s' instead", string(requests[i]), string(buffer[:n]))
+				return
+			}
+			responses = append(responses, server.Datagram{
+				Data: []byte(fmt.Sprintf("response #%d", i)),
+				Addr: conn.RemoteAddr().(*net.UDPAddr),
+			})
+		case <-time.After(time.Second):
+			t.Errorf("expected %d datagrams, received %d", datagramCount, i)
+			return
+		}
+	}
+
+	count, err = s.(server.BatchWriter).WriteBatch(responses)
+	if err != nil || count != datagramCount {
+		t.Errorf("expected to write %d datagrams, wrote %d (%v)", datagramCount, count, err)
+		return
+	}
+
+	for i := 0; i < datagramCount; i++ {
+		buffer := make([]byte, 64)
+		n, e := c.Read(buffer)
+		if e != nil {
+			t.Error(e)
+			return
+		}
+		if string(buffer[:n]) != string(responses[i].Data) {
+			t.Errorf("expected to receive '%s', received '%s' instead", string(responses[i].Data), string(buffer[:n]))
+			return
+		}
+	}
+}
+
+// BenchmarkUdpServerReceive Compares the receive path of a UDP server reading
+// one datagram per system call against one using recvmmsg, with the same
+// echo workload as TestUdpServerMultiClient (50 clients, 500 byte requests).
+func BenchmarkUdpServerReceive(b *testing.B) {
+	b.Run("ReadFrom", func(b *testing.B) {
+		benchmarkUdpServerReceive(b, 8389, 1, 1)
+	})
+	b.Run("Recvmmsg", func(b *testing.B) {
+		benchmarkUdpServerReceive(b, 8390, 32, 1)
+	})
+	b.Run("RecvmmsgShards", func(b *testing.B) {
+		benchmarkUdpServerReceive(b, 8402, 32, 4)
+	})
+}
+
+func benchmarkUdpServerReceive(b *testing.B, port uint16, batchSize int, shards int) {
+	const clientCount = 50
+	const requestLength = 500
+
+	serverCfg := server.NewConfig(net.IPv4zero.String(), port, useConnectionless)
+	serverCfg.UdpBatchSize = batchSize
+	serverCfg.ListenerShards = shards
+	s, err := server.New(serverCfg)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	err = s.Start()
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer func() {
+		_ = s.Shutdown(context.Background())
+	}()
+
+	go func() {
+		buffer := make([]byte, serverCfg.ReadBufferSize)
+		for conn := range s.Accept() {
+			if count, _ := conn.Read(buffer); count > 0 {
+				_, _ = conn.Write(buffer[:count])
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	clients := make([]client.Client, clientCount)
+	for i := range clients {
+		clientCfg := client.NewConfig(net.IPv4zero.String(), port, useConnectionless)
+		clientCfg.ReadTimeoutUs = 100000
+		c, e := client.New(clientCfg)
+		if e != nil {
+			b.Fatal(e)
+		}
+		if e = c.Start(); e != nil {
+			b.Fatal(e)
+		}
+		defer func() {
+			_ = c.Stop()
+		}()
+		clients[i] = c
+	}
+
+	request := GetRandomString(requestLength)
+	perClient := b.N/clientCount + 1
+
+	var echoed atomic.Int64
+	var wg sync.WaitGroup
+	wg.Add(clientCount)
+
+	b.SetBytes(requestLength)
+	b.ResetTimer()
+	startTime := time.Now()
+
+	for _, c := range clients {
+		go func(c client.Client) {
+			defer wg.Done()
+			buffer := make([]byte, requestLength)
+			for i := 0; i < perClient; i++ {
+				if _, e := c.Write(request); e != nil {
+					return
+				}
+				// A lost datagram only costs the read timeout
+				if count, _ := c.Read(buffer); count == requestLength {
+					echoed.Add(1)
+				}
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	elapsed := time.Since(startTime)
+	b.StopTimer()
+
+	b.ReportMetric(float64(echoed.Load())/elapsed.Seconds(), "datagrams/s")
+}
+
+func TestUdpBatchInvalidSize(t *testing.T) {
+	listener, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = listener.Close()
+	}()
+
+	for _, sizes := range [][2]int{{4, 0}, {0, 1500}} {
+		if _, err = socket.NewBatchConn(listener, sizes[0], sizes[1]); !errors.Is(err, comerr.ErrInvalidBatchSize) {
+			t.Errorf("expected ErrInvalidBatchSize for %v, have %v", sizes, err)
+		}
+	}
+
+	// The server reports the error rather than panicking
+	serverCfg := server.NewConfig(net.IPv4zero.String(), 8412, useConnectionless)
+	serverCfg.UdpBatchSize = 4
+	serverCfg.ReadBufferSize = 0
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer stopAndWait(s)
+
+	select {
+	case e := <-s.Errors():
+		if !errors.Is(e, comerr.ErrInvalidBatchSize) {
+			t.Errorf("expected ErrInvalidBatchSize, have %v", e)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected ErrInvalidBatchSize")
+	}
+}
@@ -0,0 +1,347 @@
+package test
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+	"tonysoft.com/comm/internal/transport"
+	"tonysoft.com/comm/pkg/client"
+	"tonysoft.com/comm/pkg/comerr"
+	"tonysoft.com/comm/pkg/node"
+	"tonysoft.com/comm/pkg/server"
+)
+
+func TestWebSocketAddress(t *testing.T) {
+	if tt, err := transport.GetTypeFromAddress("ws://127.0.0.1:8408/comm"); tt != transport.WebSocket || err != nil {
+		t.Errorf("expected WebSocket, have %d (%v)", tt, err)
+	}
+
+	if tt, err := transport.GetTypeFromAddress("wss://nodes.company.com/comm"); tt != transport.WebSocket || err != nil {
+		t.Errorf("expected WebSocket, have %d (%v)", tt, err)
+	}
+
+	wsURL, err := transport.ParseWebSocketAddress("wss://nodes.company.com/comm?v=1", 0)
+	if err != nil || !wsURL.Secure || wsURL.Port != 443 || wsURL.Path != "/comm?v=1" {
+		t.Errorf("unexpected result %+v (%v)", wsURL, err)
+	}
+
+	if wsURL.WithHostPort("10.0.0.2", 9000).String() != "wss://10.0.0.2:9000/comm?v=1" {
+		t.Errorf("unexpected address %s", wsURL.WithHostPort("10.0.0.2", 9000))
+	}
+}
+
+func TestWebSocketClientServer(t *testing.T) {
+	s, err := server.New(server.NewConfig("ws://127.0.0.1/comm", 8408))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer stopAndWait(s)
+
+	go echoOnce(s)
+
+	c, err := client.New(client.NewConfig("ws://127.0.0.1:8408/comm", 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = c.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = c.Write([]byte("hello"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	buffer := make([]byte, 16)
+	count, err := c.Read(buffer)
+	if err != nil || string(buffer[:count]) != "hello" {
+		t.Errorf("expected 'hello', have '%s' (%v)", string(buffer[:count]), err)
+	}
+	_ = c.Stop()
+
+	// Other paths are not upgraded
+	badClient, err := client.New(client.NewConfig("ws://127.0.0.1:8408/other", 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = badClient.Start()
+	if !errors.Is(err, comerr.ErrWebSocketHandshake) {
+		t.Errorf("expected ErrWebSocketHandshake, have %v", err)
+		_ = badClient.Stop()
+	}
+}
+
+// TestWebSocketBrowserFrames Sends what a browser might: a ping, then a text
+// message fragmented across two frames, all masked
+func TestWebSocketBrowserFrames(t *testing.T) {
+	s, err := server.New(server.NewConfig("ws://127.0.0.1/comm", 8408))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer stopAndWait(s)
+
+	conn, err := net.DialTimeout("tcp4", "127.0.0.1:8408", time.Second)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	_, _ = io.WriteString(conn, "GET /comm HTTP/1.1\r\nHost: 127.0.0.1:8408\r\nUpgrade: websocket\r\n"+
+		"Connection: keep-alive, Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"+
+		"Origin: http://127.0.0.1\r\nSec-WebSocket-Version: 13\r\n\r\n")
+
+	reader := bufio.NewReader(conn)
+	response := ""
+	for !strings.HasSuffix(response, "\r\n\r\n") {
+		line, readErr := reader.ReadString('\n')
+		if readErr != nil {
+			t.Error(readErr)
+			return
+		}
+		response += line
+	}
+
+	// The example from RFC 6455
+	if !strings.HasPrefix(response, "HTTP/1.1 101") || !strings.Contains(response, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=") {
+		t.Errorf("unexpected response %q", response)
+		return
+	}
+
+	mask := []byte{1, 2, 3, 4}
+	maskedFrame := func(first byte, payload string) []byte {
+		frame := append([]byte{first, 0x80 | byte(len(payload))}, mask...)
+		for i := 0; i < len(payload); i++ {
+			frame = append(frame, payload[i]^mask[i%4])
+		}
+		return frame
+	}
+
+	_, _ = conn.Write(maskedFrame(0x89, "are you there"))
+	_, _ = conn.Write(maskedFrame(0x01, "hello "))
+	_, _ = conn.Write(maskedFrame(0x80, "world"))
+
+	var serverConn server.Connection
+	select {
+	case serverConn = <-s.Accept():
+	case <-time.After(time.Second):
+		t.Error("expected a connection")
+		return
+	}
+
+	received := ""
+	buffer := make([]byte, 64)
+	for deadline := time.Now().Add(time.Second); received != "hello world" && time.Now().Before(deadline); {
+		count, readErr := serverConn.Read(buffer)
+		if readErr != nil {
+			t.Error(readErr)
+			return
+		}
+		received += string(buffer[:count])
+	}
+	if received != "hello world" {
+		t.Errorf("expected 'hello world', have '%s'", received)
+	}
+
+	// The ping is answered as the server reads
+	pong := make([]byte, 2+len("are you there"))
+	_, err = io.ReadFull(reader, pong)
+	if err != nil || pong[0] != 0x8A || string(pong[2:]) != "are you there" {
+		t.Errorf("expected a pong, have %v (%v)", pong, err)
+	}
+
+	// Replies are sent unmasked as one binary message
+	_, err = serverConn.Write([]byte("hi"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	reply := make([]byte, 4)
+	_, err = io.ReadFull(reader, reply)
+	if err != nil || reply[0] != 0x82 || reply[1] != 2 || string(reply[2:]) != "hi" {
+		t.Errorf("expected a binary message, have %v (%v)", reply, err)
+	}
+}
+
+func TestWebSocketSecure(t *testing.T) {
+	certificate, roots := selfSignedCertificate(t)
+
+	serverCfg := server.NewConfig("wss://127.0.0.1/comm", 8409)
+	s, err := server.New(serverCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = s.Start()
+	if !errors.Is(err, comerr.ErrTLSConfigRequired) {
+		t.Errorf("expected ErrTLSConfigRequired, have %v", err)
+		s.Stop()
+		return
+	}
+
+	serverCfg.TLSConfig = &tls.Config{Certificates: []tls.Certificate{certificate}}
+	s.SetConfig(serverCfg)
+
+	err = s.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer stopAndWait(s)
+
+	go echoOnce(s)
+
+	clientCfg := client.NewConfig("wss://127.0.0.1:8409/comm", 0)
+	clientCfg.TLSConfig = &tls.Config{RootCAs: roots}
+	c, err := client.New(clientCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = c.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = c.Stop()
+	}()
+
+	_, err = c.Write([]byte("hello"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	buffer := make([]byte, 16)
+	count, err := c.Read(buffer)
+	if err != nil || string(buffer[:count]) != "hello" {
+		t.Errorf("expected 'hello', have '%s' (%v)", string(buffer[:count]), err)
+	}
+}
+
+func TestWebSocketNodes(t *testing.T) {
+	n1, err := node.New[string](node.NewConfig("ws://127.0.0.1:9013/comm"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	n2, err := node.New[string](node.NewConfig("ws://127.0.0.1:9014/comm"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = n1.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer n1.Stop()
+
+	err = n2.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer n2.Stop()
+
+	payload := "hello"
+	msg, err := n1.Send("ws://127.0.0.1:9014/comm", &payload)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	select {
+	case msgCopy := <-n2.Recv():
+		if msgCopy.ID() != msg.ID() || msgCopy.FromNode() != "ws://127.0.0.1:9013/comm" {
+			t.Errorf("unexpected message %d from %s", msgCopy.ID(), msgCopy.FromNode())
+		}
+	case <-time.After(time.Second):
+		t.Error("expected a message")
+		return
+	}
+
+	select {
+	case rcpt := <-n1.Status():
+		if rcpt.ID() != msg.ID() || rcpt.FromNode() != "ws://127.0.0.1:9014/comm" {
+			t.Errorf("unexpected receipt %d from %s", rcpt.ID(), rcpt.FromNode())
+		}
+	case <-time.After(time.Second):
+		t.Error("expected a receipt")
+	}
+}
+
+// selfSignedCertificate Returns a certificate for 127.0.0.1 along with a
+// pool trusting it
+func selfSignedCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "comm test"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(parsed)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, roots
+}